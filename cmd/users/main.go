@@ -10,9 +10,16 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/federation"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/health"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ratelimit"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/server"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
@@ -26,6 +33,10 @@ func main() {
 
 	logger := logging.NewLoggerV2("users-service")
 
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration", logging.Fields{"error": err.Error()})
+	}
+
 	// TODO(TEAM-PLATFORM): Migrate all legacy logging to structured logging
 	log.Printf("Starting users-service on port %d", cfg.Server.Port)
 
@@ -41,35 +52,158 @@ func main() {
 	// TODO(TEAM-SEC): Remove legacy user store after migration
 	legacyRepo := repository.NewPostgresUserStoreV1(db)
 
-	passwordService := auth.NewPasswordService(cfg.Features.EnableLegacyAuth)
-	jwtService := auth.NewJWTService(cfg.JWT.Secret, cfg.JWT.Expiration)
-	sessionService := auth.NewSessionService(cfg.Redis)
+	passwordHasher, err := auth.NewHasher(cfg.PasswordHash)
+	if err != nil {
+		logger.Fatal("Invalid password hashing configuration", logging.Fields{"error": err.Error()})
+	}
+	passwordService := auth.NewPasswordServiceWithHasher(passwordHasher, cfg.Features.EnableLegacyAuth)
+	breachedPasswords, err := auth.NewBreachedPasswords(cfg.BreachedPasswords)
+	if err != nil {
+		logger.Fatal("Failed to load breached password corpus", logging.Fields{"error": err.Error()})
+	}
+	passwordPolicy, err := auth.NewPasswordPolicy(cfg.PasswordPolicy, breachedPasswords)
+	if err != nil {
+		logger.Fatal("Failed to load password policy", logging.Fields{"error": err.Error()})
+	}
+	keyRing, err := auth.LoadKeyRing(cfg.JWT)
+	if err != nil {
+		logger.Fatal("Failed to load JWT signing keys", logging.Fields{"error": err.Error()})
+	}
+	jwtService := auth.NewJWTServiceWithKeys(keyRing, cfg.JWT.Expiration, cfg.JWT.Issuer)
+	var sessionStore auth.SessionStore
+	switch cfg.Sessions.Store {
+	case auth.SessionStoreRedis:
+		sessionStore = auth.NewRedisSessionStore(cfg.Redis)
+	case auth.SessionStorePostgres:
+		sessionStore = auth.NewPostgresSessionStore(db)
+	case auth.SessionStoreMemory:
+		sessionStore = auth.NewInMemorySessionStore()
+	default:
+		logger.Fatal("Unsupported session store", logging.Fields{"store": cfg.Sessions.Store})
+	}
+	sessionService := auth.NewSessionService(sessionStore, cfg.Sessions)
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	go sessionService.RunSweeper(sweepCtx, cfg.Sessions.SweepInterval)
+	refreshTokenService := auth.NewRefreshTokenService(
+		auth.NewRedisRefreshTokenStore(cfg.Redis),
+		cfg.JWT.RefreshExpiration,
+	)
+	lockoutService := auth.NewLockoutService(
+		auth.NewFallbackAttemptStore(auth.NewRedisAttemptStore(cfg.Redis), auth.NewInMemoryAttemptStore()),
+		cfg.Lockout,
+	)
+
+	passwordResetService := auth.NewPasswordResetService(
+		auth.NewRedisPasswordResetStore(cfg.Redis),
+		cfg.PasswordReset.TokenTTL,
+	)
+
+	mfaSecrets, err := auth.NewSecretBox(cfg.MFA.EncryptionKey)
+	if err != nil {
+		logger.Fatal("Failed to initialise MFA encryption", logging.Fields{"error": err.Error()})
+	}
+	mfaChallengeService := auth.NewMFAChallengeService(auth.NewRedisMFAChallengeStore(cfg.Redis), cfg.MFA)
+	webAuthn := auth.NewWebAuthn(auth.NewRedisWebAuthnChallengeStore(cfg.Redis), cfg.WebAuthn)
+
+	if cfg.Features.EnableMetrics {
+		metrics.RegisterDBStats(db, cfg.Database.Name)
+		metrics.RegisterActiveSessions(sessionService.CountActive)
+	}
+
+	auditLog := audit.NewRecorder(audit.NewPostgresStore(db))
+	notifier := notify.NewLogSender(!cfg.IsProduction())
 
 	userService := service.NewUserService(
 		userRepo,
 		userCache,
 		legacyRepo,
 		passwordService,
+		passwordPolicy,
+		auth.NewEmailTokenService(cfg.EmailVerification.Secret, cfg.EmailVerification.TokenTTL),
+		notifier,
+		auditLog,
 		cfg,
 	)
 
+	mfaService := service.NewMFAService(userRepo, passwordService, mfaSecrets, auditLog, cfg)
+	apiKeyService := service.NewAPIKeyService(userRepo, auditLog)
+	oauthClients := oidc.NewPostgresClientStore(db)
+	oauthClientService := service.NewOAuthClientService(oauthClients, auditLog)
+
 	authService := service.NewAuthService(
 		userRepo,
 		passwordService,
+		passwordPolicy,
 		jwtService,
 		sessionService,
+		refreshTokenService,
+		auth.NewRedisTokenDenylist(cfg.Redis),
+		lockoutService,
+		passwordResetService,
+		mfaService,
+		mfaChallengeService,
+		webAuthn,
+		notifier,
+		auditLog,
 		cfg,
 	)
 
-	h := handlers.NewHandlers(userService, authService, cfg)
+	healthChecks := health.NewRegistry()
+	healthChecks.Register("postgres", db.PingContext, 2*time.Second, true)
+	healthChecks.Register("sessions", sessionService.Ping, time.Second, true)
+	if cfg.Features.EnableUserCache {
+		// Lookups fall through to Postgres when the cache is down
+		healthChecks.Register("cache_redis", userCache.Ping, time.Second, false)
+	}
+
+	federatedProviders, err := federation.New(cfg.Federation, federation.NewRedisStateStore(cfg.Redis), nil)
+	if err != nil {
+		logger.Fatal("Invalid federated identity provider configuration", logging.Fields{"error": err.Error()})
+	}
+	federationService := service.NewFederationService(federatedProviders, userRepo, authService, userService, auditLog)
+
+	// Assertions from the legacy SSO provider are verified for other services
+	// only when its shared secret is configured
+	var ssoVerifier *auth.SSOVerifier
+	if cfg.LegacySSO.Secret != "" {
+		ssoVerifier = auth.NewSSOVerifier(cfg.LegacySSO, auth.NewRedisSSONonceStore(cfg.Redis), cfg.Features.EnableLegacySSOMD5)
+	}
+
+	h := handlers.NewHandlers(userService, authService, mfaService, apiKeyService, oauthClientService, federationService, ssoVerifier, auditLog, healthChecks, cfg)
+
+	// The token endpoint serves the client_credentials grant, introspection
+	// and revocation whether or not the OpenID Connect provider is enabled
+	tokenEndpoint := oidc.NewTokenEndpoint(authService.OIDCAccounts(), oauthClients, jwtService)
+
+	var oidcProvider *oidc.Provider
+	if cfg.OIDC.Enabled {
+		oidcProvider, err = oidc.NewProvider(
+			authService.OIDCAccounts(),
+			tokenEndpoint,
+			oidc.NewRedisCodeStore(cfg.Redis),
+			cfg.OIDC,
+		)
+		if err != nil {
+			logger.Fatal("Failed to initialise OpenID Connect provider", logging.Fields{"error": err.Error()})
+		}
+	}
+
+	// Redis shares rate limit counters across replicas; the in-process
+	// limiter is only accurate for a single instance
+	var limiter ratelimit.Limiter = ratelimit.NewInMemoryLimiter()
+	if cfg.RateLimit.Backend == "redis" {
+		limiter = ratelimit.NewRedisLimiter(cfg.Redis)
+	}
 
-	srv := server.New(h, cfg)
+	srv := server.New(h, tokenEndpoint, oidcProvider, limiter, cfg)
 
 	go func() {
 		logger.Info("Server starting", logging.Fields{
-			"port":               cfg.Server.Port,
-			"enable_legacy_auth": cfg.Features.EnableLegacyAuth,
-			"enable_v1_api":      cfg.Features.EnableV1API,
+			"port":                  cfg.Server.Port,
+			"enable_legacy_auth":    cfg.Features.EnableLegacyAuth,
+			"enable_legacy_sso_md5": cfg.Features.EnableLegacySSOMD5,
+			"enable_v1_api":         cfg.Features.EnableV1API,
 		})
 		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Server failed to start", logging.Fields{"error": err.Error()})
@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+)
+
+// Action identifies what happened in an audit event.
+type Action string
+
+const (
+	ActionUserCreate     Action = "user.create"
+	ActionUserUpdate     Action = "user.update"
+	ActionUserDelete     Action = "user.delete"
+	ActionUserUnlock     Action = "user.unlock"
+	ActionPasswordChange Action = "user.password_change"
+	ActionPasswordReset  Action = "user.password_reset"
+	ActionEmailVerify    Action = "user.email_verify"
+	ActionEmailChange    Action = "user.email_change"
+	ActionMFAEnable      Action = "user.mfa_enable"
+	ActionMFADisable     Action = "user.mfa_disable"
+	ActionRecoveryCodes  Action = "user.mfa_recovery_codes"
+	ActionPasskeyAdd     Action = "user.passkey_add"
+	ActionPasskeyRemove  Action = "user.passkey_remove"
+	ActionFederatedLink  Action = "user.federated_link"
+
+	ActionLogin         Action = "auth.login"
+	ActionLoginFailed   Action = "auth.login_failed"
+	ActionLogout        Action = "auth.logout"
+	ActionLogoutAll     Action = "auth.logout_all"
+	ActionNewDevice     Action = "auth.new_device"
+	ActionSessionRevoke Action = "session.revoke"
+
+	ActionAPIKeyCreate Action = "api_key.create"
+	ActionAPIKeyRotate Action = "api_key.rotate"
+	ActionAPIKeyRevoke Action = "api_key.revoke"
+
+	ActionOAuthAuthorize    Action = "oauth.authorize"
+	ActionOAuthRevoke       Action = "oauth.revoke"
+	ActionOAuthClientCreate Action = "oauth_client.create"
+	ActionOAuthClientDelete Action = "oauth_client.delete"
+)
+
+// Resource types an event can apply to.
+const (
+	ResourceUser    = "user"
+	ResourceSession = "session"
+	ResourceDevice  = "device"
+	ResourceAPIKey  = "api_key"
+
+	ResourceOAuthClient = "oauth_client"
+)
+
+// Event records who did what to which resource.
+type Event struct {
+	ID int64 `json:"id"`
+
+	// ActorID is the user who performed the action. It is empty for
+	// unauthenticated actions such as a failed login.
+	ActorID string `json:"actor_id,omitempty"`
+
+	Action       Action `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id,omitempty"`
+
+	// OldValue and NewValue hold the fields the action changed.
+	OldValue map[string]interface{} `json:"old_value,omitempty"`
+	NewValue map[string]interface{} `json:"new_value,omitempty"`
+
+	IPAddress string    `json:"ip_address,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter selects audit events. Zero-valued fields match everything.
+type Filter struct {
+	ActorID      string
+	ResourceType string
+	ResourceID   string
+	Action       Action
+	From         time.Time
+	To           time.Time
+	Limit        int
+	Offset       int
+}
+
+// Store persists audit events.
+type Store interface {
+	Insert(ctx context.Context, event *Event) error
+
+	// List returns matching events, newest first, and the total number of
+	// matches ignoring Limit and Offset.
+	List(ctx context.Context, filter *Filter) ([]*Event, int, error)
+}
+
+type contextKey string
+
+const contextKeyClientIP contextKey = "audit_client_ip"
+
+// WithClientIP stores the caller's IP address for events recorded while
+// handling the request.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKeyClientIP, ip)
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(contextKeyClientIP).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// Recorder writes audit events, filling in the actor, client IP and request
+// ID from the request context.
+type Recorder struct {
+	store  Store
+	logger *logging.LoggerV2
+}
+
+// NewRecorder creates a new audit recorder.
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{
+		store:  store,
+		logger: logging.NewLoggerV2("audit"),
+	}
+}
+
+// Record writes an event. Failures are logged rather than returned so that
+// an audit outage does not fail the operation being audited.
+// TODO(TEAM-SEC): Decide whether security-critical actions should fail closed
+func (r *Recorder) Record(ctx context.Context, event *Event) {
+	if event.ActorID == "" {
+		event.ActorID = middleware.GetUserFromContext(ctx)
+	}
+	if event.IPAddress == "" {
+		event.IPAddress = clientIPFromContext(ctx)
+	}
+	if event.RequestID == "" {
+		event.RequestID = logging.GetRequestID(ctx)
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	if err := r.store.Insert(ctx, event); err != nil {
+		r.logger.Error("failed to write audit event", logging.Fields{
+			"action":      string(event.Action),
+			"resource_id": event.ResourceID,
+			"actor_id":    event.ActorID,
+			"error":       err.Error(),
+		})
+	}
+}
+
+// List returns audit events matching filter.
+func (r *Recorder) List(ctx context.Context, filter *Filter) ([]*Event, int, error) {
+	return r.store.List(ctx, filter)
+}
+
+// ignoredFields change on every write and would add noise to every diff.
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+// Diff compares the JSON representations of before and after and returns
+// only the fields that differ. Nested objects are flattened into dotted keys,
+// e.g. "preferences.theme". Either argument may be nil.
+func Diff(before, after interface{}) (oldValue, newValue map[string]interface{}) {
+	oldFields := flatten(before)
+	newFields := flatten(after)
+
+	oldValue = map[string]interface{}{}
+	newValue = map[string]interface{}{}
+
+	for key, ov := range oldFields {
+		if nv, ok := newFields[key]; !ok || !reflect.DeepEqual(ov, nv) {
+			oldValue[key] = ov
+			if ok {
+				newValue[key] = nv
+			}
+		}
+	}
+	for key, nv := range newFields {
+		if _, ok := oldFields[key]; !ok {
+			newValue[key] = nv
+		}
+	}
+
+	if len(oldValue) == 0 {
+		oldValue = nil
+	}
+	if len(newValue) == 0 {
+		newValue = nil
+	}
+	return oldValue, newValue
+}
+
+// Snapshot returns the fields of v as an audit value, for events that create
+// or remove a resource.
+func Snapshot(v interface{}) map[string]interface{} {
+	fields := flatten(v)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+func flatten(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fields
+	}
+
+	flattenInto(fields, "", m)
+	return fields
+}
+
+func flattenInto(dst map[string]interface{}, prefix string, m map[string]interface{}) {
+	for key, value := range m {
+		if prefix == "" && ignoredFields[key] {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenInto(dst, key, nested)
+			continue
+		}
+		dst[key] = value
+	}
+}
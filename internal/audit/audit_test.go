@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+)
+
+type profile struct {
+	Email       string            `json:"email"`
+	Role        string            `json:"role"`
+	Preferences map[string]string `json:"preferences"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+func TestDiff(t *testing.T) {
+	before := &profile{
+		Email:       "jane@example.com",
+		Role:        "customer",
+		Preferences: map[string]string{"theme": "light", "locale": "en-US"},
+		UpdatedAt:   time.Unix(0, 0),
+	}
+	after := &profile{
+		Email:       "jane@example.com",
+		Role:        "admin",
+		Preferences: map[string]string{"theme": "dark", "locale": "en-US"},
+		UpdatedAt:   time.Now(),
+	}
+
+	oldValue, newValue := Diff(before, after)
+
+	expectedOld := map[string]interface{}{"role": "customer", "preferences.theme": "light"}
+	expectedNew := map[string]interface{}{"role": "admin", "preferences.theme": "dark"}
+
+	if len(oldValue) != len(expectedOld) || len(newValue) != len(expectedNew) {
+		t.Fatalf("expected only changed fields, got old=%v new=%v", oldValue, newValue)
+	}
+	for k, v := range expectedOld {
+		if oldValue[k] != v {
+			t.Fatalf("expected old %s=%v, got %v", k, v, oldValue[k])
+		}
+	}
+	for k, v := range expectedNew {
+		if newValue[k] != v {
+			t.Fatalf("expected new %s=%v, got %v", k, v, newValue[k])
+		}
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		oldValue, newValue := Diff(before, before)
+		if oldValue != nil || newValue != nil {
+			t.Fatalf("expected nil diff, got old=%v new=%v", oldValue, newValue)
+		}
+	})
+}
+
+func TestRecorderFillsContext(t *testing.T) {
+	store := NewInMemoryStore()
+	recorder := NewRecorder(store)
+
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyUser, "user-admin")
+	ctx = WithClientIP(ctx, "10.0.0.1")
+
+	recorder.Record(ctx, &Event{
+		Action:       ActionUserDelete,
+		ResourceType: ResourceUser,
+		ResourceID:   "user-123",
+	})
+
+	events, _, err := store.List(context.Background(), &Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].ActorID != "user-admin" {
+		t.Fatalf("expected actor user-admin, got %q", events[0].ActorID)
+	}
+	if events[0].IPAddress != "10.0.0.1" {
+		t.Fatalf("expected ip 10.0.0.1, got %q", events[0].IPAddress)
+	}
+	if events[0].CreatedAt.IsZero() {
+		t.Fatal("expected created_at to be set")
+	}
+}
+
+func TestInMemoryStoreList(t *testing.T) {
+	store := NewInMemoryStore()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, e := range []*Event{
+		{ActorID: "user-1", Action: ActionLogin, ResourceType: ResourceSession, ResourceID: "sess-1"},
+		{ActorID: "user-admin", Action: ActionUserUpdate, ResourceType: ResourceUser, ResourceID: "user-1"},
+		{ActorID: "user-admin", Action: ActionUserDelete, ResourceType: ResourceUser, ResourceID: "user-1"},
+		{ActorID: "user-admin", Action: ActionUserUpdate, ResourceType: ResourceUser, ResourceID: "user-2"},
+	} {
+		e.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		if err := store.Insert(ctx, e); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+		total    int
+	}{
+		{"by actor", Filter{ActorID: "user-admin"}, []string{"user-2", "user-1", "user-1"}, 3},
+		{"by subject", Filter{ResourceType: ResourceUser, ResourceID: "user-1"}, []string{"user-1", "user-1"}, 2},
+		{"by action", Filter{Action: ActionLogin}, []string{"sess-1"}, 1},
+		{"by time range", Filter{From: base.Add(time.Hour), To: base.Add(3 * time.Hour)}, []string{"user-1", "user-1"}, 2},
+		{"paginated", Filter{Limit: 2, Offset: 1}, []string{"user-1", "user-1"}, 4},
+		{"offset past end", Filter{Limit: 2, Offset: 10}, []string{}, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			events, total, err := store.List(ctx, &filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if total != tt.total {
+				t.Fatalf("expected total %d, got %d", tt.total, total)
+			}
+			if len(events) != len(tt.expected) {
+				t.Fatalf("expected %d events, got %d", len(tt.expected), len(events))
+			}
+			for i, id := range tt.expected {
+				if events[i].ResourceID != id {
+					t.Fatalf("expected event %d on %s, got %s", i, id, events[i].ResourceID)
+				}
+			}
+		})
+	}
+}
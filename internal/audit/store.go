@@ -0,0 +1,249 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// PostgresStore stores audit events in the audit_log table.
+type PostgresStore struct {
+	db     *sql.DB
+	logger *logging.LoggerV2
+}
+
+// NewPostgresStore creates a new PostgreSQL-backed audit store.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		db:     db,
+		logger: logging.NewLoggerV2("audit-store"),
+	}
+}
+
+// Insert writes an event and sets its ID.
+func (s *PostgresStore) Insert(ctx context.Context, event *Event) error {
+	oldValue, err := marshalValue(event.OldValue)
+	if err != nil {
+		return err
+	}
+	newValue, err := marshalValue(event.NewValue)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (
+			user_id, action, resource_type, resource_id,
+			old_value, new_value, ip_address, request_id, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	return s.db.QueryRowContext(ctx, query,
+		nullString(event.ActorID),
+		string(event.Action),
+		event.ResourceType,
+		nullString(event.ResourceID),
+		oldValue,
+		newValue,
+		nullString(event.IPAddress),
+		nullString(event.RequestID),
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+// List returns matching events, newest first.
+func (s *PostgresStore) List(ctx context.Context, filter *Filter) ([]*Event, int, error) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		add("user_id = $%d", filter.ActorID)
+	}
+	if filter.ResourceType != "" {
+		add("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		add("resource_id = $%d", filter.ResourceID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", string(filter.Action))
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, user_id, action, resource_type, resource_id,
+		       old_value, new_value, ip_address, request_id, created_at
+		FROM audit_log%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		s.logger.Error("failed to query audit log", logging.Fields{"error": err.Error()})
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*Event{}
+	for rows.Next() {
+		var event Event
+		var actorID, resourceID, ipAddress, requestID sql.NullString
+		var oldValue, newValue []byte
+
+		if err := rows.Scan(
+			&event.ID,
+			&actorID,
+			&event.Action,
+			&event.ResourceType,
+			&resourceID,
+			&oldValue,
+			&newValue,
+			&ipAddress,
+			&requestID,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		event.ActorID = actorID.String
+		event.ResourceID = resourceID.String
+		event.IPAddress = ipAddress.String
+		event.RequestID = requestID.String
+		if err := unmarshalValue(oldValue, &event.OldValue); err != nil {
+			return nil, 0, err
+		}
+		if err := unmarshalValue(newValue, &event.NewValue); err != nil {
+			return nil, 0, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, total, rows.Err()
+}
+
+func marshalValue(v map[string]interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func unmarshalValue(data []byte, v *map[string]interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// InMemoryStore is an audit store for tests and local development.
+type InMemoryStore struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{}
+}
+
+func (s *InMemoryStore) Insert(ctx context.Context, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *event
+	stored.ID = int64(len(s.events) + 1)
+	s.events = append(s.events, &stored)
+	event.ID = stored.ID
+	return nil
+}
+
+func (s *InMemoryStore) List(ctx context.Context, filter *Filter) ([]*Event, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := []*Event{}
+	for _, e := range s.events {
+		if filter.matches(e) {
+			copied := *e
+			matches = append(matches, &copied)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].ID > matches[j].ID
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	total := len(matches)
+	if filter.Offset >= total {
+		return []*Event{}, total, nil
+	}
+	end := total
+	if filter.Limit > 0 && filter.Offset+filter.Limit < total {
+		end = filter.Offset + filter.Limit
+	}
+
+	return matches[filter.Offset:end], total, nil
+}
+
+func (f *Filter) matches(e *Event) bool {
+	switch {
+	case f.ActorID != "" && e.ActorID != f.ActorID:
+		return false
+	case f.ResourceType != "" && e.ResourceType != f.ResourceType:
+		return false
+	case f.ResourceID != "" && e.ResourceID != f.ResourceID:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case !f.From.IsZero() && e.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ Store = (*PostgresStore)(nil)
+	_ Store = (*InMemoryStore)(nil)
+)
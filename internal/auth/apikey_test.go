@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	generated, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(generated.Key, "acme_"+generated.Prefix+"_") {
+		t.Fatalf("expected key to embed its prefix, got %s", generated.Key)
+	}
+	if strings.Contains(generated.Hash, generated.Key) {
+		t.Fatal("expected hash not to contain the key")
+	}
+
+	prefix, err := ParseAPIKey(generated.Key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if prefix != generated.Prefix {
+		t.Fatalf("expected prefix %s, got %s", generated.Prefix, prefix)
+	}
+
+	if !VerifyAPIKey(generated.Key, generated.Hash) {
+		t.Fatal("expected key to verify against its hash")
+	}
+	if VerifyAPIKey(generated.Key+"x", generated.Hash) {
+		t.Fatal("expected modified key to fail verification")
+	}
+
+	other, _ := GenerateAPIKey()
+	if other.Key == generated.Key || other.Prefix == generated.Prefix {
+		t.Fatal("expected keys to be unique")
+	}
+	if VerifyAPIKey(other.Key, generated.Hash) {
+		t.Fatal("expected another key to fail verification")
+	}
+}
+
+func TestHashAPIKeySalted(t *testing.T) {
+	a, _ := hashAPIKey("acme_000000000000_secret")
+	b, _ := hashAPIKey("acme_000000000000_secret")
+	if a == b {
+		t.Fatal("expected hashes of the same key to differ")
+	}
+	if !VerifyAPIKey("acme_000000000000_secret", a) || !VerifyAPIKey("acme_000000000000_secret", b) {
+		t.Fatal("expected both hashes to verify")
+	}
+}
+
+func TestParseAPIKeyInvalid(t *testing.T) {
+	for _, key := range []string{
+		"",
+		"legacy-api-key-1234567890",
+		"acme_",
+		"acme_0123456789ab",
+		"acme_0123456789ab_",
+		"acme_short_secret",
+		"acme_zzzzzzzzzzzz_secret",
+	} {
+		if _, err := ParseAPIKey(key); err != ErrAPIKeyInvalid {
+			t.Fatalf("expected ErrAPIKeyInvalid for %q, got %v", key, err)
+		}
+	}
+}
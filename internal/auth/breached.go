@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// Breached password screening modes.
+const (
+	BreachedModeRange = "range"
+	BreachedModeBloom = "bloom"
+)
+
+const (
+	// breachPrefixLen is the SHA-1 hex prefix length that partitions the
+	// corpus into range files, as in the Have I Been Pwned range API.
+	breachPrefixLen = 5
+
+	// breachBloomFalsePositiveRate is the target false positive rate of the
+	// Bloom filter. A false positive only asks a user for another password.
+	breachBloomFalsePositiveRate = 0.001
+)
+
+// BreachedPasswords reports whether a password appears in a corpus of
+// breached passwords. Lookups never leave the host.
+type BreachedPasswords interface {
+	Breached(password string) (bool, error)
+}
+
+// NewBreachedPasswords returns the breached-password screen selected by cfg,
+// or nil if screening is disabled.
+func NewBreachedPasswords(cfg config.BreachedPasswordsConfig) (BreachedPasswords, error) {
+	switch cfg.Mode {
+	case "":
+		return nil, nil
+	case BreachedModeRange:
+		return NewRangeBreachedPasswords(cfg.Dir, cfg.MinCount)
+	case BreachedModeBloom:
+		return NewBloomBreachedPasswords(cfg.Dir, cfg.MinCount)
+	default:
+		return nil, fmt.Errorf("unsupported breached password mode %q", cfg.Mode)
+	}
+}
+
+// RangeBreachedPasswords looks passwords up in range files on disk. Only the
+// file for the password's SHA-1 prefix is read, so the corpus is never held
+// in memory.
+type RangeBreachedPasswords struct {
+	dir      string
+	minCount int
+}
+
+// NewRangeBreachedPasswords creates a range-file screen over dir.
+func NewRangeBreachedPasswords(dir string, minCount int) (*RangeBreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password corpus: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password corpus %s is not a directory", dir)
+	}
+	return &RangeBreachedPasswords{dir: dir, minCount: minCount}, nil
+}
+
+// Breached reports whether password appears at least minCount times. A
+// missing range file means the prefix has no entries.
+func (r *RangeBreachedPasswords) Breached(password string) (bool, error) {
+	prefix, suffix := breachHashParts(password)
+
+	f, err := os.Open(filepath.Join(r.dir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	found := false
+	err = scanRangeFile(f, func(entry string, count int) bool {
+		if entry == suffix {
+			found = count >= r.minCount
+			return false
+		}
+		return true
+	})
+	return found, err
+}
+
+// BloomBreachedPasswords holds a Bloom filter of the corpus built at startup.
+// Lookups are in-memory, at the cost of a small false positive rate.
+type BloomBreachedPasswords struct {
+	bits   []uint64
+	m      uint64
+	hashes uint64
+}
+
+// NewBloomBreachedPasswords builds a Bloom filter from every range file in
+// dir. Building reads the corpus twice: once to size the filter and once to
+// fill it.
+func NewBloomBreachedPasswords(dir string, minCount int) (*BloomBreachedPasswords, error) {
+	files, err := rangeFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var n uint64
+	err = walkRangeFiles(files, minCount, func([]byte) { n++ })
+	if err != nil {
+		return nil, err
+	}
+
+	filter := newBloomFilter(n, breachBloomFalsePositiveRate)
+	err = walkRangeFiles(files, minCount, filter.add)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func newBloomFilter(n uint64, falsePositiveRate float64) *BloomBreachedPasswords {
+	if n == 0 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomBreachedPasswords{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: k,
+	}
+}
+
+// Breached reports whether password is probably in the corpus.
+func (b *BloomBreachedPasswords) Breached(password string) (bool, error) {
+	digest := sha1.Sum([]byte(password))
+	return b.contains(digest[:]), nil
+}
+
+// add and contains derive every bit position from the SHA-1 digest by
+// double hashing; the digest is already uniformly distributed.
+func (b *BloomBreachedPasswords) add(digest []byte) {
+	h1, h2 := bloomHashes(digest)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *BloomBreachedPasswords) contains(digest []byte) bool {
+	h1, h2 := bloomHashes(digest)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func bloomHashes(digest []byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(digest[0:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
+
+// breachHashParts splits a password's uppercase SHA-1 hex digest into its
+// range file prefix and the suffix listed inside the file.
+func breachHashParts(password string) (string, string) {
+	digest := sha1.Sum([]byte(password))
+	encoded := strings.ToUpper(hex.EncodeToString(digest[:]))
+	return encoded[:breachPrefixLen], encoded[breachPrefixLen:]
+}
+
+// rangeFiles lists the range files in dir, keyed by prefix.
+func rangeFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open breached password corpus: %w", err)
+	}
+
+	files := make(map[string]string)
+	for _, entry := range entries {
+		prefix, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if entry.IsDir() || !ok || len(prefix) != breachPrefixLen {
+			continue
+		}
+		if _, err := hex.DecodeString(prefix + "0"); err != nil {
+			continue
+		}
+		files[strings.ToUpper(prefix)] = filepath.Join(dir, entry.Name())
+	}
+	return files, nil
+}
+
+// walkRangeFiles calls fn with the SHA-1 digest of every entry seen at
+// least minCount times.
+func walkRangeFiles(files map[string]string, minCount int, fn func(digest []byte)) error {
+	for prefix, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		var parseErr error
+		err = scanRangeFile(f, func(suffix string, count int) bool {
+			if count < minCount {
+				return true
+			}
+			digest, err := hex.DecodeString(prefix + suffix)
+			if err != nil || len(digest) != sha1.Size {
+				parseErr = fmt.Errorf("malformed entry in %s", path)
+				return false
+			}
+			fn(digest)
+			return true
+		})
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if parseErr != nil {
+			return parseErr
+		}
+	}
+	return nil
+}
+
+// scanRangeFile calls fn for each "<SUFFIX>:<COUNT>" line until fn returns
+// false. Suffixes are passed in uppercase; lines without a count count once.
+func scanRangeFile(r io.Reader, fn func(suffix string, count int) bool) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		suffix, countField, hasCount := strings.Cut(line, ":")
+		count := 1
+		if hasCount {
+			n, err := strconv.Atoi(countField)
+			if err != nil {
+				return fmt.Errorf("malformed range file line %q", line)
+			}
+			count = n
+		}
+
+		if !fn(strings.ToUpper(suffix), count) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// writeRangeCorpus writes HIBP-style range files for passwords, each seen
+// count times, plus an unrelated entry in every file.
+func writeRangeCorpus(t *testing.T, counts map[string]int) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := make(map[string]string)
+	for password, count := range counts {
+		prefix, suffix := breachHashParts(password)
+		files[prefix] += fmt.Sprintf("%s:%d\r\n", suffix, count)
+	}
+	for prefix, content := range files {
+		content = "0000000000000000000000000000000000A:3\r\n" + content
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write range file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestBreachedPasswords(t *testing.T) {
+	dir := writeRangeCorpus(t, map[string]int{
+		"Summer2024!":     4512,
+		"Tangerine42Kite": 1,
+	})
+
+	for _, mode := range []string{BreachedModeRange, BreachedModeBloom} {
+		t.Run(mode, func(t *testing.T) {
+			breached, err := NewBreachedPasswords(config.BreachedPasswordsConfig{Mode: mode, Dir: dir, MinCount: 2})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if found, err := breached.Breached("Summer2024!"); err != nil || !found {
+				t.Fatalf("expected breached password to be found, got %v, %v", found, err)
+			}
+			if found, _ := breached.Breached("Tangerine42Kite"); found {
+				t.Fatal("expected password below the minimum count to be ignored")
+			}
+			if found, _ := breached.Breached("Quartz-Lantern-77"); found {
+				t.Fatal("expected unknown password not to be found")
+			}
+		})
+	}
+}
+
+func TestNewBreachedPasswords(t *testing.T) {
+	breached, err := NewBreachedPasswords(config.BreachedPasswordsConfig{})
+	if err != nil || breached != nil {
+		t.Fatalf("expected screening to be disabled, got %v, %v", breached, err)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	for _, mode := range []string{BreachedModeRange, BreachedModeBloom} {
+		if _, err := NewBreachedPasswords(config.BreachedPasswordsConfig{Mode: mode, Dir: missing}); err == nil {
+			t.Fatalf("expected missing corpus to be rejected in %s mode", mode)
+		}
+	}
+
+	if _, err := NewBreachedPasswords(config.BreachedPasswordsConfig{Mode: "remote"}); err == nil {
+		t.Fatal("expected unsupported mode to be rejected")
+	}
+}
+
+func TestBloomBreachedPasswordsMalformed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ABCDE.txt"), []byte("not-hex:1\n"), 0o600); err != nil {
+		t.Fatalf("failed to write range file: %v", err)
+	}
+
+	if _, err := NewBloomBreachedPasswords(dir, 1); err == nil {
+		t.Fatal("expected malformed range file to be rejected")
+	}
+}
+
+func TestPasswordPolicyBreached(t *testing.T) {
+	breached, _ := NewRangeBreachedPasswords(writeRangeCorpus(t, map[string]int{"Summer2024!": 10}), 1)
+	policy, err := NewPasswordPolicy(testPasswordPolicyConfig(), breached)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if codes := violationCodes(policy.Check("Summer2024!", PasswordContext{})); !codes[ViolationBreached] {
+		t.Fatalf("expected breached_password violation, got %v", codes)
+	}
+	if codes := violationCodes(policy.Check("Tangerine42Kite", PasswordContext{})); codes[ViolationBreached] {
+		t.Fatalf("expected no breached_password violation, got %v", codes)
+	}
+}
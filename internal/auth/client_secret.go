@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+const clientSecretBytes = 32
+
+// GenerateClientSecret creates a random OAuth client secret and its hash. The
+// secret is shown to the client's owner once; only the hash is stored.
+func GenerateClientSecret() (secret, hash string, err error) {
+	b := make([]byte, clientSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+
+	// Client secrets have the same entropy as API keys, so they share the
+	// salted SHA-256 scheme
+	hash, err = hashAPIKey(secret)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, hash, nil
+}
+
+// VerifyClientSecret reports whether secret matches a hash produced by
+// GenerateClientSecret.
+func VerifyClientSecret(secret, hash string) bool {
+	return VerifyAPIKey(secret, hash)
+}
@@ -0,0 +1,22 @@
+package auth
+
+import "testing"
+
+func TestGenerateClientSecret(t *testing.T) {
+	secret, hash, err := GenerateClientSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !VerifyClientSecret(secret, hash) {
+		t.Fatal("expected secret to verify against its hash")
+	}
+	if VerifyClientSecret(secret+"x", hash) {
+		t.Fatal("expected modified secret to fail verification")
+	}
+
+	other, _, _ := GenerateClientSecret()
+	if other == secret {
+		t.Fatal("expected secrets to be unique")
+	}
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const revokedTokenPrefix = "revoked_token:"
+
+// TokenDenylist records the IDs (jti) of revoked access tokens. Entries only
+// need to outlive the token, which is rejected as expired afterwards.
+type TokenDenylist interface {
+	// Add denies a token ID until expiresAt.
+	Add(ctx context.Context, tokenID string, expiresAt time.Time) error
+
+	// Contains reports whether a token ID has been denied.
+	Contains(ctx context.Context, tokenID string) (bool, error)
+}
+
+// RedisTokenDenylist stores revoked token IDs in Redis so that revocation
+// holds across service instances.
+type RedisTokenDenylist struct {
+	client *redis.Client
+}
+
+// NewRedisTokenDenylist creates a new Redis-backed token denylist.
+func NewRedisTokenDenylist(cfg config.RedisConfig) *RedisTokenDenylist {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisTokenDenylist{client: client}
+}
+
+func (d *RedisTokenDenylist) Add(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, revokedTokenPrefix+tokenID, 1, ttl).Err()
+}
+
+func (d *RedisTokenDenylist) Contains(ctx context.Context, tokenID string) (bool, error) {
+	n, err := d.client.Exists(ctx, revokedTokenPrefix+tokenID).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// InMemoryTokenDenylist is a token denylist for tests and single-node
+// development.
+type InMemoryTokenDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+func NewInMemoryTokenDenylist() *InMemoryTokenDenylist {
+	return &InMemoryTokenDenylist{
+		entries: make(map[string]time.Time),
+	}
+}
+
+func (d *InMemoryTokenDenylist) Add(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if !expiresAt.After(now) {
+		return nil
+	}
+	d.entries[tokenID] = expiresAt
+
+	// Drop entries for tokens that have expired anyway
+	for id, exp := range d.entries {
+		if !exp.After(now) {
+			delete(d.entries, id)
+		}
+	}
+	return nil
+}
+
+func (d *InMemoryTokenDenylist) Contains(ctx context.Context, tokenID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	exp, ok := d.entries[tokenID]
+	return ok && time.Now().Before(exp), nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ TokenDenylist = (*RedisTokenDenylist)(nil)
+	_ TokenDenylist = (*InMemoryTokenDenylist)(nil)
+)
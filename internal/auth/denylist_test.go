@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInMemoryTokenDenylist(t *testing.T) {
+	ctx := context.Background()
+	denylist := NewInMemoryTokenDenylist()
+
+	if denied, _ := denylist.Contains(ctx, "token-1"); denied {
+		t.Fatal("expected unknown token not to be denied")
+	}
+
+	denylist.Add(ctx, "token-1", time.Now().Add(20*time.Millisecond))
+	denylist.Add(ctx, "token-2", time.Now().Add(-time.Second))
+
+	if denied, _ := denylist.Contains(ctx, "token-1"); !denied {
+		t.Fatal("expected revoked token to be denied")
+	}
+	if denied, _ := denylist.Contains(ctx, "token-2"); denied {
+		t.Fatal("expected already expired token not to be recorded")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if denied, _ := denylist.Contains(ctx, "token-1"); denied {
+		t.Fatal("expected entry to lapse once the token expires")
+	}
+}
+
+func TestGeneratedTokensHaveUniqueIDs(t *testing.T) {
+	svc := NewJWTService("test-secret-key", time.Hour)
+
+	a, _ := svc.GenerateClientToken("client-1", []string{"users:list"})
+	b, _ := svc.GenerateClientToken("client-1", []string{"users:list"})
+
+	ca, err := svc.ValidateToken(a)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	cb, _ := svc.ValidateToken(b)
+	if ca.ID == "" || ca.ID == cb.ID {
+		t.Fatalf("expected distinct token IDs, got %q and %q", ca.ID, cb.ID)
+	}
+}
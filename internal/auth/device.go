@@ -0,0 +1,108 @@
+package auth
+
+import "strings"
+
+// Device types reported by ParseUserAgent.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// DeviceInfo describes the browser and platform a User-Agent header claims.
+// Clients can send any User-Agent, so it is only fit for display.
+type DeviceInfo struct {
+	Browser string `json:"browser,omitempty"`
+	OS      string `json:"os,omitempty"`
+	Type    string `json:"type"`
+}
+
+// Label returns a short description such as "Chrome on macOS".
+func (d DeviceInfo) Label() string {
+	switch {
+	case d.Browser != "" && d.OS != "":
+		return d.Browser + " on " + d.OS
+	case d.Browser != "":
+		return d.Browser
+	case d.OS != "":
+		return d.OS + " device"
+	default:
+		return "Unknown device"
+	}
+}
+
+// uaRule maps a User-Agent token to a name. Rules are checked in order, so
+// more specific tokens come first: Edge and Opera also claim to be Chrome,
+// and Chrome also claims to be Safari.
+type uaRule struct {
+	token string
+	name  string
+}
+
+var browserRules = []uaRule{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Version/", "Safari"},
+	{"Trident/", "Internet Explorer"},
+	{"MSIE ", "Internet Explorer"},
+}
+
+var osRules = []uaRule{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"iPod", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "curl/", "wget/", "python-requests", "go-http-client"}
+
+// ParseUserAgent extracts the browser, operating system and device type
+// from a User-Agent header. Unrecognised parts are left empty.
+func ParseUserAgent(userAgent string) DeviceInfo {
+	lower := strings.ToLower(userAgent)
+	for _, token := range botTokens {
+		if strings.Contains(lower, token) {
+			return DeviceInfo{Type: DeviceBot}
+		}
+	}
+
+	info := DeviceInfo{
+		Browser: matchRule(userAgent, browserRules),
+		OS:      matchRule(userAgent, osRules),
+	}
+
+	switch {
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(info.OS == "Android" && !strings.Contains(userAgent, "Mobile")):
+		info.Type = DeviceTablet
+	case strings.Contains(userAgent, "Mobi") || info.OS == "iOS":
+		info.Type = DeviceMobile
+	case info.OS != "":
+		info.Type = DeviceDesktop
+	default:
+		info.Type = DeviceUnknown
+	}
+	return info
+}
+
+func matchRule(userAgent string, rules []uaRule) string {
+	for _, rule := range rules {
+		if strings.Contains(userAgent, rule.token) {
+			return rule.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package auth
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      DeviceInfo
+		label     string
+	}{
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			DeviceInfo{Browser: "Chrome", OS: "macOS", Type: DeviceDesktop},
+			"Chrome on macOS",
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			DeviceInfo{Browser: "Edge", OS: "Windows", Type: DeviceDesktop},
+			"Edge on Windows",
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			DeviceInfo{Browser: "Firefox", OS: "Linux", Type: DeviceDesktop},
+			"Firefox on Linux",
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			DeviceInfo{Browser: "Safari", OS: "iOS", Type: DeviceMobile},
+			"Safari on iOS",
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			DeviceInfo{Browser: "Chrome", OS: "iPadOS", Type: DeviceTablet},
+			"Chrome on iPadOS",
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			DeviceInfo{Browser: "Chrome", OS: "Android", Type: DeviceMobile},
+			"Chrome on Android",
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Safari/537.36",
+			DeviceInfo{Browser: "Samsung Internet", OS: "Android", Type: DeviceTablet},
+			"Samsung Internet on Android",
+		},
+		{
+			"Googlebot/2.1 (+http://www.google.com/bot.html)",
+			DeviceInfo{Type: DeviceBot},
+			"Unknown device",
+		},
+		{
+			"",
+			DeviceInfo{Type: DeviceUnknown},
+			"Unknown device",
+		},
+	}
+
+	for _, tt := range tests {
+		got := ParseUserAgent(tt.userAgent)
+		if got != tt.want {
+			t.Fatalf("expected %+v for %q, got %+v", tt.want, tt.userAgent, got)
+		}
+		if got.Label() != tt.label {
+			t.Fatalf("expected label %q, got %q", tt.label, got.Label())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailToken(t *testing.T) {
+	svc := NewEmailTokenService("test-secret", time.Hour)
+
+	token, _, err := svc.Issue("user-123", "jane@example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := svc.Verify(token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if claims.UserID != "user-123" || claims.Email != "jane@example.com" {
+			t.Fatalf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		forged, _, _ := svc.Issue("user-456", "jane@example.com")
+		payload, _, _ := strings.Cut(forged, ".")
+		_, signature, _ := strings.Cut(token, ".")
+
+		if _, err := svc.Verify(payload + "." + signature); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("different secret", func(t *testing.T) {
+		other := NewEmailTokenService("other-secret", time.Hour)
+		if _, err := other.Verify(token); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, tok := range []string{"", "no-separator", ".", "abc.def"} {
+			if _, err := svc.Verify(tok); err != ErrInvalidToken {
+				t.Fatalf("expected ErrInvalidToken for %q, got %v", tok, err)
+			}
+		}
+	})
+}
+
+func TestEmailTokenExpiry(t *testing.T) {
+	svc := NewEmailTokenService("test-secret", -time.Minute)
+
+	token, _, err := svc.Issue("user-123", "jane@example.com")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := svc.Verify(token); err != ErrExpiredToken {
+		t.Fatalf("expected ErrExpiredToken, got %v", err)
+	}
+}
@@ -18,6 +18,10 @@ var (
 	ErrSessionExpired  = errors.New("session expired")
 	ErrSessionInvalid  = errors.New("session invalid")
 	ErrSessionRevoked  = errors.New("session revoked")
+
+	// ErrTooManySessions is returned when a login would exceed the user's
+	// concurrent session limit and the role is configured to reject it.
+	ErrTooManySessions = errors.New("too many active sessions")
 )
 
 // Token errors
@@ -27,6 +31,75 @@ var (
 	ErrInvalidClaims    = errors.New("invalid claims")
 	ErrTokenNotYetValid = errors.New("token not yet valid")
 	ErrTokenRevoked     = errors.New("token has been revoked")
+
+	// ErrRefreshTokenReused is returned when an already-rotated refresh token
+	// is presented again, which indicates the token was stolen.
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+)
+
+// Email verification errors
+var (
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+)
+
+// MFA errors
+var (
+	// ErrMFARequired is returned when the caller's role requires MFA and the
+	// caller has not completed it.
+	ErrMFARequired       = errors.New("multi-factor authentication is required")
+	ErrMFAAlreadyEnabled = errors.New("multi-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("multi-factor authentication is not enabled")
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+)
+
+// WebAuthn errors
+var (
+	ErrWebAuthnInvalidResponse = errors.New("invalid WebAuthn response")
+
+	// ErrWebAuthnSignCount is returned when an authenticator's signature
+	// counter goes backwards, which indicates a cloned authenticator.
+	ErrWebAuthnSignCount = errors.New("authenticator signature counter did not increase")
+)
+
+// Federated login errors
+var (
+	// ErrFederatedEmailUnverified is returned when an identity provider
+	// asserts an email address it has not verified, so it cannot be used
+	// to find or create an account.
+	ErrFederatedEmailUnverified = errors.New("identity provider did not verify the email address")
+
+	// ErrFederatedAccountUnverified is returned when the matching local
+	// account's email address is unverified, so it cannot be linked.
+	ErrFederatedAccountUnverified = errors.New("account email address must be verified before linking")
+
+	// ErrFederatedAccountNotFound is returned when no account matches and
+	// the provider does not provision new accounts.
+	ErrFederatedAccountNotFound = errors.New("no account for this identity")
+
+	// ErrFederatedDomainNotAllowed is returned when the identity's email
+	// domain is not one the provider is trusted to assert.
+	ErrFederatedDomainNotAllowed = errors.New("identity provider is not trusted for this email domain")
+
+	// ErrFederatedLinkRequired is returned when an identity matches an
+	// account that cannot be linked automatically. The user must sign in
+	// and link the provider themselves.
+	ErrFederatedLinkRequired = errors.New("sign in to link this identity provider")
+
+	// ErrFederatedIdentityInUse is returned when linking an identity that is
+	// already linked to another account.
+	ErrFederatedIdentityInUse = errors.New("identity is linked to another account")
+)
+
+// SSO errors
+var (
+	ErrInvalidSSOSignature  = errors.New("invalid SSO signature")
+	ErrSSOAssertionExpired  = errors.New("SSO assertion has expired")
+	ErrSSOAssertionReplayed = errors.New("SSO assertion has already been used")
+
+	// ErrLegacySSOMD5Disabled is returned for an MD5-signed assertion while
+	// the legacy signature is not accepted.
+	ErrLegacySSOMD5Disabled = errors.New("MD5 SSO signatures are disabled")
 )
 
 // Password errors
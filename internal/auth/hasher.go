@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// DefaultBcryptCost is the bcrypt cost used when none is configured.
+const DefaultBcryptCost = 12
+
+// Hasher hashes and verifies passwords with one algorithm.
+type Hasher interface {
+	// Algorithm returns the hash type this hasher produces, e.g. "bcrypt".
+	Algorithm() string
+
+	// Hash returns an encoded hash that records the algorithm and the
+	// parameters it was produced with.
+	Hash(password string) (string, error)
+
+	// Verify reports whether password matches an encoded hash produced by
+	// this algorithm.
+	Verify(password, hash string) (bool, error)
+
+	// NeedsRehash reports whether an encoded hash of this algorithm used
+	// weaker parameters than the hasher is configured with.
+	NeedsRehash(hash string) bool
+}
+
+// NewHasher returns the hasher selected by cfg.
+func NewHasher(cfg config.PasswordHashConfig) (Hasher, error) {
+	switch cfg.Algorithm {
+	case HashTypeBcrypt:
+		return NewBcryptHasher(cfg.BcryptCost)
+	case HashTypeArgon2id:
+		if cfg.Argon2Memory < 0 || cfg.Argon2Iterations < 0 || cfg.Argon2Parallelism < 0 || cfg.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return NewArgon2idHasher(Argon2idParams{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+			SaltLength:  argon2SaltLength,
+			KeyLength:   argon2KeyLength,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", cfg.Algorithm)
+	}
+}
+
+// BcryptHasher hashes passwords with bcrypt. Hashes use bcrypt's standard
+// "$2a$<cost>$..." encoding, which PHC-format parsers accept as-is.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a bcrypt hasher with the given cost.
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+func (h *BcryptHasher) Algorithm() string { return HashTypeBcrypt }
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(password, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, ErrInvalidHashFormat
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// Argon2idParams are the argon2id cost parameters (RFC 9106).
+type Argon2idParams struct {
+	// Memory is the memory cost in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded in PHC string
+// format: $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<hash>.
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher creates an argon2id hasher with the given parameters.
+func NewArgon2idHasher(params Argon2idParams) (*Argon2idHasher, error) {
+	if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d",
+			params.Memory, params.Iterations, params.Parallelism)
+	}
+	if params.SaltLength < 8 || params.KeyLength < 16 {
+		return nil, fmt.Errorf("argon2id salt and key must be at least 8 and 16 bytes")
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+func (h *Argon2idHasher) Algorithm() string { return HashTypeArgon2id }
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, hash string) (bool, error) {
+	p, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	p, _, _, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return p.Memory < h.params.Memory ||
+		p.Iterations < h.params.Iterations ||
+		p.SaltLength < h.params.SaltLength ||
+		p.KeyLength < h.params.KeyLength
+}
+
+// decodeArgon2idHash parses a PHC-format argon2id hash.
+func decodeArgon2idHash(hash string) (Argon2idParams, []byte, []byte, error) {
+	var p Argon2idParams
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != HashTypeArgon2id {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+	if p.Iterations < 1 || p.Parallelism < 1 {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrInvalidHashFormat
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
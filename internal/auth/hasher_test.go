@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// testArgon2Params keeps tests fast; production uses much larger costs.
+var testArgon2Params = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher, err := NewArgon2idHasher(testArgon2Params)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	hash, err := hasher.Hash("securePassword123")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("expected PHC-formatted hash, got %s", hash)
+	}
+
+	if valid, err := hasher.Verify("securePassword123", hash); err != nil || !valid {
+		t.Fatalf("expected password to be valid, got %v, %v", valid, err)
+	}
+	if valid, _ := hasher.Verify("wrongPassword123", hash); valid {
+		t.Fatal("expected wrong password to be invalid")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Fatal("expected hash with current parameters not to need rehash")
+	}
+
+	t.Run("weaker parameters", func(t *testing.T) {
+		stronger := testArgon2Params
+		stronger.Iterations = 2
+		policy, _ := NewArgon2idHasher(stronger)
+		if !policy.NeedsRehash(hash) {
+			t.Fatal("expected hash with fewer iterations to need rehash")
+		}
+	})
+
+	t.Run("malformed hash", func(t *testing.T) {
+		for _, bad := range []string{
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+			"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+			"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5",
+			"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		} {
+			if _, err := hasher.Verify("securePassword123", bad); err != ErrInvalidHashFormat {
+				t.Fatalf("expected ErrInvalidHashFormat for %s, got %v", bad, err)
+			}
+		}
+	})
+}
+
+func TestBcryptHasherNeedsRehash(t *testing.T) {
+	weak, _ := NewBcryptHasher(4)
+	hash, _ := weak.Hash("securePassword123")
+
+	if weak.NeedsRehash(hash) {
+		t.Fatal("expected hash at current cost not to need rehash")
+	}
+
+	policy, _ := NewBcryptHasher(5)
+	if !policy.NeedsRehash(hash) {
+		t.Fatal("expected hash below current cost to need rehash")
+	}
+
+	if _, err := NewBcryptHasher(40); err == nil {
+		t.Fatal("expected out-of-range cost to be rejected")
+	}
+}
+
+func TestPasswordServiceMigration(t *testing.T) {
+	bcryptLow, _ := NewBcryptHasher(4)
+	bcryptHigh, _ := NewBcryptHasher(5)
+	argon, _ := NewArgon2idHasher(testArgon2Params)
+
+	lowHash, _ := bcryptLow.Hash("securePassword123")
+	argonHash, _ := argon.Hash("securePassword123")
+
+	tests := []struct {
+		name      string
+		policy    Hasher
+		hash      string
+		migration bool
+	}{
+		{"bcrypt at policy cost", bcryptLow, lowHash, false},
+		{"bcrypt cost upgrade", bcryptHigh, lowHash, true},
+		{"bcrypt to argon2id", argon, lowHash, true},
+		{"argon2id at policy", argon, argonHash, false},
+		{"argon2id to bcrypt", bcryptLow, argonHash, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewPasswordServiceWithHasher(tt.policy, false)
+
+			valid, needsMigration := svc.CheckPassword("securePassword123", tt.hash)
+			if !valid {
+				t.Fatal("expected password to be valid")
+			}
+			if needsMigration != tt.migration {
+				t.Fatalf("expected needsMigration %v, got %v", tt.migration, needsMigration)
+			}
+
+			if tt.migration {
+				newHash, err := svc.MigratePasswordHash("securePassword123")
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if svc.DetectHashType(newHash) != tt.policy.Algorithm() {
+					t.Fatalf("expected %s hash, got %s", tt.policy.Algorithm(), newHash)
+				}
+				if _, needsMigration := svc.CheckPassword("securePassword123", newHash); needsMigration {
+					t.Fatal("expected migrated hash not to need migration")
+				}
+			}
+		})
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	hasher, err := NewHasher(config.PasswordHashConfig{
+		Algorithm:         "argon2id",
+		Argon2Memory:      64,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if hasher.Algorithm() != HashTypeArgon2id {
+		t.Fatalf("expected argon2id, got %s", hasher.Algorithm())
+	}
+
+	if _, err := NewHasher(config.PasswordHashConfig{Algorithm: "scrypt"}); err == nil {
+		t.Fatal("expected unsupported algorithm to be rejected")
+	}
+	if _, err := NewHasher(config.PasswordHashConfig{Algorithm: "argon2id", Argon2Parallelism: 1}); err == nil {
+		t.Fatal("expected zero argon2id cost to be rejected")
+	}
+}
@@ -2,11 +2,13 @@ package auth
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
 )
 
 var (
@@ -23,37 +25,144 @@ type JWTClaims struct {
 	Email     string          `json:"email"`
 	Role      models.UserRole `json:"role"`
 	SessionID string          `json:"session_id,omitempty"`
+
+	// AuthMethods lists how the user authenticated (RFC 8176 amr values).
+	AuthMethods []string `json:"amr,omitempty"`
+
+	// ClientID and Scope are set on tokens issued to OAuth clients. Scope is
+	// a space-separated list, as in RFC 9068. Tokens a machine client obtains
+	// for itself have no UserID.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// IsClientToken reports whether the token was issued to a machine client
+// acting on its own behalf rather than for a user.
+func (c *JWTClaims) IsClientToken() bool {
+	return c.ClientID != "" && c.UserID == ""
+}
+
+// HasScope reports whether the token was granted scope.
+func (c *JWTClaims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// idTokenType is the typ header of ID tokens, which identify a user to a
+// client and must never be accepted as access tokens.
+const idTokenType = "id_token+jwt"
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. The audience
+// is the client the token was issued to.
+type IDTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthTime    *jwt.NumericDate `json:"auth_time,omitempty"`
+	Nonce       string           `json:"nonce,omitempty"`
+	AuthMethods []string         `json:"amr,omitempty"`
+	SessionID   string           `json:"sid,omitempty"`
+
+	// Profile and email claims, included when the matching scope was granted
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	GivenName     string `json:"given_name,omitempty"`
+	FamilyName    string `json:"family_name,omitempty"`
 }
 
 // JWTClaimsV1 represents the legacy JWT claims format.
 // Deprecated: Use JWTClaims instead.
 type JWTClaimsV1 struct {
 	jwt.RegisteredClaims
-	UserID string `json:"uid"`
-	Email  string `json:"email"`
+	UserID    string `json:"uid"`
+	Email     string `json:"email"`
+	SessionID string `json:"session_id,omitempty"`
+
+	// ClientID is only read so that tokens issued to OAuth clients, which
+	// the v1 API does not accept, can be rejected.
+	ClientID string `json:"client_id,omitempty"`
 	// TODO(TEAM-SEC): Remove legacy claims after migration
 }
 
 // JWTService handles JWT token generation and validation.
 type JWTService struct {
-	secret     []byte
+	keys       *KeyRing
 	expiration time.Duration
 	issuer     string
 	logger     *logging.LoggerV2
 }
 
-// NewJWTService creates a new JWT service.
+// NewJWTService creates a new JWT service that signs with an HS256 shared secret.
+// Deprecated: Use NewJWTServiceWithKeys with an asymmetric key ring.
 func NewJWTService(secret string, expiration time.Duration) *JWTService {
+	keys, _ := NewKeyRing(NewHMACSigningKey(legacyHMACKeyID, secret))
+	return NewJWTServiceWithKeys(keys, expiration, "acme-users-service")
+}
+
+// NewJWTServiceWithKeys creates a JWT service that signs with the key ring's
+// active key and verifies with any key in the ring.
+func NewJWTServiceWithKeys(keys *KeyRing, expiration time.Duration, issuer string) *JWTService {
 	return &JWTService{
-		secret:     []byte(secret),
+		keys:       keys,
 		expiration: expiration,
-		issuer:     "acme-users-service",
+		issuer:     issuer,
 		logger:     logging.NewLoggerV2("jwt-service"),
 	}
 }
 
 // GenerateToken generates a new JWT token for a user.
 func (s *JWTService) GenerateToken(user *models.User, sessionID string) (string, error) {
+	return s.generate(user, sessionID, nil)
+}
+
+// GenerateSessionToken generates a JWT token for a session, carrying the
+// session's authentication methods in the amr claim and, for OAuth client
+// sessions, the client and its scopes.
+func (s *JWTService) GenerateSessionToken(user *models.User, session *Session) (string, error) {
+	return s.generate(user, session.ID, session.AuthMethods, func(claims *JWTClaims) {
+		claims.ClientID = session.ClientID
+		claims.Scope = strings.Join(session.Scopes, " ")
+	})
+}
+
+// GenerateClientToken generates a JWT token for a machine client acting on
+// its own behalf. The client is the subject and the token carries no user.
+func (s *JWTService) GenerateClientToken(clientID string, scopes []string) (string, error) {
+	now := time.Now()
+	claims := &JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ids.NewTokenID(),
+			Issuer:    s.issuer,
+			Subject:   clientID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiration)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		ClientID: clientID,
+		Scope:    strings.Join(scopes, " "),
+	}
+
+	signedToken, err := s.sign(claims)
+	if err != nil {
+		s.logger.Error("failed to sign client token", logging.Fields{
+			"client_id": clientID,
+			"error":     err.Error(),
+		})
+		return "", err
+	}
+
+	return signedToken, nil
+}
+
+// Expiration returns how long issued access tokens stay valid.
+func (s *JWTService) Expiration() time.Duration {
+	return s.expiration
+}
+
+func (s *JWTService) generate(user *models.User, sessionID string, authMethods []string, opts ...func(*JWTClaims)) (string, error) {
 	s.logger.Debug("generating JWT token", logging.Fields{
 		"user_id":    user.ID,
 		"session_id": sessionID,
@@ -62,20 +171,24 @@ func (s *JWTService) GenerateToken(user *models.User, sessionID string) (string,
 	now := time.Now()
 	claims := &JWTClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ids.NewTokenID(),
 			Issuer:    s.issuer,
 			Subject:   user.ID,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiration)),
 			NotBefore: jwt.NewNumericDate(now),
 		},
-		UserID:    user.ID,
-		Email:     user.Email,
-		Role:      user.Role,
-		SessionID: sessionID,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        user.Role,
+		SessionID:   sessionID,
+		AuthMethods: authMethods,
+	}
+	for _, opt := range opts {
+		opt(claims)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(s.secret)
+	signedToken, err := s.sign(claims)
 	if err != nil {
 		s.logger.Error("failed to sign JWT token", logging.Fields{
 			"error": err.Error(),
@@ -95,12 +208,9 @@ func (s *JWTService) GenerateToken(user *models.User, sessionID string) (string,
 func (s *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	s.logger.Debug("validating JWT token")
 
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return s.secret, nil
-	})
+	// Tokens minted by another service sharing the signing keys carry its
+	// issuer and are not ours to accept
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, s.keyFunc, jwt.WithIssuer(s.issuer))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -117,6 +227,12 @@ func (s *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 		return nil, ErrInvalidClaims
 	}
 
+	// Access tokens have no audience; ID tokens are addressed to a client
+	if isIDToken(token) || len(claims.Audience) > 0 {
+		s.logger.Warn("ID token presented as access token")
+		return nil, ErrInvalidToken
+	}
+
 	s.logger.Debug("JWT token validated", logging.Fields{
 		"user_id": claims.UserID,
 	})
@@ -128,22 +244,31 @@ func (s *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 // Deprecated: Use GenerateToken instead.
 // TODO(TEAM-API): Remove after v1 API deprecation
 func (s *JWTService) GenerateTokenV1(userID, email string) (string, error) {
+	return s.GenerateSessionTokenV1(userID, email, "")
+}
+
+// GenerateSessionTokenV1 generates a legacy JWT token bound to a session, so
+// that logging out revokes it.
+// Deprecated: Use GenerateSessionToken instead.
+// TODO(TEAM-API): Remove after v1 API deprecation
+func (s *JWTService) GenerateSessionTokenV1(userID, email, sessionID string) (string, error) {
 	logging.Infof("generating legacy JWT token for user: %s", userID)
 
 	now := time.Now()
 	claims := &JWTClaimsV1{
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        ids.NewTokenID(),
 			Issuer:    s.issuer,
 			Subject:   userID,
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.expiration)),
 		},
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+	return s.sign(claims)
 }
 
 // ValidateTokenV1 validates a legacy JWT token.
@@ -152,9 +277,7 @@ func (s *JWTService) GenerateTokenV1(userID, email string) (string, error) {
 func (s *JWTService) ValidateTokenV1(tokenString string) (*JWTClaimsV1, error) {
 	logging.Infof("validating legacy JWT token")
 
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaimsV1{}, func(token *jwt.Token) (interface{}, error) {
-		return s.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaimsV1{}, s.keyFunc)
 
 	if err != nil {
 		return nil, ErrInvalidToken
@@ -165,23 +288,93 @@ func (s *JWTService) ValidateTokenV1(tokenString string) (*JWTClaimsV1, error) {
 		return nil, ErrInvalidClaims
 	}
 
+	// Only legacy user tokens are accepted. ID tokens, v2 tokens and tokens
+	// issued to OAuth clients share the signing keys but not the uid claim.
+	if isIDToken(token) || len(claims.Audience) > 0 || claims.ClientID != "" || claims.UserID == "" {
+		return nil, ErrInvalidToken
+	}
+
 	return claims, nil
 }
 
-// RefreshToken refreshes an existing token with a new expiration.
+// RefreshToken re-signs a still-valid token with a new expiration.
+// Expired tokens are rejected; clients must use their refresh token instead.
+// Deprecated: Use RefreshTokenService to rotate opaque refresh tokens.
 func (s *JWTService) RefreshToken(tokenString string) (string, error) {
 	claims, err := s.ValidateToken(tokenString)
-	if err != nil && err != ErrExpiredToken {
+	if err != nil {
 		return "", err
 	}
 
-	// Create a new token with refreshed expiration
+	// Create a new token with refreshed expiration and its own ID, so it can
+	// be revoked separately
 	now := time.Now()
+	claims.ID = ids.NewTokenID()
 	claims.IssuedAt = jwt.NewNumericDate(now)
 	claims.ExpiresAt = jwt.NewNumericDate(now.Add(s.expiration))
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secret)
+	return s.sign(claims)
+}
+
+// GenerateIDToken signs an OpenID Connect ID token. The caller sets every
+// claim, including the issuer, which must match the provider's discovery
+// document rather than the access token issuer.
+func (s *JWTService) GenerateIDToken(claims *IDTokenClaims) (string, error) {
+	return s.signWithType(claims, idTokenType)
+}
+
+// SigningAlgorithm returns the JWS algorithm of the active signing key.
+func (s *JWTService) SigningAlgorithm() string {
+	return s.keys.Active().Method.Alg()
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set.
+func (s *JWTService) JWKS() JWKSet {
+	return s.keys.JWKS()
+}
+
+// sign signs claims with the active key and sets the kid header.
+func (s *JWTService) sign(claims jwt.Claims) (string, error) {
+	return s.signWithType(claims, "")
+}
+
+// signWithType signs claims like sign, setting the typ header if typ is
+// not empty.
+func (s *JWTService) signWithType(claims jwt.Claims, typ string) (string, error) {
+	key := s.keys.Active()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != legacyHMACKeyID {
+		token.Header["kid"] = key.ID
+	}
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+
+	return token.SignedString(key.signer)
+}
+
+func isIDToken(token *jwt.Token) bool {
+	typ, _ := token.Header["typ"].(string)
+	return strings.EqualFold(typ, idTokenType)
+}
+
+// keyFunc selects the verification key named by the token's kid header and
+// rejects tokens whose algorithm does not match that key.
+func (s *JWTService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := s.keys.Lookup(kid)
+	if !ok {
+		s.logger.Warn("token signed with unknown key", logging.Fields{"kid": kid})
+		return nil, ErrInvalidToken
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+
+	return key.verifier, nil
 }
 
 // ExtractUserID extracts the user ID from a token without full validation.
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
 )
 
@@ -48,6 +49,20 @@ func TestJWTService(t *testing.T) {
 		}
 	})
 
+	t.Run("token from another issuer", func(t *testing.T) {
+		keys, _ := NewKeyRing(NewHMACSigningKey(legacyHMACKeyID, "test-secret-key"))
+		other := NewJWTServiceWithKeys(keys, time.Hour, "acme-orders-service")
+
+		token, err := other.GenerateToken(testUser, "session-123")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if _, err := svc.ValidateToken(token); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
 	t.Run("extract user ID", func(t *testing.T) {
 		token, _ := svc.GenerateToken(testUser, "session-123")
 
@@ -127,3 +142,25 @@ func TestRefreshToken(t *testing.T) {
 		}
 	})
 }
+
+func TestIDTokensAreNotAccessTokens(t *testing.T) {
+	svc := NewJWTService("test-secret-key", time.Hour)
+
+	idToken, err := svc.GenerateIDToken(&IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-123",
+			Audience:  jwt.ClaimStrings{"client-app"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := svc.ValidateToken(idToken); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if _, err := svc.ValidateTokenV1(idToken); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+}
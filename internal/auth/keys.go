@@ -0,0 +1,383 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// legacyHMACKeyID is the key ID of the shared-secret key. Tokens issued before
+// key rotation carry no kid header and resolve to this key.
+const legacyHMACKeyID = ""
+
+// SigningKey is a JWT signing or verification key.
+type SigningKey struct {
+	// ID is published as the kid header and in the JWKS document.
+	ID string
+
+	// Method is the JWS algorithm this key is used with.
+	Method jwt.SigningMethod
+
+	// signer is the private key (or HMAC secret). Nil for verify-only keys.
+	signer interface{}
+
+	// verifier is the public key (or HMAC secret).
+	verifier interface{}
+}
+
+// CanSign reports whether the key holds private material.
+func (k *SigningKey) CanSign() bool {
+	return k.signer != nil
+}
+
+// NewHMACSigningKey creates an HS256 key from a shared secret.
+// Deprecated: Shared secrets must be distributed to every verifier. Use an
+// asymmetric key instead.
+func NewHMACSigningKey(id, secret string) *SigningKey {
+	return &SigningKey{
+		ID:       id,
+		Method:   jwt.SigningMethodHS256,
+		signer:   []byte(secret),
+		verifier: []byte(secret),
+	}
+}
+
+// NewSigningKey creates a key from a private or public key. The algorithm is
+// derived from the key type: RSA keys use RS256, P-256/P-384 keys use
+// ES256/ES384 and Ed25519 keys use EdDSA.
+func NewSigningKey(id string, key interface{}) (*SigningKey, error) {
+	k := &SigningKey{ID: id}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.Method, k.signer, k.verifier = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Method, k.verifier = jwt.SigningMethodRS256, key
+	case *ecdsa.PrivateKey:
+		method, err := ecdsaMethod(key.Curve)
+		if err != nil {
+			return nil, err
+		}
+		k.Method, k.signer, k.verifier = method, key, &key.PublicKey
+	case *ecdsa.PublicKey:
+		method, err := ecdsaMethod(key.Curve)
+		if err != nil {
+			return nil, err
+		}
+		k.Method, k.verifier = method, key
+	case ed25519.PrivateKey:
+		k.Method, k.signer, k.verifier = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Method, k.verifier = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	return k, nil
+}
+
+// LoadSigningKey reads a PEM-encoded private or public key from disk.
+// Public-only keys can verify tokens but never sign them.
+func LoadSigningKey(id, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found in %s", id, path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block type %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	return NewSigningKey(id, key)
+}
+
+func ecdsaMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	default:
+		return nil, fmt.Errorf("unsupported elliptic curve %s", curve.Params().Name)
+	}
+}
+
+// KeyRing holds the active signing key plus retiring keys that are still
+// accepted for verification, so keys can be rotated without invalidating
+// tokens that are already in circulation.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyRing creates a key ring that signs with active and also verifies
+// tokens signed by any of the retiring keys.
+func NewKeyRing(active *SigningKey, retiring ...*SigningKey) (*KeyRing, error) {
+	if active == nil || !active.CanSign() {
+		return nil, errors.New("active key must hold private key material")
+	}
+
+	r := &KeyRing{
+		active: active,
+		keys:   map[string]*SigningKey{active.ID: active},
+	}
+	for _, k := range retiring {
+		if _, exists := r.keys[k.ID]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		r.keys[k.ID] = k
+	}
+
+	return r, nil
+}
+
+// LoadKeyRing builds a key ring from configuration. When no asymmetric keys
+// are configured, tokens are signed with the legacy HS256 shared secret. An
+// empty or published default secret is never loaded, since anyone could
+// sign tokens with it.
+func LoadKeyRing(cfg config.JWTConfig) (*KeyRing, error) {
+	if len(cfg.SigningKeys) == 0 {
+		if config.InsecureSecret(cfg.Secret) {
+			return nil, fmt.Errorf("JWT_SECRET is empty or a published default")
+		}
+		return NewKeyRing(NewHMACSigningKey(legacyHMACKeyID, cfg.Secret))
+	}
+
+	var active *SigningKey
+	var retiring []*SigningKey
+
+	// Load in a stable order so configuration errors are reproducible
+	ids := make([]string, 0, len(cfg.SigningKeys))
+	for id := range cfg.SigningKeys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		key, err := LoadSigningKey(id, cfg.SigningKeys[id])
+		if err != nil {
+			return nil, err
+		}
+		if id == cfg.ActiveKeyID {
+			active = key
+		} else {
+			retiring = append(retiring, key)
+		}
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("active key %q is not among the configured signing keys", cfg.ActiveKeyID)
+	}
+
+	// Keep accepting HS256 tokens issued before the switch to asymmetric keys
+	// TODO(TEAM-SEC): Disable once all pre-rotation tokens have expired
+	if cfg.AcceptLegacyHMAC && !config.InsecureSecret(cfg.Secret) {
+		retiring = append(retiring, NewHMACSigningKey(legacyHMACKeyID, cfg.Secret))
+	}
+
+	return NewKeyRing(active, retiring...)
+}
+
+// Active returns the key used to sign new tokens.
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Lookup returns the key with the given ID.
+func (r *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	k, ok := r.keys[kid]
+	return k, ok
+}
+
+// Rotate makes next the active key. The previous active key keeps verifying
+// tokens until it is retired.
+func (r *KeyRing) Rotate(next *SigningKey) error {
+	if next == nil || !next.CanSign() {
+		return errors.New("active key must hold private key material")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.keys[next.ID]; ok && existing != next {
+		return fmt.Errorf("duplicate key ID %q", next.ID)
+	}
+	r.keys[next.ID] = next
+	r.active = next
+	return nil
+}
+
+// Retire removes a non-active key. Tokens signed with it stop validating.
+func (r *KeyRing) Retire(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active.ID == kid {
+		return errors.New("cannot retire the active key")
+	}
+	delete(r.keys, kid)
+	return nil
+}
+
+// JWKS returns the public keys in the ring as a JSON Web Key Set.
+// Shared-secret keys are never published.
+func (r *KeyRing) JWKS() JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range r.keys {
+		if jwk, ok := k.publicJWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+
+	return set
+}
+
+// JWKSet is a JSON Web Key Set (RFC 7517).
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key (RFC 7517, RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+func (k *SigningKey) publicJWK() (JWK, bool) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+
+	switch pub := k.verifier.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+// PublicKey decodes a public JWK, such as one published by an external
+// identity provider, into an RSA, ECDSA or Ed25519 public key.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := unb64(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := unb64(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > math.MaxInt32 {
+			return nil, fmt.Errorf("key %q: unsupported RSA key", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("key %q: unsupported curve %q", k.KeyID, k.Curve)
+		}
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := unb64(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("key %q: point is not on curve %s", k.KeyID, k.Curve)
+		}
+		return pub, nil
+	case "OKP":
+		x, err := unb64(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if k.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %q: unsupported OKP key", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %q", k.KeyID, k.KeyType)
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
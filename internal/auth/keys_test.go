@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func mustSigningKey(t *testing.T, id string, key interface{}) *SigningKey {
+	t.Helper()
+	k, err := NewSigningKey(id, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return k
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	testUser := &models.User{ID: "user-123", Email: "test@example.com", Role: models.RoleCustomer}
+
+	tests := []struct {
+		name string
+		key  interface{}
+		alg  string
+	}{
+		{"rsa", rsaKey, "RS256"},
+		{"ecdsa", ecKey, "ES256"},
+		{"ed25519", edKey, "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := mustSigningKey(t, "key-"+tt.name, tt.key)
+			ring, err := NewKeyRing(key)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			svc := NewJWTServiceWithKeys(ring, time.Hour, "test-issuer")
+
+			token, err := svc.GenerateToken(testUser, "session-123")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			parsed, _, _ := new(jwt.Parser).ParseUnverified(token, &JWTClaims{})
+			if parsed.Header["kid"] != key.ID {
+				t.Fatalf("expected kid %s, got %v", key.ID, parsed.Header["kid"])
+			}
+			if parsed.Method.Alg() != tt.alg {
+				t.Fatalf("expected alg %s, got %s", tt.alg, parsed.Method.Alg())
+			}
+
+			claims, err := svc.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if claims.UserID != testUser.ID {
+				t.Fatalf("expected user ID %s, got %s", testUser.ID, claims.UserID)
+			}
+
+			jwks := svc.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != key.ID || jwks.Keys[0].Algorithm != tt.alg {
+				t.Fatalf("unexpected JWKS %+v", jwks)
+			}
+
+			pub, err := jwks.Keys[0].PublicKey()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.verifier) {
+				t.Fatal("expected JWK to decode to the signing key's public key")
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	ring, _ := NewKeyRing(mustSigningKey(t, "2024-01", oldKey))
+	svc := NewJWTServiceWithKeys(ring, time.Hour, "test-issuer")
+
+	testUser := &models.User{ID: "user-123", Email: "test@example.com", Role: models.RoleCustomer}
+	oldToken, _ := svc.GenerateToken(testUser, "session-123")
+
+	if err := ring.Rotate(mustSigningKey(t, "2024-06", newKey)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("old token still valid after rotation", func(t *testing.T) {
+		if _, err := svc.ValidateToken(oldToken); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("new tokens use new key", func(t *testing.T) {
+		token, _ := svc.GenerateToken(testUser, "session-123")
+		parsed, _, _ := new(jwt.Parser).ParseUnverified(token, &JWTClaims{})
+		if parsed.Header["kid"] != "2024-06" {
+			t.Fatalf("expected kid 2024-06, got %v", parsed.Header["kid"])
+		}
+	})
+
+	t.Run("both keys published", func(t *testing.T) {
+		if n := len(svc.JWKS().Keys); n != 2 {
+			t.Fatalf("expected 2 published keys, got %d", n)
+		}
+	})
+
+	t.Run("retired key rejected", func(t *testing.T) {
+		if err := ring.Retire("2024-01"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := svc.ValidateToken(oldToken); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("active key cannot be retired", func(t *testing.T) {
+		if err := ring.Retire("2024-06"); err == nil {
+			t.Fatal("expected error retiring active key")
+		}
+	})
+}
+
+func TestLegacyHMACTokens(t *testing.T) {
+	legacy := NewJWTService("test-secret-key", time.Hour)
+	testUser := &models.User{ID: "user-123", Email: "test@example.com", Role: models.RoleCustomer}
+	legacyToken, _ := legacy.GenerateToken(testUser, "session-123")
+
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	keyPath := filepath.Join(dir, "2024-06.pem")
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+
+	cfg := config.JWTConfig{
+		Secret:      "test-secret-key",
+		SigningKeys: map[string]string{"2024-06": keyPath},
+		ActiveKeyID: "2024-06",
+	}
+
+	t.Run("accepted when enabled", func(t *testing.T) {
+		cfg.AcceptLegacyHMAC = true
+		ring, err := LoadKeyRing(cfg)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		svc := NewJWTServiceWithKeys(ring, time.Hour, "acme-users-service")
+
+		if _, err := svc.ValidateToken(legacyToken); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if n := len(svc.JWKS().Keys); n != 1 {
+			t.Fatalf("expected shared secret to stay unpublished, got %d keys", n)
+		}
+	})
+
+	t.Run("rejected when disabled", func(t *testing.T) {
+		cfg.AcceptLegacyHMAC = false
+		ring, _ := LoadKeyRing(cfg)
+		svc := NewJWTServiceWithKeys(ring, time.Hour, "acme-users-service")
+
+		if _, err := svc.ValidateToken(legacyToken); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("published default secret never loaded", func(t *testing.T) {
+		forged, _ := NewJWTService("acme-secret-key", time.Hour).GenerateToken(&models.User{ID: "user-1", Role: models.RoleAdmin}, "")
+
+		cfg.Secret = "acme-secret-key"
+		cfg.AcceptLegacyHMAC = true
+		ring, err := LoadKeyRing(cfg)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		svc := NewJWTServiceWithKeys(ring, time.Hour, "acme-users-service")
+
+		if _, err := svc.ValidateToken(forged); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+}
+
+func TestLoadKeyRingRequiresPrivateSecret(t *testing.T) {
+	for _, secret := range []string{"", "acme-secret-key"} {
+		if _, err := LoadKeyRing(config.JWTConfig{Secret: secret}); err == nil {
+			t.Fatalf("expected an error for secret %q", secret)
+		}
+	}
+}
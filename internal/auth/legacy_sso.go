@@ -1,27 +1,284 @@
 package auth
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
 )
 
-// TODO(TEAM-SEC): URGENT - Remove this MD5 usage after SSO migration completes
-// This is a temporary hotfix for legacy SSO provider that requires MD5 signatures
-// Ticket: SEC-999
-// Deadline: 2024-12-01
-
-// LegacySSOSignature generates an MD5 signature for the legacy SSO provider.
-// DEPRECATED: This uses insecure MD5 hashing and must be removed.
-func LegacySSOSignature(payload string, secret string) string {
-	// WARNING: MD5 is cryptographically broken - temporary hotfix only
-	data := payload + secret
-	hash := md5.Sum([]byte(data))
+const (
+	// SSOVersionV1 signs assertions with HMAC-SHA256.
+	SSOVersionV1 = "v1"
+
+	// SSOVersionMD5 is the unversioned md5(payload+secret) signature of the
+	// legacy SSO provider. It can only be verified, never issued.
+	// Deprecated: MD5 is cryptographically broken. Use SSOVersionV1.
+	// TODO(TEAM-SEC): Remove once SSO verifications report no md5 calls
+	SSOVersionMD5 = "md5"
+
+	ssoNoncePrefix = "sso_nonce:"
+
+	// Nonces are random and at least 128 bits when hex encoded, and cannot
+	// contain the separator of the signed message.
+	minSSONonceLength = 32
+	maxSSONonceLength = 128
+)
+
+// SSOAssertion is a signed assertion from the SSO provider.
+//
+// A v1 signature is "v1=" followed by the hex HMAC-SHA256 of the version,
+// timestamp, nonce and payload joined by newlines, keyed with the shared
+// secret. A signature without a version prefix is a legacy MD5 signature
+// of the payload alone.
+type SSOAssertion struct {
+	Payload string `json:"payload"`
+
+	// Timestamp is when the assertion was signed, in Unix seconds.
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// SSONonceStore remembers the nonces of accepted assertions.
+type SSONonceStore interface {
+	// Use records a nonce for ttl. It returns false if the nonce has
+	// already been recorded and has not expired.
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// SSOVerifier verifies assertions from the SSO provider.
+//
+// v1 assertions are accepted once, within MaxAge of their timestamp in
+// either direction. Legacy MD5 signatures carry no timestamp or nonce and
+// are only accepted when acceptMD5 is set; every verification is counted
+// by version so the remaining MD5 callers can be found.
+type SSOVerifier struct {
+	secret    []byte
+	maxAge    time.Duration
+	nonces    SSONonceStore
+	acceptMD5 bool
+	logger    *logging.LoggerV2
+}
+
+// NewSSOVerifier creates a new SSO assertion verifier.
+func NewSSOVerifier(cfg config.LegacySSOConfig, nonces SSONonceStore, acceptMD5 bool) *SSOVerifier {
+	v := &SSOVerifier{
+		secret:    []byte(cfg.Secret),
+		maxAge:    cfg.MaxAge,
+		nonces:    nonces,
+		acceptMD5: acceptMD5,
+		logger:    logging.NewLoggerV2("sso-verifier"),
+	}
+
+	if acceptMD5 {
+		v.logger.Warn("legacy MD5 SSO signatures are enabled")
+	}
+
+	return v
+}
+
+// Verify checks an assertion's signature, and for v1 assertions its age and
+// nonce. It returns ErrInvalidSSOSignature, ErrSSOAssertionExpired,
+// ErrSSOAssertionReplayed or ErrLegacySSOMD5Disabled.
+func (v *SSOVerifier) Verify(ctx context.Context, a *SSOAssertion) error {
+	version, signature := splitSSOSignature(a.Signature)
+
+	var err error
+	switch version {
+	case SSOVersionV1:
+		err = v.verifyV1(ctx, a, signature)
+	case SSOVersionMD5:
+		err = v.verifyMD5(a, signature)
+	default:
+		version = "unknown"
+		err = ErrInvalidSSOSignature
+	}
+
+	metrics.SSOVerifications.WithLabelValues(version, ssoResult(err)).Inc()
+	return err
+}
+
+func (v *SSOVerifier) verifyV1(ctx context.Context, a *SSOAssertion, signature string) error {
+	if len(v.secret) == 0 || !validSSONonce(a.Nonce) {
+		return ErrInvalidSSOSignature
+	}
+
+	expected := ssoMAC(v.secret, a.Payload, a.Timestamp, a.Nonce)
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, expected) {
+		return ErrInvalidSSOSignature
+	}
+
+	age := time.Since(time.Unix(a.Timestamp, 0))
+	if age > v.maxAge || age < -v.maxAge {
+		return ErrSSOAssertionExpired
+	}
+
+	// The nonce must outlive every timestamp that would still be accepted
+	fresh, err := v.nonces.Use(ctx, a.Nonce, 2*v.maxAge)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		v.logger.Warn("replayed SSO assertion", logging.Fields{
+			"nonce": a.Nonce,
+		})
+		return ErrSSOAssertionReplayed
+	}
+
+	return nil
+}
+
+// verifyMD5 checks a legacy MD5 signature.
+// Deprecated: MD5 is cryptographically broken. Use SSOVersionV1.
+// TODO(TEAM-SEC): Remove once SSO verifications report no md5 calls
+func (v *SSOVerifier) verifyMD5(a *SSOAssertion, signature string) error {
+	if !v.acceptMD5 {
+		v.logger.Warn("MD5 SSO signature rejected because legacy SSO is disabled")
+		return ErrLegacySSOMD5Disabled
+	}
+	if len(v.secret) == 0 {
+		return ErrInvalidSSOSignature
+	}
+
+	v.logger.Warn("verifying legacy MD5 SSO signature")
+	expected := legacySSOSignature(a.Payload, string(v.secret))
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(signature)), []byte(expected)) != 1 {
+		return ErrInvalidSSOSignature
+	}
+	return nil
+}
+
+// SignSSOAssertion returns the v1 signature for an assertion. It is used by
+// first-party callers and tests; MD5 signatures cannot be created.
+func SignSSOAssertion(secret, payload string, timestamp int64, nonce string) string {
+	return SSOVersionV1 + "=" + hex.EncodeToString(ssoMAC([]byte(secret), payload, timestamp, nonce))
+}
+
+func ssoMAC(secret []byte, payload string, timestamp int64, nonce string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(SSOVersionV1 + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + nonce + "\n" + payload))
+	return mac.Sum(nil)
+}
+
+// legacySSOSignature computes the legacy provider's MD5 signature.
+// Deprecated: MD5 is cryptographically broken. Only used for verification.
+func legacySSOSignature(payload, secret string) string {
+	hash := md5.Sum([]byte(payload + secret))
 	return hex.EncodeToString(hash[:])
 }
 
-// ValidateLegacySSOToken validates a token from the legacy SSO provider.
-// TODO(TEAM-SEC): Replace with HMAC-SHA256 validation
-func ValidateLegacySSOToken(token, expectedSig, secret string) bool {
-	computed := LegacySSOSignature(token, secret)
-	return computed == expectedSig
+// splitSSOSignature separates a signature's version prefix. Signatures
+// without one are legacy MD5 signatures.
+func splitSSOSignature(signature string) (version, value string) {
+	version, value, ok := strings.Cut(signature, "=")
+	if !ok {
+		return SSOVersionMD5, signature
+	}
+	return version, value
+}
+
+// validSSONonce reports whether a nonce is long enough and contains only
+// unreserved URL characters.
+func validSSONonce(nonce string) bool {
+	if len(nonce) < minSSONonceLength || len(nonce) > maxSSONonceLength {
+		return false
+	}
+	for _, c := range nonce {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func ssoResult(err error) string {
+	switch err {
+	case nil:
+		return "valid"
+	case ErrSSOAssertionExpired:
+		return "expired"
+	case ErrSSOAssertionReplayed:
+		return "replayed"
+	case ErrLegacySSOMD5Disabled:
+		return "disabled"
+	case ErrInvalidSSOSignature:
+		return "invalid"
+	default:
+		return "error"
+	}
 }
+
+// RedisSSONonceStore records SSO nonces in Redis so that an assertion is
+// only accepted once across service instances.
+type RedisSSONonceStore struct {
+	client *redis.Client
+}
+
+// NewRedisSSONonceStore creates a new Redis-backed SSO nonce store.
+func NewRedisSSONonceStore(cfg config.RedisConfig) *RedisSSONonceStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisSSONonceStore{client: client}
+}
+
+func (s *RedisSSONonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, ssoNoncePrefix+nonce, 1, ttl).Result()
+}
+
+// InMemorySSONonceStore is an SSO nonce store for tests and single-node
+// development.
+type InMemorySSONonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewInMemorySSONonceStore() *InMemorySSONonceStore {
+	return &InMemorySSONonceStore{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (s *InMemorySSONonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := s.nonces[nonce]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+
+	// Drop expired nonces so the map does not grow without bound
+	for n, expiresAt := range s.nonces {
+		if !now.Before(expiresAt) {
+			delete(s.nonces, n)
+		}
+	}
+	return true, nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ SSONonceStore = (*RedisSSONonceStore)(nil)
+	_ SSONonceStore = (*InMemorySSONonceStore)(nil)
+)
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	testSSOSecret = "sso-test-secret"
+	testSSONonce  = "0123456789abcdef0123456789abcdef"
+)
+
+func newTestSSOVerifier(acceptMD5 bool) *SSOVerifier {
+	return NewSSOVerifier(config.LegacySSOConfig{
+		Secret: testSSOSecret,
+		MaxAge: 5 * time.Minute,
+	}, NewInMemorySSONonceStore(), acceptMD5)
+}
+
+func signedSSOAssertion(payload string, timestamp time.Time, nonce string) *SSOAssertion {
+	return &SSOAssertion{
+		Payload:   payload,
+		Timestamp: timestamp.Unix(),
+		Nonce:     nonce,
+		Signature: SignSSOAssertion(testSSOSecret, payload, timestamp.Unix(), nonce),
+	}
+}
+
+func TestSSOVerifierV1(t *testing.T) {
+	ctx := context.Background()
+	v := newTestSSOVerifier(false)
+
+	a := signedSSOAssertion(`{"user":"alice"}`, time.Now(), testSSONonce)
+	if !strings.HasPrefix(a.Signature, "v1=") {
+		t.Fatalf("expected v1 signature, got %q", a.Signature)
+	}
+	if err := v.Verify(ctx, a); err != nil {
+		t.Fatalf("expected valid assertion, got %v", err)
+	}
+
+	t.Run("replayed", func(t *testing.T) {
+		if err := v.Verify(ctx, a); err != ErrSSOAssertionReplayed {
+			t.Fatalf("expected ErrSSOAssertionReplayed, got %v", err)
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		tampered := signedSSOAssertion(`{"user":"alice"}`, time.Now(), strings.Repeat("b", 32))
+		tampered.Payload = `{"user":"admin"}`
+		if err := v.Verify(ctx, tampered); err != ErrInvalidSSOSignature {
+			t.Fatalf("expected ErrInvalidSSOSignature, got %v", err)
+		}
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		forged := signedSSOAssertion("payload", time.Now(), strings.Repeat("c", 32))
+		forged.Signature = SignSSOAssertion("other-secret", forged.Payload, forged.Timestamp, forged.Nonce)
+		if err := v.Verify(ctx, forged); err != ErrInvalidSSOSignature {
+			t.Fatalf("expected ErrInvalidSSOSignature, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		old := signedSSOAssertion("payload", time.Now().Add(-10*time.Minute), strings.Repeat("d", 32))
+		if err := v.Verify(ctx, old); err != ErrSSOAssertionExpired {
+			t.Fatalf("expected ErrSSOAssertionExpired, got %v", err)
+		}
+	})
+
+	t.Run("from the future", func(t *testing.T) {
+		future := signedSSOAssertion("payload", time.Now().Add(10*time.Minute), strings.Repeat("e", 32))
+		if err := v.Verify(ctx, future); err != ErrSSOAssertionExpired {
+			t.Fatalf("expected ErrSSOAssertionExpired, got %v", err)
+		}
+	})
+
+	t.Run("invalid nonce", func(t *testing.T) {
+		for _, nonce := range []string{"", "short", strings.Repeat("f", 31) + "\n"} {
+			a := signedSSOAssertion("payload", time.Now(), nonce)
+			if err := v.Verify(ctx, a); err != ErrInvalidSSOSignature {
+				t.Fatalf("expected ErrInvalidSSOSignature for nonce %q, got %v", nonce, err)
+			}
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		a := signedSSOAssertion("payload", time.Now(), strings.Repeat("g", 32))
+		a.Signature = "v2=" + strings.TrimPrefix(a.Signature, "v1=")
+		if err := v.Verify(ctx, a); err != ErrInvalidSSOSignature {
+			t.Fatalf("expected ErrInvalidSSOSignature, got %v", err)
+		}
+	})
+}
+
+func TestSSOVerifierLegacyMD5(t *testing.T) {
+	ctx := context.Background()
+	a := &SSOAssertion{
+		Payload:   "legacy-payload",
+		Signature: legacySSOSignature("legacy-payload", testSSOSecret),
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		if err := newTestSSOVerifier(false).Verify(ctx, a); err != ErrLegacySSOMD5Disabled {
+			t.Fatalf("expected ErrLegacySSOMD5Disabled, got %v", err)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		v := newTestSSOVerifier(true)
+		if err := v.Verify(ctx, a); err != nil {
+			t.Fatalf("expected valid assertion, got %v", err)
+		}
+
+		forged := *a
+		forged.Payload = "other-payload"
+		if err := v.Verify(ctx, &forged); err != ErrInvalidSSOSignature {
+			t.Fatalf("expected ErrInvalidSSOSignature, got %v", err)
+		}
+	})
+}
+
+func TestInMemorySSONonceStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySSONonceStore()
+
+	if fresh, _ := store.Use(ctx, "nonce", 10*time.Millisecond); !fresh {
+		t.Fatal("expected first use to be fresh")
+	}
+	if fresh, _ := store.Use(ctx, "nonce", 10*time.Millisecond); fresh {
+		t.Fatal("expected second use to be rejected")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if fresh, _ := store.Use(ctx, "nonce", 10*time.Millisecond); !fresh {
+		t.Fatal("expected nonce to be usable after it expires")
+	}
+}
@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	lockoutPrefix         = "login_lock:"
+	accountFailuresPrefix = "login_failures:account:"
+	ipFailuresPrefix      = "login_failures:ip:"
+	lockoutCountPrefix    = "login_lockouts:"
+)
+
+// AttemptStore holds the expiring counters and locks used to track failed
+// logins.
+type AttemptStore interface {
+	// Incr increments a counter and returns its new value. The counter
+	// expires window after its first increment.
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+
+	// Get returns the current value of a counter, or zero if it has expired.
+	Get(ctx context.Context, key string) (int64, error)
+
+	// Lock sets a lock that expires after d.
+	Lock(ctx context.Context, key string, d time.Duration) error
+
+	// LockTTL returns the time left on a lock, or zero if it is not held.
+	LockTTL(ctx context.Context, key string) (time.Duration, error)
+
+	// Delete removes counters and locks.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// LockoutService tracks failed logins per account and per client IP.
+//
+// An account is locked once it reaches MaxAccountFailures within
+// FailureWindow. Each consecutive lockout doubles the lock duration, starting
+// at LockoutDuration and capped at MaxLockoutDuration, and locks lift on their
+// own when they expire. Accounts are keyed by email rather than user ID so
+// that unknown addresses lock exactly like real ones and lockout responses
+// cannot be used to enumerate accounts.
+type LockoutService struct {
+	store  AttemptStore
+	cfg    config.LockoutConfig
+	logger *logging.LoggerV2
+}
+
+// NewLockoutService creates a new lockout service.
+func NewLockoutService(store AttemptStore, cfg config.LockoutConfig) *LockoutService {
+	return &LockoutService{
+		store:  store,
+		cfg:    cfg,
+		logger: logging.NewLoggerV2("lockout-service"),
+	}
+}
+
+// Check returns ErrAccountLocked if the account is locked and
+// ErrTooManyAttempts if the client IP has exceeded its failure budget.
+// An empty ipAddress skips the per-IP check.
+func (s *LockoutService) Check(ctx context.Context, email, ipAddress string) error {
+	ttl, err := s.store.LockTTL(ctx, lockoutPrefix+accountKey(email))
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return ErrAccountLocked
+	}
+
+	if ipAddress == "" || s.cfg.MaxIPFailures <= 0 {
+		return nil
+	}
+
+	failures, err := s.store.Get(ctx, ipFailuresPrefix+ipAddress)
+	if err != nil {
+		return err
+	}
+	if failures >= int64(s.cfg.MaxIPFailures) {
+		return ErrTooManyAttempts
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the account if it has
+// reached its threshold.
+func (s *LockoutService) RecordFailure(ctx context.Context, email, ipAddress string) error {
+	account := accountKey(email)
+
+	if ipAddress != "" {
+		if _, err := s.store.Incr(ctx, ipFailuresPrefix+ipAddress, s.cfg.FailureWindow); err != nil {
+			return err
+		}
+	}
+
+	failures, err := s.store.Incr(ctx, accountFailuresPrefix+account, s.cfg.FailureWindow)
+	if err != nil {
+		return err
+	}
+	if s.cfg.MaxAccountFailures <= 0 || failures < int64(s.cfg.MaxAccountFailures) {
+		return nil
+	}
+
+	// Remember previous lockouts for as long as the longest lock lasts, so
+	// repeat offenders back off progressively
+	lockouts, err := s.store.Incr(ctx, lockoutCountPrefix+account, s.cfg.MaxLockoutDuration)
+	if err != nil {
+		return err
+	}
+
+	duration := s.lockoutDuration(lockouts)
+	if err := s.store.Lock(ctx, lockoutPrefix+account, duration); err != nil {
+		return err
+	}
+
+	// Start counting afresh once the lock lifts
+	if err := s.store.Delete(ctx, accountFailuresPrefix+account); err != nil {
+		return err
+	}
+
+	s.logger.Warn("account locked after repeated login failures", logging.Fields{
+		"email":      email,
+		"ip_address": ipAddress,
+		"lockouts":   lockouts,
+		"duration":   duration.String(),
+	})
+
+	return nil
+}
+
+// RecordSuccess clears the account's failure history after a successful
+// login. Per-IP counters are left to expire so that an attacker cannot reset
+// them by logging in to an account they control.
+func (s *LockoutService) RecordSuccess(ctx context.Context, email string) error {
+	account := accountKey(email)
+	return s.store.Delete(ctx, accountFailuresPrefix+account, lockoutCountPrefix+account)
+}
+
+// Unlock lifts a lock and clears the account's failure history.
+func (s *LockoutService) Unlock(ctx context.Context, email string) error {
+	account := accountKey(email)
+
+	s.logger.Info("unlocking account", logging.Fields{"email": email})
+
+	return s.store.Delete(ctx,
+		lockoutPrefix+account,
+		accountFailuresPrefix+account,
+		lockoutCountPrefix+account,
+	)
+}
+
+// lockoutDuration returns the lock duration for the nth consecutive lockout.
+func (s *LockoutService) lockoutDuration(n int64) time.Duration {
+	d := s.cfg.LockoutDuration
+	for i := int64(1); i < n && d < s.cfg.MaxLockoutDuration; i++ {
+		d *= 2
+	}
+	if s.cfg.MaxLockoutDuration > 0 && d > s.cfg.MaxLockoutDuration {
+		d = s.cfg.MaxLockoutDuration
+	}
+	return d
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RedisAttemptStore stores login attempt counters in Redis so that limits
+// hold across service instances.
+type RedisAttemptStore struct {
+	client *redis.Client
+}
+
+// NewRedisAttemptStore creates a new Redis-backed attempt store.
+func NewRedisAttemptStore(cfg config.RedisConfig) *RedisAttemptStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisAttemptStore{client: client}
+}
+
+// incrAttemptScript increments a failure counter and starts its window on
+// the first failure in one step, so a counter is never left without a TTL.
+// Later failures do not extend the window.
+var incrAttemptScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+func (s *RedisAttemptStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrAttemptScript.Run(ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+func (s *RedisAttemptStore) Get(ctx context.Context, key string) (int64, error) {
+	n, err := s.client.Get(ctx, key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (s *RedisAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	return s.client.Set(ctx, key, time.Now().Add(d).Unix(), d).Err()
+}
+
+func (s *RedisAttemptStore) LockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// Missing keys report -2 and keys without expiry -1
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+func (s *RedisAttemptStore) Delete(ctx context.Context, keys ...string) error {
+	return s.client.Del(ctx, keys...).Err()
+}
+
+// InMemoryAttemptStore is an attempt store for tests and single-node
+// development. It also serves as the fallback when Redis is unavailable.
+type InMemoryAttemptStore struct {
+	mu      sync.Mutex
+	entries map[string]attemptEntry
+}
+
+type attemptEntry struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func NewInMemoryAttemptStore() *InMemoryAttemptStore {
+	return &InMemoryAttemptStore{
+		entries: make(map[string]attemptEntry),
+	}
+}
+
+func (s *InMemoryAttemptStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.live(key)
+	if !ok {
+		entry = attemptEntry{expiresAt: time.Now().Add(window)}
+	}
+	entry.count++
+	s.entries[key] = entry
+
+	return entry.count, nil
+}
+
+func (s *InMemoryAttemptStore) Get(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, _ := s.live(key)
+	return entry.count, nil
+}
+
+func (s *InMemoryAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = attemptEntry{count: 1, expiresAt: time.Now().Add(d)}
+	return nil
+}
+
+func (s *InMemoryAttemptStore) LockTTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.live(key)
+	if !ok {
+		return 0, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+func (s *InMemoryAttemptStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// live returns an unexpired entry, evicting it if it has expired.
+// Callers must hold s.mu.
+func (s *InMemoryAttemptStore) live(key string) (attemptEntry, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return attemptEntry{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(s.entries, key)
+		return attemptEntry{}, false
+	}
+	return entry, true
+}
+
+// FallbackAttemptStore uses a primary store and switches to a fallback for
+// any call the primary fails, so an outage of the primary degrades lockout
+// to per-instance tracking instead of disabling it.
+type FallbackAttemptStore struct {
+	primary  AttemptStore
+	fallback AttemptStore
+	logger   *logging.LoggerV2
+}
+
+// NewFallbackAttemptStore creates a store that prefers primary.
+func NewFallbackAttemptStore(primary, fallback AttemptStore) *FallbackAttemptStore {
+	return &FallbackAttemptStore{
+		primary:  primary,
+		fallback: fallback,
+		logger:   logging.NewLoggerV2("attempt-store"),
+	}
+}
+
+func (s *FallbackAttemptStore) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	n, err := s.primary.Incr(ctx, key, window)
+	if err != nil {
+		s.warn("incr", err)
+		return s.fallback.Incr(ctx, key, window)
+	}
+	return n, nil
+}
+
+func (s *FallbackAttemptStore) Get(ctx context.Context, key string) (int64, error) {
+	n, err := s.primary.Get(ctx, key)
+	if err != nil {
+		s.warn("get", err)
+		return s.fallback.Get(ctx, key)
+	}
+	return n, nil
+}
+
+func (s *FallbackAttemptStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if err := s.primary.Lock(ctx, key, d); err != nil {
+		s.warn("lock", err)
+		return s.fallback.Lock(ctx, key, d)
+	}
+	return nil
+}
+
+func (s *FallbackAttemptStore) LockTTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.primary.LockTTL(ctx, key)
+	if err != nil {
+		s.warn("lock ttl", err)
+		return s.fallback.LockTTL(ctx, key)
+	}
+	return ttl, nil
+}
+
+func (s *FallbackAttemptStore) Delete(ctx context.Context, keys ...string) error {
+	// Always clear the fallback too, so state recorded during an outage
+	// does not outlive an unlock
+	fallbackErr := s.fallback.Delete(ctx, keys...)
+	if err := s.primary.Delete(ctx, keys...); err != nil {
+		s.warn("delete", err)
+		return fallbackErr
+	}
+	return nil
+}
+
+func (s *FallbackAttemptStore) warn(op string, err error) {
+	s.logger.Warn("attempt store unavailable, using fallback", logging.Fields{
+		"operation": op,
+		"error":     err.Error(),
+	})
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ AttemptStore = (*RedisAttemptStore)(nil)
+	_ AttemptStore = (*InMemoryAttemptStore)(nil)
+	_ AttemptStore = (*FallbackAttemptStore)(nil)
+)
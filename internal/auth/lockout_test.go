@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func newTestLockoutService(store AttemptStore) *LockoutService {
+	return NewLockoutService(store, config.LockoutConfig{
+		MaxAccountFailures: 3,
+		MaxIPFailures:      10,
+		FailureWindow:      time.Minute,
+		LockoutDuration:    time.Minute,
+		MaxLockoutDuration: 5 * time.Minute,
+	})
+}
+
+func TestAccountLockout(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryAttemptStore()
+	svc := newTestLockoutService(store)
+
+	for i := 0; i < 2; i++ {
+		svc.RecordFailure(ctx, "test@example.com", "10.0.0.1")
+	}
+	if err := svc.Check(ctx, "test@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("expected no error below threshold, got %v", err)
+	}
+
+	svc.RecordFailure(ctx, "Test@Example.com", "10.0.0.1")
+
+	t.Run("locked at threshold", func(t *testing.T) {
+		if err := svc.Check(ctx, "test@example.com", "10.0.0.2"); err != ErrAccountLocked {
+			t.Fatalf("expected ErrAccountLocked, got %v", err)
+		}
+	})
+
+	t.Run("other accounts unaffected", func(t *testing.T) {
+		if err := svc.Check(ctx, "other@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("admin unlock", func(t *testing.T) {
+		if err := svc.Unlock(ctx, "test@example.com"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := svc.Check(ctx, "test@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("expected no error after unlock, got %v", err)
+		}
+	})
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryAttemptStore()
+	svc := newTestLockoutService(store)
+	lockKey := lockoutPrefix + "test@example.com"
+
+	tests := []struct {
+		name     string
+		expected time.Duration
+	}{
+		{"first lockout", time.Minute},
+		{"second lockout doubles", 2 * time.Minute},
+		{"third lockout doubles", 4 * time.Minute},
+		{"capped at maximum", 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Simulate the previous lock expiring
+			store.Delete(ctx, lockKey)
+
+			for i := 0; i < 3; i++ {
+				svc.RecordFailure(ctx, "test@example.com", "")
+			}
+
+			ttl, _ := store.LockTTL(ctx, lockKey)
+			if ttl <= tt.expected-time.Second || ttl > tt.expected {
+				t.Fatalf("expected lock of %v, got %v", tt.expected, ttl)
+			}
+		})
+	}
+
+	t.Run("success resets backoff", func(t *testing.T) {
+		store.Delete(ctx, lockKey)
+		svc.RecordSuccess(ctx, "test@example.com")
+
+		for i := 0; i < 3; i++ {
+			svc.RecordFailure(ctx, "test@example.com", "")
+		}
+
+		ttl, _ := store.LockTTL(ctx, lockKey)
+		if ttl > time.Minute {
+			t.Fatalf("expected lock of 1m, got %v", ttl)
+		}
+	})
+}
+
+func TestLockoutExpiry(t *testing.T) {
+	ctx := context.Background()
+	svc := NewLockoutService(NewInMemoryAttemptStore(), config.LockoutConfig{
+		MaxAccountFailures: 1,
+		FailureWindow:      time.Minute,
+		LockoutDuration:    10 * time.Millisecond,
+		MaxLockoutDuration: time.Minute,
+	})
+
+	svc.RecordFailure(ctx, "test@example.com", "")
+	if err := svc.Check(ctx, "test@example.com", ""); err != ErrAccountLocked {
+		t.Fatalf("expected ErrAccountLocked, got %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if err := svc.Check(ctx, "test@example.com", ""); err != nil {
+		t.Fatalf("expected lock to expire, got %v", err)
+	}
+}
+
+func TestIPThrottling(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestLockoutService(NewInMemoryAttemptStore())
+
+	// Spread failures across accounts so none of them locks
+	for i := 0; i < 10; i++ {
+		svc.RecordFailure(ctx, string(rune('a'+i))+"@example.com", "10.0.0.1")
+	}
+
+	if err := svc.Check(ctx, "new@example.com", "10.0.0.1"); err != ErrTooManyAttempts {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if err := svc.Check(ctx, "new@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("expected other IPs to be unaffected, got %v", err)
+	}
+}
+
+type failingAttemptStore struct{ AttemptStore }
+
+func (failingAttemptStore) Incr(context.Context, string, time.Duration) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingAttemptStore) LockTTL(context.Context, string) (time.Duration, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingAttemptStore) Lock(context.Context, string, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (failingAttemptStore) Delete(context.Context, ...string) error {
+	return errors.New("connection refused")
+}
+
+func TestFallbackAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewFallbackAttemptStore(failingAttemptStore{}, NewInMemoryAttemptStore())
+	svc := newTestLockoutService(store)
+
+	for i := 0; i < 3; i++ {
+		if err := svc.RecordFailure(ctx, "test@example.com", ""); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	if err := svc.Check(ctx, "test@example.com", ""); err != ErrAccountLocked {
+		t.Fatalf("expected ErrAccountLocked from fallback store, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// Authentication method references (RFC 8176) recorded on sessions and in
+// the amr claim of issued tokens.
+const (
+	AuthMethodPassword    = "pwd"
+	AuthMethodOTP         = "otp"
+	AuthMethodHardwareKey = "hwk"
+	AuthMethodMFA         = "mfa"
+
+	// AuthMethodFederated is not registered in RFC 8176; it marks a
+	// sign-in through an external identity provider.
+	AuthMethodFederated = "fed"
+)
+
+const (
+	mfaChallengePrefix = "mfa_challenge:"
+
+	recoveryCodeCount = 10
+	recoveryCodeChars = 10
+	recoveryAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// HasAuthMethod reports whether methods contains method.
+func HasAuthMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateRecoveryCodes returns a fresh set of one-time recovery codes,
+// formatted for display as "xxxxx-xxxxx".
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	buf := make([]byte, recoveryCodeChars)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for j, v := range buf {
+			if j == recoveryCodeChars/2 {
+				b.WriteByte('-')
+			}
+			// The alphabet length is not a power of two; the slight bias
+			// is irrelevant at this length
+			b.WriteByte(recoveryAlphabet[int(v)%len(recoveryAlphabet)])
+		}
+		codes[i] = b.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips formatting so codes match however the user
+// types them.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// MFAChallenge is a pending login that passed the password step and is
+// waiting for a second factor.
+type MFAChallenge struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Attempts  int       `json:"attempts"`
+
+	// AuthMethods records the first factor the user already presented.
+	AuthMethods []string `json:"auth_methods"`
+}
+
+// MFAChallengeStore persists challenges keyed by token hash.
+type MFAChallengeStore interface {
+	Save(ctx context.Context, hash string, challenge *MFAChallenge) error
+
+	// Get returns ErrInvalidToken if no challenge exists.
+	Get(ctx context.Context, hash string) (*MFAChallenge, error)
+
+	// IncrAttempts records a failed verification and returns the new count.
+	IncrAttempts(ctx context.Context, hash string) (int, error)
+
+	Delete(ctx context.Context, hash string) error
+}
+
+// MFAChallengeService issues and resolves short-lived MFA challenge tokens.
+type MFAChallengeService struct {
+	store       MFAChallengeStore
+	ttl         time.Duration
+	maxAttempts int
+	logger      *logging.LoggerV2
+}
+
+// NewMFAChallengeService creates a new MFA challenge service.
+func NewMFAChallengeService(store MFAChallengeStore, cfg config.MFAConfig) *MFAChallengeService {
+	return &MFAChallengeService{
+		store:       store,
+		ttl:         cfg.ChallengeTTL,
+		maxAttempts: cfg.MaxAttempts,
+		logger:      logging.NewLoggerV2("mfa-challenge-service"),
+	}
+}
+
+// Issue creates a challenge token for a login that passed the password step.
+func (s *MFAChallengeService) Issue(ctx context.Context, challenge *MFAChallenge) (string, time.Time, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	challenge.ExpiresAt = time.Now().Add(s.ttl)
+	if err := s.store.Save(ctx, hashOpaqueToken(token), challenge); err != nil {
+		s.logger.Error("failed to store MFA challenge", logging.Fields{
+			"user_id": challenge.UserID,
+			"error":   err.Error(),
+		})
+		return "", time.Time{}, err
+	}
+
+	return token, challenge.ExpiresAt, nil
+}
+
+// Get returns the pending challenge for token.
+func (s *MFAChallengeService) Get(ctx context.Context, token string) (*MFAChallenge, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	challenge, err := s.store.Get(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+	return challenge, nil
+}
+
+// Fail records a wrong code. Once the attempt limit is reached the challenge
+// is discarded and the user must sign in with their password again.
+func (s *MFAChallengeService) Fail(ctx context.Context, token string) error {
+	hash := hashOpaqueToken(token)
+	attempts, err := s.store.IncrAttempts(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if attempts >= s.maxAttempts {
+		return s.store.Delete(ctx, hash)
+	}
+	return nil
+}
+
+// Complete discards a challenge after a successful verification so the token
+// cannot be used again.
+func (s *MFAChallengeService) Complete(ctx context.Context, token string) error {
+	return s.store.Delete(ctx, hashOpaqueToken(token))
+}
+
+// RedisMFAChallengeStore stores MFA challenges in Redis.
+type RedisMFAChallengeStore struct {
+	client *redis.Client
+	logger *logging.LoggerV2
+}
+
+// NewRedisMFAChallengeStore creates a new Redis-backed MFA challenge store.
+func NewRedisMFAChallengeStore(cfg config.RedisConfig) *RedisMFAChallengeStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisMFAChallengeStore{
+		client: client,
+		logger: logging.NewLoggerV2("mfa-challenge-store"),
+	}
+}
+
+// Save stores a challenge in a hash until it expires. Attempts are kept as
+// a separate field so they can be incremented atomically.
+func (s *RedisMFAChallengeStore) Save(ctx context.Context, hash string, challenge *MFAChallenge) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	key := mfaChallengePrefix + hash
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "data", data, "attempts", challenge.Attempts)
+		pipe.Expire(ctx, key, time.Until(challenge.ExpiresAt))
+		return nil
+	})
+	return err
+}
+
+func (s *RedisMFAChallengeStore) Get(ctx context.Context, hash string) (*MFAChallenge, error) {
+	values, err := s.client.HMGet(ctx, mfaChallengePrefix+hash, "data", "attempts").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := values[0].(string)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	var challenge MFAChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if attempts, ok := values[1].(string); ok {
+		challenge.Attempts, _ = strconv.Atoi(attempts)
+	}
+
+	return &challenge, nil
+}
+
+func (s *RedisMFAChallengeStore) IncrAttempts(ctx context.Context, hash string) (int, error) {
+	n, err := s.client.HIncrBy(ctx, mfaChallengePrefix+hash, "attempts", 1).Result()
+	return int(n), err
+}
+
+func (s *RedisMFAChallengeStore) Delete(ctx context.Context, hash string) error {
+	return s.client.Del(ctx, mfaChallengePrefix+hash).Err()
+}
+
+// InMemoryMFAChallengeStore is an MFA challenge store for tests and single-node development.
+type InMemoryMFAChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]MFAChallenge
+}
+
+func NewInMemoryMFAChallengeStore() *InMemoryMFAChallengeStore {
+	return &InMemoryMFAChallengeStore{
+		challenges: make(map[string]MFAChallenge),
+	}
+}
+
+func (s *InMemoryMFAChallengeStore) Save(ctx context.Context, hash string, challenge *MFAChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[hash] = *challenge
+	return nil
+}
+
+func (s *InMemoryMFAChallengeStore) Get(ctx context.Context, hash string) (*MFAChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &challenge, nil
+}
+
+func (s *InMemoryMFAChallengeStore) IncrAttempts(ctx context.Context, hash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[hash]
+	if !ok {
+		return 0, ErrInvalidToken
+	}
+	challenge.Attempts++
+	s.challenges[hash] = challenge
+	return challenge.Attempts, nil
+}
+
+func (s *InMemoryMFAChallengeStore) Delete(ctx context.Context, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, hash)
+	return nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ MFAChallengeStore = (*RedisMFAChallengeStore)(nil)
+	_ MFAChallengeStore = (*InMemoryMFAChallengeStore)(nil)
+)
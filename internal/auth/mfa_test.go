@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox("test-key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if sealed == "JBSWY3DPEHPK3PXP" {
+		t.Fatal("expected sealed value to differ from plaintext")
+	}
+
+	opened, err := box.Open(sealed)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if opened != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected JBSWY3DPEHPK3PXP, got %s", opened)
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		other, _ := NewSecretBox("other-key")
+		if _, err := other.Open(sealed); err != ErrInvalidHashFormat {
+			t.Fatalf("expected ErrInvalidHashFormat, got %v", err)
+		}
+		if other.Digest("code") == box.Digest("code") {
+			t.Fatal("expected digests to depend on the key")
+		}
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", recoveryCodeCount, len(codes))
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != recoveryCodeChars+1 || code[recoveryCodeChars/2] != '-' {
+			t.Fatalf("expected xxxxx-xxxxx, got %s", code)
+		}
+		if seen[code] {
+			t.Fatalf("expected unique codes, got %s twice", code)
+		}
+		seen[code] = true
+	}
+
+	if NormalizeRecoveryCode(" ABCDE-fghjk ") != "abcdefghjk" {
+		t.Fatalf("expected abcdefghjk, got %s", NormalizeRecoveryCode(" ABCDE-fghjk "))
+	}
+}
+
+func TestMFAChallenge(t *testing.T) {
+	ctx := context.Background()
+	svc := NewMFAChallengeService(NewInMemoryMFAChallengeStore(), config.MFAConfig{
+		ChallengeTTL: time.Minute,
+		MaxAttempts:  3,
+	})
+
+	token, _, err := svc.Issue(ctx, &MFAChallenge{UserID: "user-123", Email: "jane@example.com"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	challenge, err := svc.Get(ctx, token)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if challenge.UserID != "user-123" {
+		t.Fatalf("expected user-123, got %s", challenge.UserID)
+	}
+
+	t.Run("discarded after max attempts", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if err := svc.Fail(ctx, token); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+		if _, err := svc.Get(ctx, token); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		token, _, _ := svc.Issue(ctx, &MFAChallenge{UserID: "user-123"})
+		if err := svc.Complete(ctx, token); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := svc.Get(ctx, token); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := NewMFAChallengeService(NewInMemoryMFAChallengeStore(), config.MFAConfig{
+			ChallengeTTL: -time.Minute,
+			MaxAttempts:  3,
+		})
+		token, _, _ := expired.Issue(ctx, &MFAChallenge{UserID: "user-123"})
+		if _, err := expired.Get(ctx, token); err != ErrExpiredToken {
+			t.Fatalf("expected ErrExpiredToken, got %v", err)
+		}
+	})
+}
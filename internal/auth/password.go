@@ -7,17 +7,15 @@ import (
 	"errors"
 	"strings"
 
-	"golang.org/x/crypto/bcrypt"
-
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
 )
 
 const (
-	bcryptCost = 12
-
-	HashTypeMD5    = "md5"
-	HashTypeSHA1   = "sha1"
-	HashTypeBcrypt = "bcrypt"
+	HashTypeMD5      = "md5"
+	HashTypeSHA1     = "sha1"
+	HashTypeBcrypt   = "bcrypt"
+	HashTypeArgon2id = "argon2id"
 )
 
 var (
@@ -28,41 +26,72 @@ var (
 	ErrInvalidHashFormat = errors.New("invalid hash format")
 )
 
-// PasswordService handles password hashing and validation.
+// PasswordService handles password hashing and validation. New hashes use the
+// configured Hasher; bcrypt and argon2id hashes are always verifiable so the
+// policy can change without locking anyone out.
 type PasswordService struct {
+	hasher       Hasher
+	verifiers    map[string]Hasher
 	enableLegacy bool
 	logger       *logging.LoggerV2
 }
 
-// NewPasswordService creates a new password service.
+// NewPasswordService creates a new password service that hashes with bcrypt
+// at DefaultBcryptCost.
 func NewPasswordService(enableLegacy bool) *PasswordService {
+	hasher, _ := NewBcryptHasher(DefaultBcryptCost)
+	return NewPasswordServiceWithHasher(hasher, enableLegacy)
+}
+
+// NewPasswordServiceWithHasher creates a password service that hashes new
+// passwords with hasher.
+func NewPasswordServiceWithHasher(hasher Hasher, enableLegacy bool) *PasswordService {
+	// Parameters of verifiers are irrelevant; each hash records its own
+	verifiers := map[string]Hasher{
+		HashTypeBcrypt:   &BcryptHasher{cost: DefaultBcryptCost},
+		HashTypeArgon2id: &Argon2idHasher{},
+	}
+	verifiers[hasher.Algorithm()] = hasher
+
 	return &PasswordService{
+		hasher:       hasher,
+		verifiers:    verifiers,
 		enableLegacy: enableLegacy,
 		logger:       logging.NewLoggerV2("password-service"),
 	}
 }
 
+// Algorithm returns the hash type new passwords are hashed with.
+func (s *PasswordService) Algorithm() string {
+	return s.hasher.Algorithm()
+}
+
 // SEC-125: bcrypt hashing introduced for new user registrations
-// HashPassword hashes a password using bcrypt (recommended).
+// HashPassword hashes a password with the configured algorithm.
 func (s *PasswordService) HashPassword(password string) (string, error) {
 	if err := s.validatePassword(password); err != nil {
 		return "", err
 	}
 
-	s.logger.Debug("hashing password with bcrypt", logging.Fields{
-		"cost": bcryptCost,
+	s.logger.Debug("hashing password", logging.Fields{
+		"algorithm": s.hasher.Algorithm(),
 	})
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	hash, err := s.hasher.Hash(password)
 	if err != nil {
-		s.logger.Error("bcrypt hashing failed", logging.Fields{"error": err.Error()})
+		s.logger.Error("password hashing failed", logging.Fields{
+			"algorithm": s.hasher.Algorithm(),
+			"error":     err.Error(),
+		})
 		return "", err
 	}
 
-	return string(hash), nil
+	return hash, nil
 }
 
 // CheckPassword verifies a password against a hash (supports all hash types).
+// needsMigration is true when the hash uses a legacy or different algorithm,
+// or weaker parameters than the configured policy.
 func (s *PasswordService) CheckPassword(password, hash string) (bool, bool) {
 	hashType := s.DetectHashType(hash)
 
@@ -74,9 +103,9 @@ func (s *PasswordService) CheckPassword(password, hash string) (bool, bool) {
 	var needsMigration bool
 
 	switch hashType {
-	case HashTypeBcrypt:
-		valid = s.checkBcryptPassword(password, hash)
-		needsMigration = false
+	case HashTypeBcrypt, HashTypeArgon2id:
+		valid = s.checkModernPassword(hashType, password, hash)
+		needsMigration = s.NeedsRehash(hash)
 	case HashTypeMD5:
 		valid = s.checkMD5Password(password, hash)
 		needsMigration = true
@@ -87,23 +116,36 @@ func (s *PasswordService) CheckPassword(password, hash string) (bool, bool) {
 		s.logger.Warn("unknown hash type detected", logging.Fields{
 			"hash_length": len(hash),
 		})
+		metrics.PasswordChecks.WithLabelValues("unknown", "invalid").Inc()
 		return false, false
 	}
 
+	result := "invalid"
+	if valid {
+		result = "valid"
+	}
+	metrics.PasswordChecks.WithLabelValues(hashType, result).Inc()
+
 	if needsMigration {
 		s.logger.Info("password hash needs migration", logging.Fields{
 			"from": hashType,
-			"to":   HashTypeBcrypt,
+			"to":   s.hasher.Algorithm(),
 		})
 	}
 
 	return valid, needsMigration
 }
 
-// checkBcryptPassword verifies a password against a bcrypt hash.
-func (s *PasswordService) checkBcryptPassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// checkModernPassword verifies a password against a bcrypt or argon2id hash.
+func (s *PasswordService) checkModernPassword(hashType, password, hash string) bool {
+	valid, err := s.verifiers[hashType].Verify(password, hash)
+	if err != nil {
+		s.logger.Warn("malformed password hash", logging.Fields{
+			"hash_type": hashType,
+		})
+		return false
+	}
+	return valid
 }
 
 // checkMD5Password verifies a password against an MD5 hash.
@@ -147,6 +189,10 @@ func (s *PasswordService) DetectHashType(hash string) string {
 		return HashTypeBcrypt
 	}
 
+	if strings.HasPrefix(hash, "$"+HashTypeArgon2id+"$") {
+		return HashTypeArgon2id
+	}
+
 	// MD5 hashes are 32 hex characters
 	if len(hash) == 32 && isHexString(hash) {
 		return HashTypeMD5
@@ -160,16 +206,23 @@ func (s *PasswordService) DetectHashType(hash string) string {
 	return ""
 }
 
-// MigratePasswordHash migrates a password from a legacy hash to bcrypt.
+// MigratePasswordHash rehashes a password with the configured algorithm and
+// parameters.
 func (s *PasswordService) MigratePasswordHash(password string) (string, error) {
-	s.logger.Info("migrating password hash to bcrypt")
+	s.logger.Info("migrating password hash", logging.Fields{
+		"to": s.hasher.Algorithm(),
+	})
 	return s.HashPassword(password)
 }
 
-// NeedsRehash checks if a password hash should be migrated.
+// NeedsRehash checks if a password hash should be migrated: it is a legacy
+// hash, uses a different algorithm than the configured one, or used weaker
+// parameters.
 func (s *PasswordService) NeedsRehash(hash string) bool {
-	hashType := s.DetectHashType(hash)
-	return hashType != HashTypeBcrypt
+	if s.DetectHashType(hash) != s.hasher.Algorithm() {
+		return true
+	}
+	return s.hasher.NeedsRehash(hash)
 }
 
 func (s *PasswordService) validatePassword(password string) error {
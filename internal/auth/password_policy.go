@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+)
+
+// Password policy violation codes.
+const (
+	ViolationTooShort     = "too_short"
+	ViolationTooLong      = "too_long"
+	ViolationNoLowercase  = "missing_lowercase"
+	ViolationNoUppercase  = "missing_uppercase"
+	ViolationNoDigit      = "missing_digit"
+	ViolationNoSymbol     = "missing_symbol"
+	ViolationTooWeak      = "too_weak"
+	ViolationCommon       = "common_password"
+	ViolationPersonalInfo = "contains_personal_info"
+	ViolationRecentlyUsed = "recently_used"
+	ViolationBreached     = "breached_password"
+)
+
+// minPersonalInfoMatchSize ignores name and email parts too short to matter.
+const minPersonalInfoMatchSize = 3
+
+// PasswordViolation is one password policy rule a password failed.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password failed.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	codes := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		codes[i] = v.Code
+	}
+	return "password does not meet policy: " + strings.Join(codes, ", ")
+}
+
+// PasswordContext is what the policy knows about the password's owner.
+type PasswordContext struct {
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// PasswordPolicy checks new passwords against the configured rules. Reuse of
+// previous passwords needs the user's history and is checked by the caller.
+type PasswordPolicy struct {
+	cfg      config.PasswordPolicyConfig
+	denyList map[string]struct{}
+	breached BreachedPasswords
+	logger   *logging.LoggerV2
+}
+
+// NewPasswordPolicy creates a password policy, loading the deny-list file if
+// one is configured. breached may be nil to skip breached-password screening.
+func NewPasswordPolicy(cfg config.PasswordPolicyConfig, breached BreachedPasswords) (*PasswordPolicy, error) {
+	// PasswordService refuses to hash outside these bounds regardless
+	if cfg.MinLength < 8 || cfg.MaxLength > 72 || cfg.MinLength > cfg.MaxLength {
+		return nil, fmt.Errorf("password length bounds must be within 8-72, got %d-%d", cfg.MinLength, cfg.MaxLength)
+	}
+
+	policy := &PasswordPolicy{
+		cfg:      cfg,
+		denyList: map[string]struct{}{},
+		breached: breached,
+		logger:   logging.NewLoggerV2("password-policy"),
+	}
+	if cfg.DenyListFile != "" {
+		if err := policy.loadDenyList(cfg.DenyListFile); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// HistorySize is how many previous passwords a new one may not repeat.
+func (p *PasswordPolicy) HistorySize() int {
+	return p.cfg.HistorySize
+}
+
+// Check returns every rule password breaks, or nil if it satisfies the
+// policy.
+func (p *PasswordPolicy) Check(password string, pc PasswordContext) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(code, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(password) < p.cfg.MinLength {
+		add(ViolationTooShort, "must be at least %d characters", p.cfg.MinLength)
+	}
+	if len(password) > p.cfg.MaxLength {
+		add(ViolationTooLong, "must be at most %d characters", p.cfg.MaxLength)
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if p.cfg.RequireLowercase && !hasLower {
+		add(ViolationNoLowercase, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireUppercase && !hasUpper {
+		add(ViolationNoUppercase, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireDigit && !hasDigit {
+		add(ViolationNoDigit, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !hasSymbol {
+		add(ViolationNoSymbol, "must contain a symbol")
+	}
+
+	if PasswordStrength(password) < p.cfg.MinScore {
+		add(ViolationTooWeak, "is too weak; use a longer password with a mix of character types")
+	}
+
+	if _, denied := p.denyList[strings.ToLower(password)]; denied {
+		add(ViolationCommon, "is too common")
+	}
+
+	if containsPersonalInfo(password, pc) {
+		add(ViolationPersonalInfo, "must not contain your name or email address")
+	}
+
+	if p.Breached(password) {
+		add(ViolationBreached, "has appeared in a data breach")
+	}
+
+	return violations
+}
+
+// Validate returns a *PasswordPolicyError if password breaks the policy.
+func (p *PasswordPolicy) Validate(password string, pc PasswordContext) error {
+	if violations := p.Check(password, pc); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Breached reports whether password appears in the breached-password
+// corpus. Lookup errors are logged and treated as not breached so a damaged
+// corpus cannot block every password change.
+func (p *PasswordPolicy) Breached(password string) bool {
+	if p.breached == nil {
+		return false
+	}
+
+	breached, err := p.breached.Breached(password)
+	if err != nil {
+		p.logger.Error("breached password lookup failed", logging.Fields{"error": err.Error()})
+		return false
+	}
+	if breached {
+		metrics.BreachedPasswords.Inc()
+	}
+	return breached
+}
+
+// loadDenyList reads one password per line. Blank lines and lines starting
+// with # are ignored; matching is case-insensitive.
+func (p *PasswordPolicy) loadDenyList(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open password deny-list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.denyList[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read password deny-list: %w", err)
+	}
+	return nil
+}
+
+func containsPersonalInfo(password string, pc PasswordContext) bool {
+	lower := strings.ToLower(password)
+
+	local, _, _ := strings.Cut(strings.ToLower(pc.Email), "@")
+
+	for _, part := range []string{local, strings.ToLower(pc.FirstName), strings.ToLower(pc.LastName)} {
+		if len(part) >= minPersonalInfoMatchSize && strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func testPasswordPolicyConfig() config.PasswordPolicyConfig {
+	return config.PasswordPolicyConfig{
+		MinLength:        10,
+		MaxLength:        72,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		MinScore:         2,
+		HistorySize:      5,
+	}
+}
+
+func violationCodes(violations []PasswordViolation) map[string]bool {
+	codes := make(map[string]bool)
+	for _, v := range violations {
+		codes[v.Code] = true
+	}
+	return codes
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy, err := NewPasswordPolicy(testPasswordPolicyConfig(), nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"valid", "Tangerine42Kite", nil},
+		{"too short", "Tang42ab", []string{ViolationTooShort}},
+		{"missing classes", "tangerinekite", []string{ViolationNoUppercase, ViolationNoDigit}},
+		{"too long", "Aa1" + string(make([]byte, 70)), []string{ViolationTooLong}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := policy.Check(tt.password, PasswordContext{})
+			codes := violationCodes(violations)
+			for _, code := range tt.want {
+				if !codes[code] {
+					t.Fatalf("expected violation %s, got %v", code, violations)
+				}
+			}
+			if tt.want == nil && len(violations) != 0 {
+				t.Fatalf("expected no violations, got %v", violations)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicySymbolAndScore(t *testing.T) {
+	cfg := testPasswordPolicyConfig()
+	cfg.RequireSymbol = true
+	cfg.MinScore = 4
+	policy, _ := NewPasswordPolicy(cfg, nil)
+
+	codes := violationCodes(policy.Check("Tangerine42Kite", PasswordContext{}))
+	if !codes[ViolationNoSymbol] || !codes[ViolationTooWeak] {
+		t.Fatalf("expected missing_symbol and too_weak, got %v", codes)
+	}
+
+	if violations := policy.Check("Tangerine42Kite!", PasswordContext{}); len(violations) != 0 {
+		t.Fatalf("expected no violations, got %v", violations)
+	}
+}
+
+func TestPasswordPolicyDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "common.txt")
+	content := "# common passwords\n\nPassword123!\nWelcome2024Spring\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write deny-list: %v", err)
+	}
+
+	cfg := testPasswordPolicyConfig()
+	cfg.DenyListFile = path
+	policy, err := NewPasswordPolicy(cfg, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if codes := violationCodes(policy.Check("welcome2024SPRING", PasswordContext{})); !codes[ViolationCommon] {
+		t.Fatalf("expected deny-listed password to be rejected case-insensitively, got %v", codes)
+	}
+	if codes := violationCodes(policy.Check("# common passwords", PasswordContext{})); codes[ViolationCommon] {
+		t.Fatal("expected comment lines to be ignored")
+	}
+
+	cfg.DenyListFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewPasswordPolicy(cfg, nil); err == nil {
+		t.Fatal("expected missing deny-list file to be an error")
+	}
+}
+
+func TestPasswordPolicyPersonalInfo(t *testing.T) {
+	policy, _ := NewPasswordPolicy(testPasswordPolicyConfig(), nil)
+	pc := PasswordContext{Email: "jdoe@example.com", FirstName: "Jane", LastName: "Do"}
+
+	tests := []struct {
+		password string
+		rejected bool
+	}{
+		{"Xx9JDOE-secure", true},
+		{"Jane2024Secure", true},
+		// Parts shorter than three characters are ignored
+		{"Domino2024Kite", false},
+		{"Tangerine42Kite", false},
+	}
+
+	for _, tt := range tests {
+		codes := violationCodes(policy.Check(tt.password, pc))
+		if codes[ViolationPersonalInfo] != tt.rejected {
+			t.Fatalf("expected personal info rejection %v for %s, got %v", tt.rejected, tt.password, codes)
+		}
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy, _ := NewPasswordPolicy(testPasswordPolicyConfig(), nil)
+
+	if err := policy.Validate("Tangerine42Kite", PasswordContext{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	err := policy.Validate("short", PasswordContext{})
+	policyErr, ok := err.(*PasswordPolicyError)
+	if !ok {
+		t.Fatalf("expected *PasswordPolicyError, got %T", err)
+	}
+	if len(policyErr.Violations) == 0 {
+		t.Fatal("expected violations to be listed")
+	}
+}
+
+func TestNewPasswordPolicyBounds(t *testing.T) {
+	for _, bounds := range [][2]int{{6, 72}, {8, 100}, {20, 12}} {
+		cfg := testPasswordPolicyConfig()
+		cfg.MinLength, cfg.MaxLength = bounds[0], bounds[1]
+		if _, err := NewPasswordPolicy(cfg, nil); err == nil {
+			t.Fatalf("expected length bounds %v to be rejected", bounds)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+)
+
+// Permission identifies an action a caller may perform on a resource.
+// Permissions ending in ":self" only apply to resources the caller owns;
+// permissions ending in ":any" apply to every resource of that type.
+type Permission string
+
+const (
+	PermUsersList       Permission = "users:list"
+	PermUsersCreate     Permission = "users:create"
+	PermUsersReadSelf   Permission = "users:read:self"
+	PermUsersReadAny    Permission = "users:read:any"
+	PermUsersUpdateSelf Permission = "users:update:self"
+	PermUsersUpdateAny  Permission = "users:update:any"
+	PermUsersDeleteSelf Permission = "users:delete:self"
+	PermUsersDeleteAny  Permission = "users:delete:any"
+	PermUsersUnlock     Permission = "users:unlock"
+
+	PermSessionsRevokeSelf Permission = "sessions:revoke:self"
+	PermSessionsRevokeAny  Permission = "sessions:revoke:any"
+
+	PermAuditRead Permission = "audit:read"
+
+	PermHealthRead Permission = "health:read"
+
+	PermOAuthClientsManage Permission = "oauth_clients:manage"
+
+	// PermTokensIntrospect lets a machine client introspect tokens issued
+	// to anyone. It is only granted as a client scope.
+	PermTokensIntrospect Permission = "tokens:introspect"
+
+	// PermSSOVerify lets a machine client verify assertions from the legacy
+	// SSO provider. It is only granted as a client scope.
+	PermSSOVerify Permission = "sso:verify"
+)
+
+// selfServicePermissions are granted to every authenticated role.
+var selfServicePermissions = []Permission{
+	PermUsersReadSelf,
+	PermUsersUpdateSelf,
+	PermUsersDeleteSelf,
+	PermSessionsRevokeSelf,
+}
+
+// rolePermissions maps each role to the permissions it is granted.
+// TODO(TEAM-SEC): Move to a database-backed policy once roles are editable.
+var rolePermissions = map[models.UserRole][]Permission{
+	models.RoleAdmin: append([]Permission{
+		PermUsersList,
+		PermUsersCreate,
+		PermUsersReadAny,
+		PermUsersUpdateAny,
+		PermUsersDeleteAny,
+		PermUsersUnlock,
+		PermSessionsRevokeAny,
+		PermAuditRead,
+		PermHealthRead,
+		PermOAuthClientsManage,
+	}, selfServicePermissions...),
+	models.RoleVendor:   selfServicePermissions,
+	models.RoleCustomer: selfServicePermissions,
+}
+
+// HasPermission reports whether the given role is granted a permission.
+// Unknown roles are granted nothing.
+func HasPermission(role models.UserRole, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// servicePermissions may be granted to machine clients as scopes of the
+// client_credentials grant. Internal routes are authorized by these scopes
+// rather than by role.
+var servicePermissions = []Permission{
+	PermUsersList,
+	PermUsersReadAny,
+	PermTokensIntrospect,
+	PermSSOVerify,
+}
+
+// IsServiceScope reports whether scope may be granted to a machine client.
+func IsServiceScope(scope string) bool {
+	for _, p := range servicePermissions {
+		if string(p) == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	refreshTokenPrefix = "refresh_token:"
+	opaqueTokenBytes   = 32
+
+	// maxConsumeRetries bounds optimistic-locking retries when two requests
+	// race to rotate the same refresh token.
+	maxConsumeRetries = 3
+)
+
+// RefreshTokenRecord is the server-side state of an opaque refresh token.
+// Every token issued for a session belongs to that session's family; rotating
+// a token marks it used and issues its successor.
+type RefreshTokenRecord struct {
+	SessionID string    `json:"session_id"`
+	UserID    string    `json:"user_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"`
+}
+
+// RefreshTokenStore persists refresh token records keyed by token hash.
+type RefreshTokenStore interface {
+	// Save stores a new record.
+	Save(ctx context.Context, hash string, record *RefreshTokenRecord) error
+
+	// Consume atomically marks a record as used and returns it as it was
+	// before the call. It returns ErrInvalidToken if no record exists.
+	Consume(ctx context.Context, hash string) (*RefreshTokenRecord, error)
+
+	// Get returns a record without changing it. It returns ErrInvalidToken
+	// if no record exists.
+	Get(ctx context.Context, hash string) (*RefreshTokenRecord, error)
+}
+
+// RefreshTokenService issues and rotates opaque refresh tokens.
+type RefreshTokenService struct {
+	store  RefreshTokenStore
+	ttl    time.Duration
+	logger *logging.LoggerV2
+}
+
+// NewRefreshTokenService creates a new refresh token service.
+func NewRefreshTokenService(store RefreshTokenStore, ttl time.Duration) *RefreshTokenService {
+	return &RefreshTokenService{
+		store:  store,
+		ttl:    ttl,
+		logger: logging.NewLoggerV2("refresh-token-service"),
+	}
+}
+
+// Issue creates a new refresh token bound to a session.
+func (s *RefreshTokenService) Issue(ctx context.Context, session *Session) (string, time.Time, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	record := &RefreshTokenRecord{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	if err := s.store.Save(ctx, hashOpaqueToken(token), record); err != nil {
+		s.logger.Error("failed to store refresh token", logging.Fields{
+			"session_id": session.ID,
+			"error":      err.Error(),
+		})
+		return "", time.Time{}, err
+	}
+
+	return token, record.ExpiresAt, nil
+}
+
+// Redeem consumes a refresh token. The returned record identifies the session
+// the token belongs to. If the token was already used, the record is returned
+// together with ErrRefreshTokenReused so the caller can revoke the session.
+func (s *RefreshTokenService) Redeem(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	record, err := s.store.Consume(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if record.Used {
+		s.logger.Warn("refresh token reuse detected", logging.Fields{
+			"session_id": record.SessionID,
+			"user_id":    record.UserID,
+		})
+		return record, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	return record, nil
+}
+
+// Lookup returns the record of an unused, unexpired refresh token without
+// redeeming it, so the session it belongs to can be checked or revoked.
+func (s *RefreshTokenService) Lookup(ctx context.Context, token string) (*RefreshTokenRecord, error) {
+	record, err := s.store.Get(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if record.Used {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+	return record, nil
+}
+
+func newOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashOpaqueToken derives the storage key for a token. Tokens carry 256 bits
+// of entropy, so an unsalted SHA-256 is sufficient.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RedisRefreshTokenStore stores refresh tokens in Redis.
+type RedisRefreshTokenStore struct {
+	client *redis.Client
+	logger *logging.LoggerV2
+}
+
+// NewRedisRefreshTokenStore creates a new Redis-backed refresh token store.
+func NewRedisRefreshTokenStore(cfg config.RedisConfig) *RedisRefreshTokenStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisRefreshTokenStore{
+		client: client,
+		logger: logging.NewLoggerV2("refresh-token-store"),
+	}
+}
+
+// Save stores a new record until it expires.
+func (s *RedisRefreshTokenStore) Save(ctx context.Context, hash string, record *RefreshTokenRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, refreshTokenPrefix+hash, data, time.Until(record.ExpiresAt)).Err()
+}
+
+// Consume marks a record as used. Used records are kept until they expire so
+// that replaying a rotated token can be detected.
+func (s *RedisRefreshTokenStore) Consume(ctx context.Context, hash string) (*RefreshTokenRecord, error) {
+	key := refreshTokenPrefix + hash
+
+	var prior RefreshTokenRecord
+	consume := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := json.Unmarshal(data, &prior); err != nil {
+			return ErrInvalidToken
+		}
+
+		used := prior
+		used.Used = true
+		updated, err := json.Marshal(&used)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxConsumeRetries; i++ {
+		err := s.client.Watch(ctx, consume, key)
+		if err == redis.TxFailedErr {
+			// Another request rotated the token first; retrying will see it as used
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &prior, nil
+	}
+
+	s.logger.Warn("refresh token consume retries exhausted", logging.Fields{"key": key})
+	return nil, ErrInvalidToken
+}
+
+func (s *RedisRefreshTokenStore) Get(ctx context.Context, hash string) (*RefreshTokenRecord, error) {
+	data, err := s.client.Get(ctx, refreshTokenPrefix+hash).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record RefreshTokenRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &record, nil
+}
+
+// InMemoryRefreshTokenStore is a refresh token store for tests and single-node development.
+type InMemoryRefreshTokenStore struct {
+	mu      sync.Mutex
+	records map[string]RefreshTokenRecord
+}
+
+func NewInMemoryRefreshTokenStore() *InMemoryRefreshTokenStore {
+	return &InMemoryRefreshTokenStore{
+		records: make(map[string]RefreshTokenRecord),
+	}
+}
+
+func (s *InMemoryRefreshTokenStore) Save(ctx context.Context, hash string, record *RefreshTokenRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[hash] = *record
+	return nil
+}
+
+func (s *InMemoryRefreshTokenStore) Consume(ctx context.Context, hash string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	used := record
+	used.Used = true
+	s.records[hash] = used
+
+	return &record, nil
+}
+
+func (s *InMemoryRefreshTokenStore) Get(ctx context.Context, hash string) (*RefreshTokenRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &record, nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ RefreshTokenStore = (*RedisRefreshTokenStore)(nil)
+	_ RefreshTokenStore = (*InMemoryRefreshTokenStore)(nil)
+)
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	svc := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), time.Hour)
+	session := &Session{ID: "sess-123", UserID: "user-123"}
+
+	first, expiresAt, err := svc.Issue(ctx, session)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if first == "" {
+		t.Fatal("expected non-empty refresh token")
+	}
+	if time.Until(expiresAt) > time.Hour {
+		t.Fatalf("expected expiry within 1h, got %v", expiresAt)
+	}
+
+	t.Run("redeem returns owning session", func(t *testing.T) {
+		record, err := svc.Redeem(ctx, first)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if record.SessionID != session.ID || record.UserID != session.UserID {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	})
+
+	t.Run("reuse detected", func(t *testing.T) {
+		record, err := svc.Redeem(ctx, first)
+		if err != ErrRefreshTokenReused {
+			t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+		}
+		if record == nil || record.SessionID != session.ID {
+			t.Fatal("expected reused record to identify the session")
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		if _, err := svc.Redeem(ctx, "not-a-token"); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("tokens are unique", func(t *testing.T) {
+		second, _, _ := svc.Issue(ctx, session)
+		if second == first {
+			t.Fatal("expected rotated token to differ from its predecessor")
+		}
+	})
+}
+
+func TestRefreshTokenExpiry(t *testing.T) {
+	ctx := context.Background()
+	svc := NewRefreshTokenService(NewInMemoryRefreshTokenStore(), -time.Minute)
+
+	token, _, _ := svc.Issue(ctx, &Session{ID: "sess-123", UserID: "user-123"})
+
+	if _, err := svc.Redeem(ctx, token); err != ErrExpiredToken {
+		t.Fatalf("expected ErrExpiredToken, got %v", err)
+	}
+}
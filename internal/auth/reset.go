@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	passwordResetPrefix     = "password_reset:"
+	passwordResetUserPrefix = "password_reset:user:"
+)
+
+// PasswordResetRecord is the server-side state of a password reset token.
+type PasswordResetRecord struct {
+	UserID    string    `json:"user_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordResetStore persists reset token records keyed by token hash.
+type PasswordResetStore interface {
+	// Save stores a new record and invalidates any token previously issued
+	// to the same user, so only the most recent link works.
+	Save(ctx context.Context, hash string, record *PasswordResetRecord) error
+
+	// Get returns a record without removing it. It returns ErrInvalidToken
+	// if no record exists.
+	Get(ctx context.Context, hash string) (*PasswordResetRecord, error)
+
+	// Consume atomically removes a record and returns it. It returns
+	// ErrInvalidToken if no record exists.
+	Consume(ctx context.Context, hash string) (*PasswordResetRecord, error)
+}
+
+// PasswordResetService issues and redeems single-use password reset tokens.
+// Only a SHA-256 hash of each token is stored.
+type PasswordResetService struct {
+	store  PasswordResetStore
+	ttl    time.Duration
+	logger *logging.LoggerV2
+}
+
+// NewPasswordResetService creates a new password reset service.
+func NewPasswordResetService(store PasswordResetStore, ttl time.Duration) *PasswordResetService {
+	return &PasswordResetService{
+		store:  store,
+		ttl:    ttl,
+		logger: logging.NewLoggerV2("password-reset-service"),
+	}
+}
+
+// Issue creates a reset token for a user.
+func (s *PasswordResetService) Issue(ctx context.Context, userID string) (string, time.Time, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	record := &PasswordResetRecord{
+		UserID:    userID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	if err := s.store.Save(ctx, hashOpaqueToken(token), record); err != nil {
+		s.logger.Error("failed to store password reset token", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return "", time.Time{}, err
+	}
+
+	return token, record.ExpiresAt, nil
+}
+
+// Lookup returns the user a reset token was issued to without consuming it,
+// so the new password can be checked before the token is spent.
+func (s *PasswordResetService) Lookup(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	record, err := s.store.Get(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return "", ErrExpiredToken
+	}
+
+	return record.UserID, nil
+}
+
+// Redeem consumes a reset token and returns the user it was issued to. A
+// token can only be redeemed once, whether or not the reset that follows
+// succeeds.
+func (s *PasswordResetService) Redeem(ctx context.Context, token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	record, err := s.store.Consume(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	if time.Now().After(record.ExpiresAt) {
+		return "", ErrExpiredToken
+	}
+
+	return record.UserID, nil
+}
+
+// RedisPasswordResetStore stores reset tokens in Redis.
+type RedisPasswordResetStore struct {
+	client *redis.Client
+	logger *logging.LoggerV2
+}
+
+// NewRedisPasswordResetStore creates a new Redis-backed password reset store.
+func NewRedisPasswordResetStore(cfg config.RedisConfig) *RedisPasswordResetStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisPasswordResetStore{
+		client: client,
+		logger: logging.NewLoggerV2("password-reset-store"),
+	}
+}
+
+// Save stores a record until it expires. A per-user pointer tracks the
+// outstanding token so it can be invalidated when a new one is issued.
+func (s *RedisPasswordResetStore) Save(ctx context.Context, hash string, record *PasswordResetRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	userKey := passwordResetUserPrefix + record.UserID
+	previous, err := s.client.Get(ctx, userKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	ttl := time.Until(record.ExpiresAt)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, passwordResetPrefix+previous)
+		}
+		pipe.Set(ctx, passwordResetPrefix+hash, data, ttl)
+		pipe.Set(ctx, userKey, hash, ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisPasswordResetStore) Get(ctx context.Context, hash string) (*PasswordResetRecord, error) {
+	data, err := s.client.Get(ctx, passwordResetPrefix+hash).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record PasswordResetRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &record, nil
+}
+
+// Consume reads and deletes a record in one transaction so concurrent
+// requests cannot both redeem the same token.
+func (s *RedisPasswordResetStore) Consume(ctx context.Context, hash string) (*PasswordResetRecord, error) {
+	key := passwordResetPrefix + hash
+
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record PasswordResetRecord
+	if err := json.Unmarshal([]byte(get.Val()), &record); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &record, nil
+}
+
+// InMemoryPasswordResetStore is a password reset store for tests and single-node development.
+type InMemoryPasswordResetStore struct {
+	mu      sync.Mutex
+	records map[string]PasswordResetRecord
+	byUser  map[string]string
+}
+
+func NewInMemoryPasswordResetStore() *InMemoryPasswordResetStore {
+	return &InMemoryPasswordResetStore{
+		records: make(map[string]PasswordResetRecord),
+		byUser:  make(map[string]string),
+	}
+}
+
+func (s *InMemoryPasswordResetStore) Save(ctx context.Context, hash string, record *PasswordResetRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.byUser[record.UserID]; ok {
+		delete(s.records, previous)
+	}
+	s.records[hash] = *record
+	s.byUser[record.UserID] = hash
+	return nil
+}
+
+func (s *InMemoryPasswordResetStore) Get(ctx context.Context, hash string) (*PasswordResetRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return &record, nil
+}
+
+func (s *InMemoryPasswordResetStore) Consume(ctx context.Context, hash string) (*PasswordResetRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[hash]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	delete(s.records, hash)
+	if s.byUser[record.UserID] == hash {
+		delete(s.byUser, record.UserID)
+	}
+
+	return &record, nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ PasswordResetStore = (*RedisPasswordResetStore)(nil)
+	_ PasswordResetStore = (*InMemoryPasswordResetStore)(nil)
+)
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPasswordResetService(NewInMemoryPasswordResetStore(), time.Hour)
+
+	token, expiresAt, err := svc.Issue(ctx, "user-123")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if token == "" {
+		t.Fatal("expected non-empty reset token")
+	}
+	if time.Until(expiresAt) > time.Hour {
+		t.Fatalf("expected expiry within 1h, got %v", expiresAt)
+	}
+
+	t.Run("lookup does not consume", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			userID, err := svc.Lookup(ctx, token)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if userID != "user-123" {
+				t.Fatalf("expected user-123, got %s", userID)
+			}
+		}
+	})
+
+	t.Run("redeem returns user", func(t *testing.T) {
+		userID, err := svc.Redeem(ctx, token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if userID != "user-123" {
+			t.Fatalf("expected user-123, got %s", userID)
+		}
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		if _, err := svc.Redeem(ctx, token); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+
+	t.Run("new token invalidates previous", func(t *testing.T) {
+		first, _, _ := svc.Issue(ctx, "user-456")
+		second, _, _ := svc.Issue(ctx, "user-456")
+
+		if _, err := svc.Redeem(ctx, first); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken for superseded token, got %v", err)
+		}
+		if _, err := svc.Redeem(ctx, second); err != nil {
+			t.Fatalf("expected latest token to redeem, got %v", err)
+		}
+	})
+
+	t.Run("empty token", func(t *testing.T) {
+		if _, err := svc.Redeem(ctx, ""); err != ErrInvalidToken {
+			t.Fatalf("expected ErrInvalidToken, got %v", err)
+		}
+	})
+}
+
+func TestPasswordResetExpiry(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPasswordResetService(NewInMemoryPasswordResetStore(), -time.Minute)
+
+	token, _, err := svc.Issue(ctx, "user-123")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := svc.Lookup(ctx, token); err != ErrExpiredToken {
+		t.Fatalf("expected ErrExpiredToken from lookup, got %v", err)
+	}
+	if _, err := svc.Redeem(ctx, token); err != ErrExpiredToken {
+		t.Fatalf("expected ErrExpiredToken, got %v", err)
+	}
+}
@@ -2,19 +2,18 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"sort"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
 )
 
-const (
-	sessionPrefix = "session:"
-	sessionTTL    = 24 * time.Hour
-)
+// maxTouchInterval bounds how often Touch writes a session's activity back to
+// the store, so that every authenticated request does not cost a write.
+const maxTouchInterval = time.Minute
 
 var (
 	ErrSessionNotFound = errors.New("session not found")
@@ -33,6 +32,24 @@ type Session struct {
 	IPAddress string    `json:"ip_address"`
 	UserAgent string    `json:"user_agent"`
 	Active    bool      `json:"active"`
+
+	// DeviceID identifies the browser or app the session was created from,
+	// from a long-lived cookie. Empty if the client did not keep cookies.
+	DeviceID string `json:"device_id,omitempty"`
+
+	// LastActivityAt is when the session was last used. ExpiresAt is the
+	// idle timeout after it, but never later than MaxExpiresAt, the end of
+	// the session's absolute lifetime.
+	LastActivityAt time.Time `json:"last_activity_at"`
+	MaxExpiresAt   time.Time `json:"max_expires_at"`
+
+	// AuthMethods lists how the user authenticated (RFC 8176 amr values).
+	AuthMethods []string `json:"auth_methods,omitempty"`
+
+	// ClientID is set on sessions an OAuth client holds for the user, which
+	// are limited to Scopes. It is empty for first-party logins.
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
 }
 
 // SessionV1 represents a legacy session format.
@@ -44,88 +61,111 @@ type SessionV1 struct {
 	// TODO(TEAM-SEC): Remove legacy session format
 }
 
-// SessionService handles user session management.
+// SessionService handles user session management on top of a SessionStore.
+// Session lifetimes and per-user limits come from the role's
+// config.SessionLimits.
 type SessionService struct {
-	client *redis.Client
+	store  SessionStore
+	cfg    config.SessionConfig
 	logger *logging.LoggerV2
 }
 
 // NewSessionService creates a new session service.
-func NewSessionService(cfg config.RedisConfig) *SessionService {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.Addr(),
-		Password: cfg.Password,
-		DB:       cfg.DB,
-	})
-
+func NewSessionService(store SessionStore, cfg config.SessionConfig) *SessionService {
 	return &SessionService{
-		client: client,
+		store:  store,
+		cfg:    cfg,
 		logger: logging.NewLoggerV2("session-service"),
 	}
 }
 
-// Create creates a new session for a user.
-func (s *SessionService) Create(ctx context.Context, userID, email, role, ipAddress, userAgent string) (*Session, error) {
-	sessionID := generateSessionID()
-	now := time.Now()
+// Create creates a new session for a user. authMethods records how the user
+// authenticated and is carried into tokens issued for the session; deviceID
+// identifies the client it logged in from. If the user is at the role's
+// concurrent session limit, Create either evicts the user's oldest sessions
+// or returns ErrTooManySessions.
+func (s *SessionService) Create(ctx context.Context, userID, email, role, ipAddress, userAgent, deviceID string, authMethods []string) (*Session, error) {
+	return s.create(ctx, &Session{
+		UserID:      userID,
+		Email:       email,
+		Role:        role,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		DeviceID:    deviceID,
+		AuthMethods: authMethods,
+	})
+}
 
-	session := &Session{
-		ID:        sessionID,
-		UserID:    userID,
-		Email:     email,
-		Role:      role,
-		CreatedAt: now,
-		ExpiresAt: now.Add(sessionTTL),
-		IPAddress: ipAddress,
-		UserAgent: userAgent,
-		Active:    true,
+// CreateForClient creates a session for an OAuth client acting for the user
+// signed in to browser. The new session inherits how the user authenticated
+// and is limited to scopes. Client sessions do not count toward the user's
+// concurrent session limit, so authorizing an app never ends or blocks a
+// sign-in.
+func (s *SessionService) CreateForClient(ctx context.Context, browser *Session, clientID string, scopes []string) (*Session, error) {
+	return s.create(ctx, &Session{
+		UserID:      browser.UserID,
+		Email:       browser.Email,
+		Role:        browser.Role,
+		IPAddress:   browser.IPAddress,
+		UserAgent:   browser.UserAgent,
+		DeviceID:    browser.DeviceID,
+		AuthMethods: browser.AuthMethods,
+		ClientID:    clientID,
+		Scopes:      scopes,
+	})
+}
+
+// create assigns a new session its ID and lifetime and saves it.
+func (s *SessionService) create(ctx context.Context, session *Session) (*Session, error) {
+	limits := s.cfg.LimitsFor(session.Role)
+	if session.ClientID == "" {
+		if err := s.enforceConcurrentLimit(ctx, session.UserID, limits); err != nil {
+			return nil, err
+		}
 	}
 
+	now := sessionNow()
+	session.ID = ids.NewSessionID()
+	session.CreatedAt = now
+	session.Active = true
+	session.LastActivityAt = now
+	session.MaxExpiresAt = now.Add(limits.MaxLifetime)
+	session.ExpiresAt = idleExpiry(session, limits.IdleTimeout)
+
 	s.logger.Info("creating session", logging.Fields{
-		"session_id": sessionID,
-		"user_id":    userID,
+		"session_id": session.ID,
+		"user_id":    session.UserID,
+		"client_id":  session.ClientID,
 	})
 
-	data, err := json.Marshal(session)
-	if err != nil {
-		return nil, err
-	}
-
-	key := sessionPrefix + sessionID
-	if err := s.client.Set(ctx, key, data, sessionTTL).Err(); err != nil {
+	if err := s.store.Save(ctx, session); err != nil {
 		s.logger.Error("failed to create session", logging.Fields{
 			"error": err.Error(),
 		})
 		return nil, err
 	}
 
-	// Also track sessions by user ID for listing
-	userSessionKey := "user_sessions:" + userID
-	s.client.SAdd(ctx, userSessionKey, sessionID)
-
 	return session, nil
 }
 
 // Get retrieves a session by ID.
 func (s *SessionService) Get(ctx context.Context, sessionID string) (*Session, error) {
-	key := sessionPrefix + sessionID
+	// Malformed IDs cannot name a session; skip the round trip
+	if !ids.IsSessionID(sessionID) {
+		return nil, ErrSessionNotFound
+	}
 
 	s.logger.Debug("getting session", logging.Fields{"session_id": sessionID})
 
-	data, err := s.client.Get(ctx, key).Bytes()
-	if err == redis.Nil {
-		return nil, ErrSessionNotFound
+	session, err := s.store.Get(ctx, sessionID)
+	if err == ErrSessionNotFound {
+		return nil, err
 	}
 	if err != nil {
 		logging.Errorf("failed to get session %s: %v", sessionID, err)
 		return nil, err
 	}
 
-	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, ErrSessionInvalid
-	}
-
 	if time.Now().After(session.ExpiresAt) {
 		return nil, ErrSessionExpired
 	}
@@ -134,114 +174,126 @@ func (s *SessionService) Get(ctx context.Context, sessionID string) (*Session, e
 		return nil, ErrSessionInvalid
 	}
 
-	return &session, nil
+	return session, nil
 }
 
 // Delete deletes a session (logout).
 func (s *SessionService) Delete(ctx context.Context, sessionID string) error {
 	s.logger.Info("deleting session", logging.Fields{"session_id": sessionID})
-
-	key := sessionPrefix + sessionID
-	if err := s.client.Del(ctx, key).Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return s.store.Delete(ctx, sessionID)
 }
 
 // DeleteAllForUser deletes all sessions for a user.
 func (s *SessionService) DeleteAllForUser(ctx context.Context, userID string) error {
 	s.logger.Info("deleting all sessions for user", logging.Fields{"user_id": userID})
-
-	userSessionKey := "user_sessions:" + userID
-	sessionIDs, err := s.client.SMembers(ctx, userSessionKey).Result()
-	if err != nil {
-		return err
-	}
-
-	for _, sessionID := range sessionIDs {
-		key := sessionPrefix + sessionID
-		s.client.Del(ctx, key)
-	}
-
-	s.client.Del(ctx, userSessionKey)
-
-	return nil
+	return s.store.DeleteAllForUser(ctx, userID)
 }
 
-// ListForUser lists all active sessions for a user.
+// ListForUser lists a user's active sessions, newest first.
 func (s *SessionService) ListForUser(ctx context.Context, userID string) ([]*Session, error) {
 	s.logger.Debug("listing sessions for user", logging.Fields{"user_id": userID})
 
-	userSessionKey := "user_sessions:" + userID
-	sessionIDs, err := s.client.SMembers(ctx, userSessionKey).Result()
+	all, err := s.store.ListForUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	sessions := []*Session{}
-	for _, sessionID := range sessionIDs {
-		session, err := s.Get(ctx, sessionID)
-		if err != nil {
-			// Remove expired/invalid sessions from set
-			s.client.SRem(ctx, userSessionKey, sessionID)
-			continue
+	for _, session := range all {
+		if session.Active && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
 		}
-		sessions = append(sessions, session)
 	}
 
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
 	return sessions, nil
 }
 
-// Refresh extends a session's expiration.
+// Refresh records activity on a session, extending its idle timeout up to
+// the session's absolute lifetime.
 func (s *SessionService) Refresh(ctx context.Context, sessionID string) error {
 	session, err := s.Get(ctx, sessionID)
 	if err != nil {
 		return err
 	}
 
-	session.ExpiresAt = time.Now().Add(sessionTTL)
+	return s.extend(ctx, session)
+}
 
-	data, err := json.Marshal(session)
-	if err != nil {
-		return err
+// Touch records activity on a session returned by Get, updating it in place.
+// Unlike Refresh it skips the write if the session was used very recently.
+func (s *SessionService) Touch(ctx context.Context, session *Session) error {
+	idle := s.cfg.LimitsFor(session.Role).IdleTimeout
+	interval := idle / 10
+	if interval > maxTouchInterval {
+		interval = maxTouchInterval
+	}
+	if time.Since(session.LastActivityAt) < interval {
+		return nil
 	}
 
-	key := sessionPrefix + sessionID
-	return s.client.Set(ctx, key, data, sessionTTL).Err()
+	return s.extend(ctx, session)
 }
 
-// Revoke marks a session as inactive without deleting it.
-func (s *SessionService) Revoke(ctx context.Context, sessionID string) error {
-	session, err := s.Get(ctx, sessionID)
-	if err != nil {
-		return err
+// extend slides a session's idle timeout forward from now. Only the
+// activity and expiry are written, and only while the session is still
+// active in the store, so a revocation since session was read stands.
+func (s *SessionService) extend(ctx context.Context, session *Session) error {
+	session.LastActivityAt = sessionNow()
+	if session.MaxExpiresAt.IsZero() {
+		// Sessions created before absolute lifetimes were recorded
+		session.MaxExpiresAt = session.CreatedAt.Add(s.cfg.LimitsFor(session.Role).MaxLifetime)
 	}
+	session.ExpiresAt = idleExpiry(session, s.cfg.LimitsFor(session.Role).IdleTimeout)
 
-	session.Active = false
+	return s.store.Extend(ctx, session.ID, session.LastActivityAt, session.ExpiresAt)
+}
 
-	data, err := json.Marshal(session)
-	if err != nil {
-		return err
+// Revoke marks a session as inactive without deleting it. Revoking an
+// already revoked session is not an error.
+func (s *SessionService) Revoke(ctx context.Context, sessionID string) error {
+	if !ids.IsSessionID(sessionID) {
+		return ErrSessionNotFound
 	}
 
-	key := sessionPrefix + sessionID
-	ttl := time.Until(session.ExpiresAt)
-	return s.client.Set(ctx, key, data, ttl).Err()
+	s.logger.Info("revoking session", logging.Fields{"session_id": sessionID})
+	return s.store.Revoke(ctx, sessionID)
+}
+
+// CountActive returns the number of unexpired, unrevoked sessions.
+func (s *SessionService) CountActive(ctx context.Context) (int64, error) {
+	return s.store.CountActive(ctx)
 }
 
-func generateSessionID() string {
-	// Simple session ID generation for demo
-	return "sess-" + randomSessionString(24)
+// SweepExpired deletes expired sessions and returns how many were removed.
+func (s *SessionService) SweepExpired(ctx context.Context) (int64, error) {
+	return s.store.DeleteExpired(ctx)
 }
 
-func randomSessionString(n int) string {
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[i%len(letters)]
+// RunSweeper deletes expired sessions every interval until ctx is done.
+func (s *SessionService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.SweepExpired(ctx)
+			if err != nil {
+				s.logger.Warn("failed to sweep expired sessions", logging.Fields{"error": err.Error()})
+				continue
+			}
+			if removed > 0 {
+				s.logger.Debug("swept expired sessions", logging.Fields{"removed": removed})
+			}
+		}
 	}
-	return string(b)
 }
 
 // ValidateSessionLegacy validates a legacy session.
@@ -264,7 +316,7 @@ func (s *SessionService) ValidateSessionLegacy(ctx context.Context, sessionID st
 func (s *SessionService) CreateSessionLegacy(ctx context.Context, userID string) (string, error) {
 	logging.Infof("creating legacy session for user: %s", userID)
 
-	session, err := s.Create(ctx, userID, "", "", "", "")
+	session, err := s.Create(ctx, userID, "", "", "", "", "", nil)
 	if err != nil {
 		return "", err
 	}
@@ -272,7 +324,69 @@ func (s *SessionService) CreateSessionLegacy(ctx context.Context, userID string)
 	return session.ID, nil
 }
 
+// enforceConcurrentLimit makes room for one more session for userID.
+func (s *SessionService) enforceConcurrentLimit(ctx context.Context, userID string, limits config.SessionLimits) error {
+	if limits.MaxConcurrent <= 0 {
+		return nil
+	}
+
+	// Concurrent logins may both pass this check; the limit is best effort
+	all, err := s.ListForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Only the user's own sign-ins count; OAuth client sessions are not
+	// evicted to make room
+	var sessions []*Session
+	for _, session := range all {
+		if session.ClientID == "" {
+			sessions = append(sessions, session)
+		}
+	}
+	if len(sessions) < limits.MaxConcurrent {
+		return nil
+	}
+
+	if limits.OnLimit == config.SessionLimitReject {
+		s.logger.Info("rejecting session over concurrent limit", logging.Fields{
+			"user_id": userID,
+			"limit":   limits.MaxConcurrent,
+		})
+		return ErrTooManySessions
+	}
+
+	// ListForUser is newest first, so the oldest sessions are at the end
+	for _, session := range sessions[limits.MaxConcurrent-1:] {
+		s.logger.Info("evicting session over concurrent limit", logging.Fields{
+			"session_id": session.ID,
+			"user_id":    userID,
+			"limit":      limits.MaxConcurrent,
+		})
+		if err := s.store.Delete(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionNow returns the current time as stored in sessions. Postgres stores
+// microseconds; truncating keeps every backend returning the same timestamps.
+func sessionNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// idleExpiry returns when a session expires if it is not used again: the
+// idle timeout after its last activity, capped at its absolute lifetime.
+func idleExpiry(session *Session, idleTimeout time.Duration) time.Time {
+	expiresAt := session.LastActivityAt.Add(idleTimeout)
+	if expiresAt.After(session.MaxExpiresAt) {
+		return session.MaxExpiresAt
+	}
+	return expiresAt
+}
+
 // Ping checks if the session store is accessible.
 func (s *SessionService) Ping(ctx context.Context) error {
-	return s.client.Ping(ctx).Err()
+	return s.store.Ping(ctx)
 }
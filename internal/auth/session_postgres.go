@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresSessionStore stores sessions in the sessions table. Expired rows
+// are hidden from reads and removed by DeleteExpired.
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresSessionStore creates a new Postgres-backed session store.
+func NewPostgresSessionStore(db *sql.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{db: db}
+}
+
+const sessionColumns = `id, user_id, email, role, created_at, expires_at, ip_address, user_agent, active, auth_methods, last_activity_at, max_expires_at, device_id, client_id, scopes`
+
+func (s *PostgresSessionStore) Save(ctx context.Context, session *Session) error {
+	query := `
+		INSERT INTO sessions (` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, active = EXCLUDED.active,
+			last_activity_at = EXCLUDED.last_activity_at, max_expires_at = EXCLUDED.max_expires_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.Email,
+		session.Role,
+		session.CreatedAt,
+		session.ExpiresAt,
+		session.IPAddress,
+		session.UserAgent,
+		session.Active,
+		pq.Array(session.AuthMethods),
+		session.LastActivityAt,
+		session.MaxExpiresAt,
+		session.DeviceID,
+		session.ClientID,
+		pq.Array(session.Scopes),
+	)
+	return err
+}
+
+func (s *PostgresSessionStore) Extend(ctx context.Context, id string, lastActivity, expiresAt time.Time) error {
+	query := `
+		UPDATE sessions SET last_activity_at = $2, expires_at = $3
+		WHERE id = $1 AND active AND expires_at > $4
+	`
+	return s.updateOne(ctx, query, id, lastActivity, expiresAt, time.Now().UTC())
+}
+
+func (s *PostgresSessionStore) Revoke(ctx context.Context, id string) error {
+	query := `UPDATE sessions SET active = false WHERE id = $1 AND expires_at > $2`
+	return s.updateOne(ctx, query, id, time.Now().UTC())
+}
+
+// updateOne runs an update of a single session and returns
+// ErrSessionNotFound if no row matched.
+func (s *PostgresSessionStore) updateOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *PostgresSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1 AND expires_at > $2`
+
+	session, err := scanSession(s.db.QueryRowContext(ctx, query, id, time.Now().UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+func (s *PostgresSessionStore) ListForUser(ctx context.Context, userID string) ([]*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE user_id = $1 AND expires_at > $2`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *PostgresSessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresSessionStore) DeleteAllForUser(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *PostgresSessionStore) CountActive(ctx context.Context) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM sessions WHERE active AND expires_at > $1`
+	err := s.db.QueryRowContext(ctx, query, time.Now().UTC()).Scan(&count)
+	return count, err
+}
+
+func (s *PostgresSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= $1`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *PostgresSessionStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+type sessionScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row sessionScanner) (*Session, error) {
+	var session Session
+	var ipAddress, userAgent sql.NullString
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.Email,
+		&session.Role,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&ipAddress,
+		&userAgent,
+		&session.Active,
+		pq.Array(&session.AuthMethods),
+		&session.LastActivityAt,
+		&session.MaxExpiresAt,
+		&session.DeviceID,
+		&session.ClientID,
+		pq.Array(&session.Scopes),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	session.IPAddress = ipAddress.String
+	session.UserAgent = userAgent.String
+	if len(session.AuthMethods) == 0 {
+		session.AuthMethods = nil
+	}
+	if len(session.Scopes) == 0 {
+		session.Scopes = nil
+	}
+	return &session, nil
+}
@@ -0,0 +1,464 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// Session store backends selectable with config.SessionConfig.Store.
+const (
+	SessionStoreRedis    = "redis"
+	SessionStorePostgres = "postgres"
+	SessionStoreMemory   = "memory"
+)
+
+// SessionStore persists sessions. Stores only hold and return sessions;
+// SessionService decides what an expired or revoked session means.
+type SessionStore interface {
+	// Save creates or replaces a session and keeps it until ExpiresAt.
+	Save(ctx context.Context, session *Session) error
+
+	// Extend records activity on an active session, setting only its last
+	// activity and expiry. It returns ErrSessionNotFound if the session does
+	// not exist, has expired or has been revoked, so a stale write can never
+	// bring back a deleted or revoked session.
+	Extend(ctx context.Context, id string, lastActivity, expiresAt time.Time) error
+
+	// Revoke marks a session inactive, leaving its other fields as stored.
+	// It returns ErrSessionNotFound if the session does not exist or has
+	// expired.
+	Revoke(ctx context.Context, id string) error
+
+	// Get returns a session. It returns ErrSessionNotFound if the session
+	// does not exist or has expired. Revoked sessions are returned.
+	Get(ctx context.Context, id string) (*Session, error)
+
+	// ListForUser returns a user's unexpired sessions, revoked ones
+	// included, in no particular order.
+	ListForUser(ctx context.Context, userID string) ([]*Session, error)
+
+	// Delete removes a session. Deleting a missing session is not an error.
+	Delete(ctx context.Context, id string) error
+
+	// DeleteAllForUser removes every session belonging to a user.
+	DeleteAllForUser(ctx context.Context, userID string) error
+
+	// CountActive returns the number of unexpired, unrevoked sessions.
+	CountActive(ctx context.Context) (int64, error)
+
+	// DeleteExpired removes expired sessions and returns how many it
+	// removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+}
+
+const (
+	sessionPrefix     = "session:"
+	userSessionPrefix = "user_sessions:"
+
+	// activeSessionsKey is a sorted set of active session IDs scored by
+	// expiry time in Unix milliseconds, used to count sessions without
+	// scanning the keyspace. Entries scored in seconds by older releases
+	// sort as long expired and are pruned on the next sweep.
+	activeSessionsKey = "sessions:active"
+
+	// maxSessionUpdateRetries bounds optimistic-locking retries when a
+	// session is extended and revoked at the same time.
+	maxSessionUpdateRetries = 3
+)
+
+// RedisSessionStore stores sessions in Redis. Session keys expire with the
+// session; a per-user set indexes them for listing.
+type RedisSessionStore struct {
+	client *redis.Client
+	logger *logging.LoggerV2
+}
+
+// NewRedisSessionStore creates a new Redis-backed session store.
+func NewRedisSessionStore(cfg config.RedisConfig) *RedisSessionStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisSessionStore{
+		client: client,
+		logger: logging.NewLoggerV2("session-store"),
+	}
+}
+
+func (s *RedisSessionStore) Save(ctx context.Context, session *Session) error {
+	ttl := time.Until(session.ExpiresAt)
+	if ttl <= 0 {
+		// A non-positive TTL would keep the key forever
+		return s.Delete(ctx, session.ID)
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	if err := s.client.Set(ctx, sessionPrefix+session.ID, data, ttl).Err(); err != nil {
+		return err
+	}
+	s.client.SAdd(ctx, userSessionPrefix+session.UserID, session.ID)
+
+	if session.Active {
+		s.trackActive(ctx, session)
+	} else {
+		s.client.ZRem(ctx, activeSessionsKey, session.ID)
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) Extend(ctx context.Context, id string, lastActivity, expiresAt time.Time) error {
+	return s.update(ctx, id, func(session *Session) (time.Duration, error) {
+		if !session.Active {
+			return 0, ErrSessionNotFound
+		}
+		session.LastActivityAt = lastActivity
+		session.ExpiresAt = expiresAt
+		return time.Until(expiresAt), nil
+	})
+}
+
+func (s *RedisSessionStore) Revoke(ctx context.Context, id string) error {
+	return s.update(ctx, id, func(session *Session) (time.Duration, error) {
+		session.Active = false
+		return redis.KeepTTL, nil
+	})
+}
+
+// update changes a stored session in a WATCH transaction, so a concurrent
+// revocation or deletion is never overwritten. apply returns the key's new
+// TTL.
+func (s *RedisSessionStore) update(ctx context.Context, id string, apply func(session *Session) (time.Duration, error)) error {
+	key := sessionPrefix + id
+
+	txf := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			return ErrSessionInvalid
+		}
+		if !time.Now().Before(session.ExpiresAt) {
+			return ErrSessionNotFound
+		}
+
+		ttl, err := apply(&session)
+		if err != nil {
+			return err
+		}
+		if ttl != redis.KeepTTL && ttl <= 0 {
+			return ErrSessionNotFound
+		}
+		updated, err := json.Marshal(&session)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, ttl)
+			if session.Active {
+				pipe.ZAdd(ctx, activeSessionsKey, redis.Z{
+					Score:  float64(session.ExpiresAt.UnixMilli()),
+					Member: session.ID,
+				})
+			} else {
+				pipe.ZRem(ctx, activeSessionsKey, session.ID)
+			}
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxSessionUpdateRetries; i++ {
+		err := s.client.Watch(ctx, txf, key)
+		if err == redis.TxFailedErr {
+			// The session changed underneath us; retry against the new state
+			continue
+		}
+		return err
+	}
+
+	s.logger.Warn("session update retries exhausted", logging.Fields{"session_id": id})
+	return redis.TxFailedErr
+}
+
+func (s *RedisSessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	data, err := s.client.Get(ctx, sessionPrefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, ErrSessionInvalid
+	}
+	if !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (s *RedisSessionStore) ListForUser(ctx context.Context, userID string) ([]*Session, error) {
+	userSessionKey := userSessionPrefix + userID
+	sessionIDs, err := s.client.SMembers(ctx, userSessionKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*Session{}
+	for _, sessionID := range sessionIDs {
+		session, err := s.Get(ctx, sessionID)
+		if err == ErrSessionNotFound || err == ErrSessionInvalid {
+			// Drop expired and unreadable sessions from the index
+			s.client.SRem(ctx, userSessionKey, sessionID)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	if err := s.client.Del(ctx, sessionPrefix+id).Err(); err != nil {
+		return err
+	}
+	s.client.ZRem(ctx, activeSessionsKey, id)
+	return nil
+}
+
+func (s *RedisSessionStore) DeleteAllForUser(ctx context.Context, userID string) error {
+	userSessionKey := userSessionPrefix + userID
+	sessionIDs, err := s.client.SMembers(ctx, userSessionKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		s.client.Del(ctx, sessionPrefix+sessionID)
+		s.client.ZRem(ctx, activeSessionsKey, sessionID)
+	}
+
+	return s.client.Del(ctx, userSessionKey).Err()
+}
+
+// CountActive counts the active-session index. Sessions created before the
+// index was added are not counted.
+func (s *RedisSessionStore) CountActive(ctx context.Context) (int64, error) {
+	if _, err := s.DeleteExpired(ctx); err != nil {
+		return 0, err
+	}
+	return s.client.ZCard(ctx, activeSessionsKey).Result()
+}
+
+// DeleteExpired prunes the active-session index. Redis expires the session
+// keys themselves.
+func (s *RedisSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return s.client.ZRemRangeByScore(ctx, activeSessionsKey, "-inf", "("+now).Result()
+}
+
+func (s *RedisSessionStore) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// trackActive records a session and its expiry in the active-session index.
+func (s *RedisSessionStore) trackActive(ctx context.Context, session *Session) {
+	err := s.client.ZAdd(ctx, activeSessionsKey, redis.Z{
+		Score:  float64(session.ExpiresAt.UnixMilli()),
+		Member: session.ID,
+	}).Err()
+	if err != nil {
+		s.logger.Warn("failed to track active session", logging.Fields{
+			"session_id": session.ID,
+			"error":      err.Error(),
+		})
+	}
+}
+
+// InMemorySessionStore is a session store for tests and single-node
+// development. Sessions are lost on restart.
+type InMemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+	byUser   map[string]map[string]struct{}
+}
+
+func NewInMemorySessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{
+		sessions: make(map[string]Session),
+		byUser:   make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *InMemorySessionStore) Save(ctx context.Context, session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *session
+	stored.AuthMethods = append([]string(nil), session.AuthMethods...)
+	stored.Scopes = append([]string(nil), session.Scopes...)
+	s.sessions[session.ID] = stored
+
+	if s.byUser[session.UserID] == nil {
+		s.byUser[session.UserID] = make(map[string]struct{})
+	}
+	s.byUser[session.UserID][session.ID] = struct{}{}
+	return nil
+}
+
+func (s *InMemorySessionStore) Extend(ctx context.Context, id string, lastActivity, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !session.Active || !time.Now().Before(session.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+	session.LastActivityAt = lastActivity
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *InMemorySessionStore) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return ErrSessionNotFound
+	}
+	session.Active = false
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *InMemorySessionStore) Get(ctx context.Context, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !time.Now().Before(session.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+func (s *InMemorySessionStore) ListForUser(ctx context.Context, userID string) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	sessions := []*Session{}
+	for id := range s.byUser[userID] {
+		session, ok := s.sessions[id]
+		if ok && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	return sessions, nil
+}
+
+func (s *InMemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteLocked(id)
+	return nil
+}
+
+func (s *InMemorySessionStore) DeleteAllForUser(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.byUser[userID] {
+		delete(s.sessions, id)
+	}
+	delete(s.byUser, userID)
+	return nil
+}
+
+func (s *InMemorySessionStore) CountActive(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var count int64
+	for _, session := range s.sessions {
+		if session.Active && now.Before(session.ExpiresAt) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *InMemorySessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for id, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			s.deleteLocked(id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (s *InMemorySessionStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *InMemorySessionStore) deleteLocked(id string) {
+	session, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	delete(s.sessions, id)
+	delete(s.byUser[session.UserID], id)
+	if len(s.byUser[session.UserID]) == 0 {
+		delete(s.byUser, session.UserID)
+	}
+}
+
+func copySession(session Session) *Session {
+	session.AuthMethods = append([]string(nil), session.AuthMethods...)
+	session.Scopes = append([]string(nil), session.Scopes...)
+	return &session
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ SessionStore = (*RedisSessionStore)(nil)
+	_ SessionStore = (*PostgresSessionStore)(nil)
+	_ SessionStore = (*InMemorySessionStore)(nil)
+)
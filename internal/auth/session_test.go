@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func testSessionConfig() config.SessionConfig {
+	return config.SessionConfig{
+		Limits: config.SessionLimits{
+			IdleTimeout: time.Hour,
+			MaxLifetime: 24 * time.Hour,
+			OnLimit:     config.SessionLimitEvict,
+		},
+	}
+}
+
+func TestSessionLifetime(t *testing.T) {
+	ctx := context.Background()
+	cfg := testSessionConfig()
+	cfg.RoleLimits = map[string]config.SessionLimits{
+		"admin": {IdleTimeout: 15 * time.Minute, MaxLifetime: 30 * time.Minute},
+	}
+	svc := NewSessionService(NewInMemorySessionStore(), cfg)
+
+	customer, _ := svc.Create(ctx, "user-1", "", "customer", "", "", "", nil)
+	if want := customer.CreatedAt.Add(time.Hour); !customer.ExpiresAt.Equal(want) {
+		t.Fatalf("expected customer session to expire at %v, got %v", want, customer.ExpiresAt)
+	}
+	if want := customer.CreatedAt.Add(24 * time.Hour); !customer.MaxExpiresAt.Equal(want) {
+		t.Fatalf("expected customer session lifetime to end at %v, got %v", want, customer.MaxExpiresAt)
+	}
+
+	admin, _ := svc.Create(ctx, "user-2", "", "admin", "", "", "", nil)
+	if want := admin.CreatedAt.Add(15 * time.Minute); !admin.ExpiresAt.Equal(want) {
+		t.Fatalf("expected admin session to expire at %v, got %v", want, admin.ExpiresAt)
+	}
+
+	// Pretend the admin session has been in use for 20 minutes: the idle
+	// timeout may only slide to the end of its 30 minute lifetime
+	admin.CreatedAt = admin.CreatedAt.Add(-20 * time.Minute)
+	admin.MaxExpiresAt = admin.MaxExpiresAt.Add(-20 * time.Minute)
+	svc.store.Save(ctx, admin)
+
+	if err := svc.Refresh(ctx, admin.ID); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, _ := svc.Get(ctx, admin.ID)
+	if !got.ExpiresAt.Equal(admin.MaxExpiresAt) {
+		t.Fatalf("expected expiry capped at %v, got %v", admin.MaxExpiresAt, got.ExpiresAt)
+	}
+}
+
+func TestSessionTouch(t *testing.T) {
+	ctx := context.Background()
+	svc := NewSessionService(NewInMemorySessionStore(), testSessionConfig())
+
+	session, _ := svc.Create(ctx, "user-1", "", "customer", "", "", "", nil)
+	created := *session
+
+	// Activity within a minute of the last is not written back
+	if err := svc.Touch(ctx, session); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !session.LastActivityAt.Equal(created.LastActivityAt) {
+		t.Fatalf("expected recent activity to be skipped, got %v", session.LastActivityAt)
+	}
+
+	session.LastActivityAt = session.LastActivityAt.Add(-10 * time.Minute)
+	if err := svc.Touch(ctx, session); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got, _ := svc.Get(ctx, session.ID)
+	if !got.LastActivityAt.After(created.LastActivityAt) || !got.ExpiresAt.After(created.ExpiresAt) {
+		t.Fatalf("expected activity to extend the session, got %+v", got)
+	}
+}
+
+func TestSessionConcurrentLimit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("evict", func(t *testing.T) {
+		cfg := testSessionConfig()
+		cfg.Limits.MaxConcurrent = 2
+		svc := NewSessionService(NewInMemorySessionStore(), cfg)
+
+		var created []*Session
+		for i := 0; i < 3; i++ {
+			session, err := svc.Create(ctx, "user-1", "", "customer", "", "", "", nil)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			created = append(created, session)
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		sessions, _ := svc.ListForUser(ctx, "user-1")
+		want := []string{created[2].ID, created[1].ID}
+		if got := sessionIDs(sessions); !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected oldest session to be evicted leaving %v, got %v", want, got)
+		}
+		if _, err := svc.Get(ctx, created[0].ID); err != ErrSessionNotFound {
+			t.Fatalf("expected evicted session to be gone, got %v", err)
+		}
+	})
+
+	t.Run("client sessions do not count", func(t *testing.T) {
+		cfg := testSessionConfig()
+		cfg.Limits.MaxConcurrent = 1
+		cfg.Limits.OnLimit = config.SessionLimitReject
+		svc := NewSessionService(NewInMemorySessionStore(), cfg)
+
+		browser, err := svc.Create(ctx, "user-1", "", "customer", "", "", "", nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, clientID := range []string{"client-a", "client-b"} {
+			if _, err := svc.CreateForClient(ctx, browser, clientID, nil); err != nil {
+				t.Fatalf("expected client session to be created, got %v", err)
+			}
+		}
+		if _, err := svc.Get(ctx, browser.ID); err != nil {
+			t.Fatalf("expected browser session to survive, got %v", err)
+		}
+
+		// The client sessions are listed but leave the user's one sign-in
+		// slot to the browser session
+		sessions, _ := svc.ListForUser(ctx, "user-1")
+		if len(sessions) != 3 {
+			t.Fatalf("expected 3 sessions, got %d", len(sessions))
+		}
+		if _, err := svc.Create(ctx, "user-1", "", "customer", "", "", "", nil); err != ErrTooManySessions {
+			t.Fatalf("expected ErrTooManySessions, got %v", err)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		cfg := testSessionConfig()
+		cfg.RoleLimits = map[string]config.SessionLimits{
+			"admin": {IdleTimeout: time.Hour, MaxLifetime: time.Hour, MaxConcurrent: 1, OnLimit: config.SessionLimitReject},
+		}
+		svc := NewSessionService(NewInMemorySessionStore(), cfg)
+
+		first, err := svc.Create(ctx, "user-1", "", "admin", "", "", "", nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := svc.Create(ctx, "user-1", "", "admin", "", "", "", nil); err != ErrTooManySessions {
+			t.Fatalf("expected ErrTooManySessions, got %v", err)
+		}
+		if _, err := svc.Get(ctx, first.ID); err != nil {
+			t.Fatalf("expected existing session to survive, got %v", err)
+		}
+
+		// Revoked sessions do not count towards the limit
+		svc.Revoke(ctx, first.ID)
+		if _, err := svc.Create(ctx, "user-1", "", "admin", "", "", "", nil); err != nil {
+			t.Fatalf("expected login after revoking to succeed, got %v", err)
+		}
+
+		// Other roles are unlimited
+		for i := 0; i < 3; i++ {
+			if _, err := svc.Create(ctx, "user-2", "", "customer", "", "", "", nil); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 test key from RFC 6238 Appendix B, base32-encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if code != tt.code {
+			t.Fatalf("at %d: expected %s, got %s", tt.unix, tt.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now)
+	if !ok {
+		t.Fatal("expected current code to be valid")
+	}
+	if step != now.Unix()/30 {
+		t.Fatalf("expected step %d, got %d", now.Unix()/30, step)
+	}
+
+	t.Run("previous period accepted", func(t *testing.T) {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now.Add(30*time.Second)); !ok {
+			t.Fatal("expected code from previous period to be valid")
+		}
+	})
+
+	t.Run("old code rejected", func(t *testing.T) {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now.Add(2*time.Minute)); ok {
+			t.Fatal("expected old code to be rejected")
+		}
+	})
+
+	t.Run("wrong code rejected", func(t *testing.T) {
+		wrong := "000000"
+		if code == wrong {
+			wrong = "111111"
+		}
+		if _, ok := ValidateTOTP(rfc6238Secret, wrong, now); ok {
+			t.Fatal("expected wrong code to be rejected")
+		}
+	})
+
+	t.Run("malformed secret rejected", func(t *testing.T) {
+		if _, ok := ValidateTOTP("not base32!", code, now); ok {
+			t.Fatal("expected malformed secret to be rejected")
+		}
+	})
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(secret) != 32 {
+		t.Fatalf("expected 32 base32 characters, got %d", len(secret))
+	}
+
+	uri := TOTPProvisioningURI("Acme Shop", "jane@example.com", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Acme%20Shop:jane@example.com?") {
+		t.Fatalf("unexpected provisioning URI: %s", uri)
+	}
+	if !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("expected provisioning URI to contain the secret: %s", uri)
+	}
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const testOrigin = "https://shop.example.com"
+
+func newTestWebAuthn() *WebAuthn {
+	return NewWebAuthn(NewInMemoryWebAuthnChallengeStore(), config.WebAuthnConfig{
+		RPID:             "shop.example.com",
+		RPName:           "Acme Shop",
+		Origins:          []string{testOrigin},
+		Timeout:          time.Minute,
+		UserVerification: "required",
+	})
+}
+
+// fakeAuthenticator is a software ES256 authenticator holding one passkey.
+type fakeAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	flags        byte
+}
+
+func newFakeAuthenticator(t *testing.T) *fakeAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return &fakeAuthenticator{
+		key:          key,
+		credentialID: []byte("credential-0001"),
+		flags:        authDataUserPresent | authDataUserVerified,
+	}
+}
+
+func (a *fakeAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	var buf bytes.Buffer
+	buf.WriteByte(0xa5)
+	buf.Write([]byte{0x01, 0x02, 0x03, 0x26, 0x20, 0x01})
+	buf.Write([]byte{0x21, 0x58, 0x20})
+	buf.Write(x)
+	buf.Write([]byte{0x22, 0x58, 0x20})
+	buf.Write(y)
+	return buf.Bytes()
+}
+
+func (a *fakeAuthenticator) authData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := a.flags
+
+	var buf bytes.Buffer
+	buf.Write(rpIDHash[:])
+	if attested {
+		flags |= authDataAttested
+	}
+	buf.WriteByte(flags)
+	binary.Write(&buf, binary.BigEndian, a.signCount)
+	if attested {
+		buf.Write(make([]byte, 16))
+		binary.Write(&buf, binary.BigEndian, uint16(len(a.credentialID)))
+		buf.Write(a.credentialID)
+		buf.Write(a.coseKey())
+	}
+	return buf.Bytes()
+}
+
+func clientDataJSON(clientType, challenge, origin string) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      clientType,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return data
+}
+
+func (a *fakeAuthenticator) create(opts *CredentialCreationOptions, origin string) *RegistrationResponse {
+	a.userHandle, _ = decodeBase64URL(opts.User.ID)
+	authData := a.authData(opts.RP.ID, true)
+
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	var attObj bytes.Buffer
+	attObj.WriteByte(0xa3)
+	attObj.Write([]byte{0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e'})
+	attObj.Write([]byte{0x67, 'a', 't', 't', 'S', 't', 'm', 't', 0xa0})
+	attObj.Write([]byte{0x68, 'a', 'u', 't', 'h', 'D', 'a', 't', 'a', 0x59})
+	binary.Write(&attObj, binary.BigEndian, uint16(len(authData)))
+	attObj.Write(authData)
+
+	resp := &RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: base64.RawURLEncoding.EncodeToString(a.credentialID),
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientDataJSON("webauthn.create", opts.Challenge, origin))
+	resp.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(attObj.Bytes())
+	resp.Response.Transports = []string{"internal"}
+	return resp
+}
+
+func (a *fakeAuthenticator) get(t *testing.T, opts *CredentialRequestOptions, origin string) *AssertionResponse {
+	a.signCount++
+	authData := a.authData(opts.RPID, false)
+	clientData := clientDataJSON("webauthn.get", opts.Challenge, origin)
+	clientDataHash := sha256.Sum256(clientData)
+
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	resp := &AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: base64.RawURLEncoding.EncodeToString(a.credentialID),
+		Type:  "public-key",
+	}
+	resp.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientData)
+	resp.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	resp.Response.Signature = base64.RawURLEncoding.EncodeToString(sig)
+	resp.Response.UserHandle = base64.RawURLEncoding.EncodeToString(a.userHandle)
+	return resp
+}
+
+func registerFakeAuthenticator(t *testing.T, w *WebAuthn, a *fakeAuthenticator) *VerifiedCredential {
+	ctx := context.Background()
+	opts, err := w.BeginRegistration(ctx, WebAuthnUser{ID: "user-1", Name: "jane@example.com"}, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	cred, err := w.FinishRegistration(ctx, "user-1", a.create(opts, testOrigin))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return cred
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	w := newTestWebAuthn()
+	a := newFakeAuthenticator(t)
+
+	cred := registerFakeAuthenticator(t, w, a)
+	if cred.ID != base64.RawURLEncoding.EncodeToString(a.credentialID) {
+		t.Fatalf("expected credential ID to match, got %s", cred.ID)
+	}
+	if !cred.UserVerified {
+		t.Fatal("expected user to be verified")
+	}
+	if _, _, err := parseCOSEKey(cred.PublicKey); err != nil {
+		t.Fatalf("expected stored public key to parse, got %v", err)
+	}
+
+	t.Run("challenge reuse", func(t *testing.T) {
+		ctx := context.Background()
+		opts, _ := w.BeginRegistration(ctx, WebAuthnUser{ID: "user-1"}, nil)
+		resp := newFakeAuthenticator(t).create(opts, testOrigin)
+
+		if _, err := w.FinishRegistration(ctx, "user-1", resp); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := w.FinishRegistration(ctx, "user-1", resp); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("different user", func(t *testing.T) {
+		ctx := context.Background()
+		opts, _ := w.BeginRegistration(ctx, WebAuthnUser{ID: "user-1"}, nil)
+		resp := newFakeAuthenticator(t).create(opts, testOrigin)
+
+		if _, err := w.FinishRegistration(ctx, "user-2", resp); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		ctx := context.Background()
+		opts, _ := w.BeginRegistration(ctx, WebAuthnUser{ID: "user-1"}, nil)
+		resp := newFakeAuthenticator(t).create(opts, "https://evil.example.com")
+
+		if _, err := w.FinishRegistration(ctx, "user-1", resp); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("user not verified", func(t *testing.T) {
+		ctx := context.Background()
+		opts, _ := w.BeginRegistration(ctx, WebAuthnUser{ID: "user-1"}, nil)
+		other := newFakeAuthenticator(t)
+		other.flags = authDataUserPresent
+
+		if _, err := w.FinishRegistration(ctx, "user-1", other.create(opts, testOrigin)); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+}
+
+func TestWebAuthnAssertion(t *testing.T) {
+	ctx := context.Background()
+	w := newTestWebAuthn()
+	a := newFakeAuthenticator(t)
+	cred := registerFakeAuthenticator(t, w, a)
+
+	opts, err := w.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	resp := a.get(t, opts, testOrigin)
+
+	assertion, err := w.ParseAssertion(ctx, resp)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if assertion.CredentialID != cred.ID {
+		t.Fatalf("expected credential %s, got %s", cred.ID, assertion.CredentialID)
+	}
+	if assertion.UserID != "user-1" {
+		t.Fatalf("expected user-1, got %s", assertion.UserID)
+	}
+	if err := assertion.Verify(cred.PublicKey, cred.SignCount); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("challenge reuse", func(t *testing.T) {
+		if _, err := w.ParseAssertion(ctx, resp); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("sign count", func(t *testing.T) {
+		opts, _ := w.BeginLogin(ctx)
+		assertion, err := w.ParseAssertion(ctx, a.get(t, opts, testOrigin))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := assertion.Verify(cred.PublicKey, a.signCount); err != ErrWebAuthnSignCount {
+			t.Fatalf("expected ErrWebAuthnSignCount, got %v", err)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		opts, _ := w.BeginLogin(ctx)
+		assertion, err := w.ParseAssertion(ctx, a.get(t, opts, testOrigin))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		other := newFakeAuthenticator(t)
+		if err := assertion.Verify(other.coseKey(), 0); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		opts, _ := w.BeginLogin(ctx)
+		if _, err := w.ParseAssertion(ctx, a.get(t, opts, "https://evil.example.com")); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+
+	t.Run("wrong RP ID", func(t *testing.T) {
+		opts, _ := w.BeginLogin(ctx)
+		opts.RPID = "evil.example.com"
+		if _, err := w.ParseAssertion(ctx, a.get(t, opts, testOrigin)); err != ErrWebAuthnInvalidResponse {
+			t.Fatalf("expected ErrWebAuthnInvalidResponse, got %v", err)
+		}
+	})
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// {1: -7, "a": [h'0102', true]}
+	data := []byte{0xa2, 0x01, 0x26, 0x61, 'a', 0x82, 0x42, 0x01, 0x02, 0xf5}
+
+	v, n, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if n != len(data) {
+		t.Fatalf("expected %d bytes consumed, got %d", len(data), n)
+	}
+
+	m := v.(map[interface{}]interface{})
+	if m[int64(1)] != int64(-7) {
+		t.Fatalf("expected -7, got %v", m[int64(1)])
+	}
+	arr := m["a"].([]interface{})
+	if !bytes.Equal(arr[0].([]byte), []byte{1, 2}) || arr[1] != true {
+		t.Fatalf("unexpected array %v", arr)
+	}
+
+	for _, bad := range [][]byte{
+		{0xa1, 0x01},       // truncated map
+		{0x5f, 0x41, 0x00}, // indefinite-length byte string
+		{0xc2, 0x40},       // tagged value
+	} {
+		if _, _, err := decodeCBOR(bad); err == nil {
+			t.Fatalf("expected error decoding %x", bad)
+		}
+	}
+}
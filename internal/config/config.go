@@ -4,18 +4,32 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	ServiceName    string
-	ServiceVersion string
-	Environment    string
-	Server         ServerConfig
-	Database       DatabaseConfig
-	Redis          RedisConfig
-	JWT            JWTConfig
-	Features       FeatureFlags
+	ServiceName       string
+	ServiceVersion    string
+	Environment       string
+	Server            ServerConfig
+	Database          DatabaseConfig
+	Redis             RedisConfig
+	Sessions          SessionConfig
+	JWT               JWTConfig
+	Lockout           LockoutConfig
+	PasswordHash      PasswordHashConfig
+	PasswordPolicy    PasswordPolicyConfig
+	BreachedPasswords BreachedPasswordsConfig
+	PasswordReset     PasswordResetConfig
+	EmailVerification EmailVerificationConfig
+	MFA               MFAConfig
+	WebAuthn          WebAuthnConfig
+	OIDC              OIDCConfig
+	Federation        FederationConfig
+	LegacySSO         LegacySSOConfig
+	RateLimit         RateLimitConfig
+	Features          FeatureFlags
 }
 
 type ServerConfig struct {
@@ -57,10 +71,306 @@ func (r *RedisConfig) Addr() string {
 	return fmt.Sprintf("%s:%d", r.Host, r.Port)
 }
 
+// SessionConfig selects where sessions are stored.
+type SessionConfig struct {
+	// Store is "redis", "postgres" or "memory". The in-memory store is not
+	// shared between replicas and loses every session on restart.
+	Store string
+
+	// SweepInterval is how often expired sessions are deleted.
+	SweepInterval time.Duration
+
+	// Limits applies to roles without an entry in RoleLimits.
+	Limits SessionLimits
+
+	// RoleLimits overrides Limits for specific roles, e.g. to give admin
+	// sessions a shorter idle timeout than customer sessions.
+	RoleLimits map[string]SessionLimits
+}
+
+// Actions taken when a user already has the maximum number of sessions.
+const (
+	SessionLimitEvict  = "evict"
+	SessionLimitReject = "reject"
+)
+
+// SessionLimits bounds how long sessions live and how many a user may hold.
+type SessionLimits struct {
+	// IdleTimeout ends a session this long after it was last used. Each use
+	// extends it, up to MaxLifetime.
+	IdleTimeout time.Duration
+
+	// MaxLifetime ends a session this long after login, however active.
+	MaxLifetime time.Duration
+
+	// MaxConcurrent is the number of sessions a user may hold at once. Zero
+	// means no limit.
+	MaxConcurrent int
+
+	// OnLimit is "evict", which ends the user's oldest session to make room
+	// for a new login, or "reject", which refuses the new login.
+	OnLimit string
+}
+
+// LimitsFor returns the session limits for role.
+func (s *SessionConfig) LimitsFor(role string) SessionLimits {
+	if limits, ok := s.RoleLimits[role]; ok {
+		return limits
+	}
+	return s.Limits
+}
+
 type JWTConfig struct {
 	Secret     string
 	Expiration time.Duration
 	Issuer     string
+
+	// RefreshExpiration is the lifetime of each opaque refresh token.
+	RefreshExpiration time.Duration
+
+	// SigningKeys maps key IDs to PEM-encoded key files. Keys other than
+	// ActiveKeyID only verify tokens, which allows rotation without
+	// invalidating tokens already issued.
+	SigningKeys map[string]string
+
+	// ActiveKeyID selects the key in SigningKeys used to sign new tokens.
+	ActiveKeyID string
+
+	// AcceptLegacyHMAC keeps accepting HS256 tokens signed with Secret after
+	// switching to asymmetric keys.
+	// TODO(TEAM-SEC): Remove once all HS256 tokens have expired
+	AcceptLegacyHMAC bool
+}
+
+// PasswordHashConfig selects the algorithm and cost for new password hashes.
+// Existing hashes with a different algorithm or weaker parameters are
+// rehashed at the user's next login when password migration is enabled.
+type PasswordHashConfig struct {
+	// Algorithm is "bcrypt" or "argon2id".
+	Algorithm string
+
+	BcryptCost int
+
+	// Argon2Memory is the argon2id memory cost in KiB.
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+}
+
+// PasswordPolicyConfig is the policy new passwords must meet when users
+// register, change or reset their password.
+type PasswordPolicyConfig struct {
+	// MinLength and MaxLength bound the password length in bytes, within
+	// 8-72 (bcrypt ignores anything past 72 bytes).
+	MinLength int
+	MaxLength int
+
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	// MinScore is the minimum auth.PasswordStrength score, from 0 to 4.
+	MinScore int
+
+	// DenyListFile is a local file of common passwords, one per line, that
+	// are always rejected. Empty disables the deny-list.
+	DenyListFile string
+
+	// HistorySize is how many previous passwords a new one may not repeat.
+	// Zero only rejects the current password.
+	HistorySize int
+}
+
+// BreachedPasswordsConfig points at a local copy of a breached-password
+// corpus in Have I Been Pwned range format: one file per 5-character SHA-1
+// prefix, named <PREFIX>.txt, holding "<SUFFIX>:<COUNT>" lines.
+type BreachedPasswordsConfig struct {
+	// Mode is "range" to read range files on each lookup, "bloom" to build
+	// an in-memory Bloom filter from them at startup, or empty to disable
+	// screening.
+	Mode string
+
+	// Dir is the directory holding the range files.
+	Dir string
+
+	// MinCount ignores passwords seen in fewer than this many breaches.
+	MinCount int
+}
+
+// LockoutConfig controls how failed logins lock accounts and throttle clients.
+type LockoutConfig struct {
+	// MaxAccountFailures is the number of failures within FailureWindow that
+	// locks an account. Zero disables account lockout.
+	MaxAccountFailures int
+
+	// MaxIPFailures is the number of failures within FailureWindow after which
+	// a client IP is refused until the window ends. Zero disables the limit.
+	MaxIPFailures int
+
+	// FailureWindow is how long failures are counted before they expire.
+	FailureWindow time.Duration
+
+	// LockoutDuration is the length of the first lockout. Each consecutive
+	// lockout doubles it, up to MaxLockoutDuration.
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+}
+
+// PasswordResetConfig controls self-service password reset.
+type PasswordResetConfig struct {
+	// TokenTTL is how long a reset token stays valid after it is issued.
+	TokenTTL time.Duration
+
+	// URL is the frontend page that completes the reset. The token is
+	// appended as the "token" query parameter.
+	URL string
+}
+
+// EmailVerificationConfig controls email address verification.
+type EmailVerificationConfig struct {
+	// Secret signs verification tokens. Rotating it invalidates links that
+	// have already been sent.
+	Secret string
+
+	// TokenTTL is how long a verification link stays valid.
+	TokenTTL time.Duration
+
+	// URL is the frontend page that submits the token. The token is appended
+	// as the "token" query parameter.
+	URL string
+}
+
+// MFAConfig controls multi-factor authentication.
+type MFAConfig struct {
+	// Issuer is the account label shown in authenticator apps.
+	Issuer string
+
+	// EncryptionKey encrypts TOTP secrets and keys recovery code digests.
+	// Rotating it invalidates every existing enrollment.
+	EncryptionKey string
+
+	// RequiredRoles lists roles that must use MFA. Users with these roles can
+	// only reach MFA enrollment until they have enabled it.
+	RequiredRoles []string
+
+	// ChallengeTTL is how long a login may wait for its second factor.
+	ChallengeTTL time.Duration
+
+	// MaxAttempts is the number of wrong codes after which a challenge is
+	// discarded and the password must be entered again.
+	MaxAttempts int
+}
+
+// RoleRequiresMFA reports whether users with role must use MFA.
+func (m *MFAConfig) RoleRequiresMFA(role string) bool {
+	for _, r := range m.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// WebAuthnConfig controls passkey registration and login.
+type WebAuthnConfig struct {
+	// RPID is the relying party ID: the registrable domain passkeys are
+	// bound to. Changing it invalidates every registered passkey.
+	RPID   string
+	RPName string
+
+	// Origins lists the exact origins (scheme://host[:port]) the frontend is
+	// served from. Responses from any other origin are rejected.
+	Origins []string
+
+	// Timeout is how long a ceremony may take before its challenge expires.
+	Timeout time.Duration
+
+	// UserVerification is "required", "preferred" or "discouraged".
+	UserVerification string
+}
+
+// OIDCConfig controls the OpenID Connect provider that lets other
+// applications sign users in with their Acme Shop account.
+type OIDCConfig struct {
+	Enabled bool
+
+	// Issuer is the provider's https URL. It is published in the discovery
+	// document and must match the iss claim of every ID token.
+	Issuer string
+
+	// CodeTTL is how long an authorization code may wait to be redeemed.
+	CodeTTL time.Duration
+}
+
+// FederationConfig controls sign-in through external OpenID Connect
+// identity providers, such as a vendor's corporate IdP.
+type FederationConfig struct {
+	Providers []FederatedProviderConfig
+
+	// StateTTL is how long a user has to complete sign-in at the provider.
+	StateTTL time.Duration
+}
+
+// FederatedProviderConfig configures one upstream identity provider.
+type FederatedProviderConfig struct {
+	// ID names the provider in API paths and linked identities.
+	ID   string
+	Name string
+
+	// Issuer is the provider's issuer URL; its discovery document is read
+	// from Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// RedirectURL is the frontend page the provider returns the user to.
+	// It must be registered with the provider.
+	RedirectURL string
+
+	// Provision creates accounts for users with no local account, with
+	// role DefaultRole.
+	Provision   bool
+	DefaultRole string
+
+	// AllowedDomains limits the email domains the provider may link or
+	// provision accounts for. Empty allows any domain.
+	AllowedDomains []string
+}
+
+// LegacySSOConfig controls verification of assertions from the legacy SSO
+// provider.
+type LegacySSOConfig struct {
+	// Secret is shared with the provider and keys assertion signatures.
+	Secret string
+
+	// MaxAge is how far an assertion's timestamp may be from the current
+	// time, in either direction, for it to be accepted.
+	MaxAge time.Duration
+}
+
+// RateLimitConfig holds the per-route rate limit policies. A limit of zero
+// disables that policy.
+type RateLimitConfig struct {
+	// Backend is "redis" to share counters across replicas or "memory" to
+	// keep them in process.
+	Backend string
+
+	// LoginPerIP and LoginPerEmail limit login attempts within LoginWindow.
+	LoginPerIP    int
+	LoginPerEmail int
+	LoginWindow   time.Duration
+
+	// UserRequests limits authenticated requests per user within UserWindow.
+	UserRequests int
+	UserWindow   time.Duration
+
+	// PasswordResetPerIP and PasswordResetPerEmail limit reset requests
+	// within PasswordResetWindow.
+	PasswordResetPerIP    int
+	PasswordResetPerEmail int
+	PasswordResetWindow   time.Duration
 }
 
 type FeatureFlags struct {
@@ -69,6 +379,11 @@ type FeatureFlags struct {
 	// TODO(TEAM-SEC): Remove after password migration is complete
 	EnableLegacyAuth bool
 
+	// EnableLegacySSOMD5 accepts legacy MD5 signatures on SSO assertions.
+	// Deprecated: Migrate the SSO provider to v1 HMAC-SHA256 signatures.
+	// TODO(TEAM-SEC): Remove once SSO verifications report no md5 calls
+	EnableLegacySSOMD5 bool
+
 	// EnableNewAuth enables bcrypt-based authentication.
 	EnableNewAuth bool
 
@@ -94,11 +409,23 @@ type FeatureFlags struct {
 	// EnableMetrics enables Prometheus metrics endpoint.
 	EnableMetrics bool
 
-	// EnableRateLimiting enables rate limiting on auth endpoints.
+	// EnableRateLimiting enables rate limiting on login and authenticated
+	// endpoints. Policies are configured in RateLimitConfig.
 	EnableRateLimiting bool
+
+	// RequireEmailVerification refuses login until the account's email
+	// address has been verified.
+	RequireEmailVerification bool
 }
 
 func Load() *Config {
+	sessionLimits := SessionLimits{
+		IdleTimeout:   getEnvDuration("SESSION_IDLE_TIMEOUT", 24*time.Hour),
+		MaxLifetime:   getEnvDuration("SESSION_MAX_LIFETIME", 30*24*time.Hour),
+		MaxConcurrent: getEnvInt("SESSION_MAX_CONCURRENT", 0),
+		OnLimit:       getEnv("SESSION_ON_LIMIT", SessionLimitEvict),
+	}
+
 	return &Config{
 		ServiceName:    getEnv("SERVICE_NAME", "users-service"),
 		ServiceVersion: getEnv("SERVICE_VERSION", "1.0.0"),
@@ -128,13 +455,104 @@ func Load() *Config {
 			DB:       getEnvInt("REDIS_DB", 0),
 			TTL:      getEnvDuration("REDIS_TTL", 15*time.Minute),
 		},
+		Sessions: SessionConfig{
+			Store:         getEnv("SESSION_STORE", "redis"),
+			SweepInterval: getEnvDuration("SESSION_SWEEP_INTERVAL", 5*time.Minute),
+			Limits:        sessionLimits,
+			RoleLimits:    getEnvSessionLimits(sessionLimits),
+		},
 		JWT: JWTConfig{
-			Secret:     getEnv("JWT_SECRET", "acme-secret-key"),
+			Secret:     getEnv("JWT_SECRET", ""),
 			Expiration: getEnvDuration("JWT_EXPIRATION", 24*time.Hour),
 			Issuer:     getEnv("JWT_ISSUER", "acme-users-service"),
+
+			RefreshExpiration: getEnvDuration("JWT_REFRESH_EXPIRATION", 7*24*time.Hour),
+
+			SigningKeys:      getEnvMap("JWT_SIGNING_KEYS"),
+			ActiveKeyID:      getEnv("JWT_ACTIVE_KEY_ID", ""),
+			AcceptLegacyHMAC: getEnvBool("JWT_ACCEPT_LEGACY_HMAC", false),
+		},
+		Lockout: LockoutConfig{
+			MaxAccountFailures: getEnvInt("LOCKOUT_MAX_ACCOUNT_FAILURES", 5),
+			MaxIPFailures:      getEnvInt("LOCKOUT_MAX_IP_FAILURES", 50),
+			FailureWindow:      getEnvDuration("LOCKOUT_FAILURE_WINDOW", 15*time.Minute),
+			LockoutDuration:    getEnvDuration("LOCKOUT_DURATION", 5*time.Minute),
+			MaxLockoutDuration: getEnvDuration("LOCKOUT_MAX_DURATION", 24*time.Hour),
+		},
+		PasswordHash: PasswordHashConfig{
+			Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+			BcryptCost:        getEnvInt("BCRYPT_COST", 12),
+			Argon2Memory:      getEnvInt("ARGON2_MEMORY_KB", 64*1024),
+			Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 3),
+			Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 2),
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:        getEnvInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:        getEnvInt("PASSWORD_MAX_LENGTH", 72),
+			RequireLowercase: getEnvBool("PASSWORD_REQUIRE_LOWERCASE", true),
+			RequireUppercase: getEnvBool("PASSWORD_REQUIRE_UPPERCASE", true),
+			RequireDigit:     getEnvBool("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol:    getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			MinScore:         getEnvInt("PASSWORD_MIN_SCORE", 2),
+			DenyListFile:     getEnv("PASSWORD_DENY_LIST_FILE", "configs/common-passwords.txt"),
+			HistorySize:      getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+		},
+		BreachedPasswords: BreachedPasswordsConfig{
+			Mode:     getEnv("BREACHED_PASSWORDS_MODE", ""),
+			Dir:      getEnv("BREACHED_PASSWORDS_DIR", "data/pwned-passwords"),
+			MinCount: getEnvInt("BREACHED_PASSWORDS_MIN_COUNT", 1),
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenTTL: getEnvDuration("PASSWORD_RESET_TOKEN_TTL", 30*time.Minute),
+			URL:      getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		},
+		EmailVerification: EmailVerificationConfig{
+			Secret:   getEnv("EMAIL_VERIFICATION_SECRET", ""),
+			TokenTTL: getEnvDuration("EMAIL_VERIFICATION_TOKEN_TTL", 48*time.Hour),
+			URL:      getEnv("EMAIL_VERIFICATION_URL", "http://localhost:3000/verify-email"),
+		},
+		MFA: MFAConfig{
+			Issuer:        getEnv("MFA_ISSUER", "Acme Shop"),
+			EncryptionKey: getEnv("MFA_ENCRYPTION_KEY", ""),
+			RequiredRoles: getEnvList("MFA_REQUIRED_ROLES", nil),
+			ChallengeTTL:  getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+			MaxAttempts:   getEnvInt("MFA_MAX_ATTEMPTS", 5),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:             getEnv("WEBAUTHN_RP_ID", "localhost"),
+			RPName:           getEnv("WEBAUTHN_RP_NAME", "Acme Shop"),
+			Origins:          getEnvList("WEBAUTHN_ORIGINS", []string{"http://localhost:3000"}),
+			Timeout:          getEnvDuration("WEBAUTHN_TIMEOUT", 5*time.Minute),
+			UserVerification: getEnv("WEBAUTHN_USER_VERIFICATION", "required"),
+		},
+		OIDC: OIDCConfig{
+			Enabled: getEnvBool("OIDC_ENABLED", false),
+			Issuer:  getEnv("OIDC_ISSUER", "http://localhost:8080"),
+			CodeTTL: getEnvDuration("OIDC_CODE_TTL", time.Minute),
+		},
+		Federation: FederationConfig{
+			Providers: getEnvFederatedProviders(),
+			StateTTL:  getEnvDuration("FEDERATION_STATE_TTL", 10*time.Minute),
+		},
+		LegacySSO: LegacySSOConfig{
+			Secret: getEnv("LEGACY_SSO_SECRET", ""),
+			MaxAge: getEnvDuration("LEGACY_SSO_MAX_AGE", 5*time.Minute),
+		},
+		RateLimit: RateLimitConfig{
+			Backend:       getEnv("RATE_LIMIT_BACKEND", "redis"),
+			LoginPerIP:    getEnvInt("RATE_LIMIT_LOGIN_PER_IP", 20),
+			LoginPerEmail: getEnvInt("RATE_LIMIT_LOGIN_PER_EMAIL", 10),
+			LoginWindow:   getEnvDuration("RATE_LIMIT_LOGIN_WINDOW", time.Minute),
+			UserRequests:  getEnvInt("RATE_LIMIT_USER_REQUESTS", 300),
+			UserWindow:    getEnvDuration("RATE_LIMIT_USER_WINDOW", time.Minute),
+
+			PasswordResetPerIP:    getEnvInt("RATE_LIMIT_PASSWORD_RESET_PER_IP", 10),
+			PasswordResetPerEmail: getEnvInt("RATE_LIMIT_PASSWORD_RESET_PER_EMAIL", 3),
+			PasswordResetWindow:   getEnvDuration("RATE_LIMIT_PASSWORD_RESET_WINDOW", time.Hour),
 		},
 		Features: FeatureFlags{
 			EnableLegacyAuth:        getEnvBool("ENABLE_LEGACY_AUTH", false),
+			EnableLegacySSOMD5:      getEnvBool("ENABLE_LEGACY_SSO_MD5", false),
 			EnableNewAuth:           getEnvBool("ENABLE_NEW_AUTH", true),
 			EnableV1API:             getEnvBool("ENABLE_V1_API", true), // TODO(TEAM-API): Set to false
 			EnableV2API:             getEnvBool("ENABLE_V2_API", true),
@@ -143,10 +561,39 @@ func Load() *Config {
 			EnableDebugMode:         getEnvBool("ENABLE_DEBUG_MODE", false),
 			EnableMetrics:           getEnvBool("ENABLE_METRICS", true),
 			EnableRateLimiting:      getEnvBool("ENABLE_RATE_LIMITING", true),
+
+			RequireEmailVerification: getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
 		},
 	}
 }
 
+// publishedSecrets are secrets that earlier releases used as defaults. They
+// are in the source, so a deployment still using one has no secret at all.
+var publishedSecrets = map[string]bool{
+	"acme-secret-key":             true,
+	"acme-email-verification-key": true,
+	"acme-mfa-encryption-key":     true,
+}
+
+// Validate reports settings the service must not start with.
+func (c *Config) Validate() error {
+	if len(c.JWT.SigningKeys) == 0 && InsecureSecret(c.JWT.Secret) {
+		return fmt.Errorf("JWT_SECRET must be set to a private value when JWT_SIGNING_KEYS is empty")
+	}
+	if InsecureSecret(c.EmailVerification.Secret) {
+		return fmt.Errorf("EMAIL_VERIFICATION_SECRET must be set to a private value")
+	}
+	if InsecureSecret(c.MFA.EncryptionKey) {
+		return fmt.Errorf("MFA_ENCRYPTION_KEY must be set to a private value")
+	}
+	return nil
+}
+
+// InsecureSecret reports whether secret is empty or a published default.
+func InsecureSecret(secret string) bool {
+	return secret == "" || publishedSecrets[secret]
+}
+
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production" || c.Environment == "prod"
 }
@@ -185,6 +632,109 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvMap parses a comma-separated list of key=value pairs.
+func getEnvMap(key string) map[string]string {
+	result := map[string]string{}
+	value := os.Getenv(key)
+	if value == "" {
+		return result
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || k == "" {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// getEnvSessionLimits reads per-role session limits from
+// SESSION_ROLE_IDLE_TIMEOUT, SESSION_ROLE_MAX_LIFETIME,
+// SESSION_ROLE_MAX_CONCURRENT and SESSION_ROLE_ON_LIMIT, each a list of
+// role=value pairs. Settings not given for a role fall back to defaults.
+func getEnvSessionLimits(defaults SessionLimits) map[string]SessionLimits {
+	result := map[string]SessionLimits{}
+	limitsFor := func(role string) SessionLimits {
+		if limits, ok := result[role]; ok {
+			return limits
+		}
+		return defaults
+	}
+
+	for role, value := range getEnvMap("SESSION_ROLE_IDLE_TIMEOUT") {
+		if d, err := time.ParseDuration(value); err == nil {
+			limits := limitsFor(role)
+			limits.IdleTimeout = d
+			result[role] = limits
+		}
+	}
+	for role, value := range getEnvMap("SESSION_ROLE_MAX_LIFETIME") {
+		if d, err := time.ParseDuration(value); err == nil {
+			limits := limitsFor(role)
+			limits.MaxLifetime = d
+			result[role] = limits
+		}
+	}
+	for role, value := range getEnvMap("SESSION_ROLE_MAX_CONCURRENT") {
+		if n, err := strconv.Atoi(value); err == nil {
+			limits := limitsFor(role)
+			limits.MaxConcurrent = n
+			result[role] = limits
+		}
+	}
+	for role, value := range getEnvMap("SESSION_ROLE_ON_LIMIT") {
+		limits := limitsFor(role)
+		limits.OnLimit = value
+		result[role] = limits
+	}
+	return result
+}
+
+// getEnvFederatedProviders reads the providers listed in
+// FEDERATION_PROVIDERS. Each provider is configured with variables prefixed
+// FEDERATION_<ID>_, e.g. FEDERATION_CORP_ISSUER for provider "corp".
+// FEDERATION_REDIRECT_URL and FEDERATION_DEFAULT_ROLE apply to providers that
+// do not set their own.
+func getEnvFederatedProviders() []FederatedProviderConfig {
+	redirectURL := getEnv("FEDERATION_REDIRECT_URL", "http://localhost:3000/auth/federated/callback")
+	defaultRole := getEnv("FEDERATION_DEFAULT_ROLE", "customer")
+
+	var providers []FederatedProviderConfig
+	for _, id := range getEnvList("FEDERATION_PROVIDERS", nil) {
+		prefix := "FEDERATION_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		providers = append(providers, FederatedProviderConfig{
+			ID:           id,
+			Name:         getEnv(prefix+"NAME", id),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       getEnvList(prefix+"SCOPES", []string{"openid", "email", "profile"}),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", redirectURL),
+			Provision:    getEnvBool(prefix+"PROVISION", false),
+			DefaultRole:  getEnv(prefix+"DEFAULT_ROLE", defaultRole),
+
+			AllowedDomains: getEnvList(prefix+"ALLOWED_DOMAINS", nil),
+		})
+	}
+	return providers
+}
+
+// getEnvList parses a comma-separated list, skipping empty entries.
+func getEnvList(key string, defaultValue []string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
 // getLegacyDevPassword returns a fallback password for local development.
 // TODO(TEAM-SEC): Remove this function and require DB_PASSWORD env var.
 func getLegacyDevPassword() string {
@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+// validConfig returns a configuration with every required secret set.
+func validConfig() *Config {
+	return &Config{
+		JWT:               JWTConfig{Secret: "jwt-secret"},
+		EmailVerification: EmailVerificationConfig{Secret: "email-secret"},
+		MFA:               MFAConfig{EncryptionKey: "mfa-key"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+	}{
+		{"missing JWT secret", func(c *Config) { c.JWT.Secret = "" }},
+		{"published JWT secret", func(c *Config) { c.JWT.Secret = "acme-secret-key" }},
+		{"missing email verification secret", func(c *Config) { c.EmailVerification.Secret = "" }},
+		{"published email verification secret", func(c *Config) { c.EmailVerification.Secret = "acme-email-verification-key" }},
+		{"missing MFA encryption key", func(c *Config) { c.MFA.EncryptionKey = "" }},
+		{"published MFA encryption key", func(c *Config) { c.MFA.EncryptionKey = "acme-mfa-encryption-key" }},
+	}
+
+	t.Run("JWT secret unused with signing keys", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.JWT = JWTConfig{Secret: "acme-secret-key", SigningKeys: map[string]string{"2024-06": "/keys/2024-06.pem"}}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.mutate(cfg)
+			if err := cfg.Validate(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Package federation signs users in through external OpenID Connect
+// identity providers using the authorization code flow with PKCE.
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// Federation errors
+var (
+	// ErrUnknownProvider is returned for a provider ID that is not configured.
+	ErrUnknownProvider = errors.New("unknown identity provider")
+
+	// ErrInvalidState is returned when a sign-in's state is unknown, has
+	// expired, was already used or belongs to another provider.
+	ErrInvalidState = errors.New("invalid or expired sign-in state")
+
+	// ErrInvalidIDToken is returned when the provider rejects the
+	// authorization code or its ID token fails validation.
+	ErrInvalidIDToken = errors.New("invalid ID token")
+
+	// ErrProviderUnavailable is returned when the provider cannot be reached
+	// or returns a malformed response.
+	ErrProviderUnavailable = errors.New("identity provider is unavailable")
+)
+
+const randomBytes = 32
+
+// Identity is a user as asserted by a provider's verified ID token.
+type Identity struct {
+	Provider string
+
+	// Subject is the provider's stable identifier for the user.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+// Authorization is a started sign-in. The client sends the user to URL and
+// must check that the state the provider returns matches State before
+// finishing the sign-in.
+type Authorization struct {
+	URL       string    `json:"authorization_url"`
+	State     string    `json:"state"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Federation holds the configured identity providers.
+type Federation struct {
+	providers []*Provider
+	states    StateStore
+	stateTTL  time.Duration
+	logger    *logging.LoggerV2
+}
+
+// New creates a federation from configuration. A nil httpClient uses a
+// client with a 10 second timeout.
+func New(cfg config.FederationConfig, states StateStore, httpClient *http.Client) (*Federation, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	f := &Federation{
+		states:   states,
+		stateTTL: cfg.StateTTL,
+		logger:   logging.NewLoggerV2("federation"),
+	}
+
+	seen := map[string]bool{}
+	for _, pc := range cfg.Providers {
+		switch {
+		case pc.ID == "" || seen[pc.ID]:
+			return nil, fmt.Errorf("federated provider %q: missing or duplicate ID", pc.ID)
+		case !validURL(pc.Issuer):
+			return nil, fmt.Errorf("federated provider %q: issuer must be an https URL", pc.ID)
+		case pc.ClientID == "":
+			return nil, fmt.Errorf("federated provider %q: client ID is required", pc.ID)
+		case !validURL(pc.RedirectURL):
+			return nil, fmt.Errorf("federated provider %q: invalid redirect URL", pc.ID)
+		case !containsScope(pc.Scopes, "openid"):
+			return nil, fmt.Errorf("federated provider %q: scopes must include openid", pc.ID)
+		case pc.Provision && !provisionableRole(pc.DefaultRole):
+			return nil, fmt.Errorf("federated provider %q: default role must be customer or vendor", pc.ID)
+		}
+		seen[pc.ID] = true
+		f.providers = append(f.providers, newProvider(pc, httpClient))
+	}
+
+	return f, nil
+}
+
+// Providers returns the configured providers in configuration order.
+func (f *Federation) Providers() []*Provider {
+	return f.providers
+}
+
+// Provider returns the provider with the given ID.
+func (f *Federation) Provider(id string) (*Provider, error) {
+	for _, p := range f.providers {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// Begin starts a sign-in with a provider. The state, nonce and PKCE
+// verifier are kept server-side until Finish.
+func (f *Federation) Begin(ctx context.Context, providerID string) (*Authorization, error) {
+	return f.begin(ctx, providerID, "")
+}
+
+// BeginLink starts a sign-in that links the identity to userID's account.
+// It can only be completed with FinishLink by the same user.
+func (f *Federation) BeginLink(ctx context.Context, providerID, userID string) (*Authorization, error) {
+	return f.begin(ctx, providerID, userID)
+}
+
+func (f *Federation) begin(ctx context.Context, providerID, userID string) (*Authorization, error) {
+	p, err := f.Provider(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(f.stateTTL)
+	err = f.states.Save(ctx, hashState(state), &LoginState{
+		Provider:     p.ID,
+		Nonce:        nonce,
+		UserID:       userID,
+		CodeVerifier: verifier,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return &Authorization{
+		URL:       p.authorizationURL(metadata, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:])),
+		State:     state,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Finish completes a sign-in: it redeems the authorization code the
+// provider returned with state, validates the ID token and returns the
+// identity it asserts. Each state can only be finished once.
+func (f *Federation) Finish(ctx context.Context, providerID, code, state string) (*Identity, error) {
+	return f.finish(ctx, providerID, "", code, state)
+}
+
+// FinishLink completes a sign-in started with BeginLink by userID.
+func (f *Federation) FinishLink(ctx context.Context, providerID, userID, code, state string) (*Identity, error) {
+	return f.finish(ctx, providerID, userID, code, state)
+}
+
+func (f *Federation) finish(ctx context.Context, providerID, userID, code, state string) (*Identity, error) {
+	p, err := f.Provider(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := f.states.Consume(ctx, hashState(state))
+	if err != nil {
+		return nil, err
+	}
+	// A state issued for another provider indicates a mix-up attack, and a
+	// link started by another user would attach an identity to their account
+	if login.Provider != p.ID || login.UserID != userID || time.Now().After(login.ExpiresAt) {
+		return nil, ErrInvalidState
+	}
+
+	metadata, err := p.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := p.exchange(ctx, metadata, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := p.verifyIDToken(ctx, metadata, idToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	f.logger.Info("federated identity verified", logging.Fields{
+		"provider": p.ID,
+		"subject":  identity.Subject,
+	})
+	return identity, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, randomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// provisionableRole reports whether users may be created with role. Admins
+// are never provisioned from an external identity.
+func provisionableRole(role string) bool {
+	switch models.UserRole(role) {
+	case models.RoleCustomer, models.RoleVendor:
+		return true
+	default:
+		return false
+	}
+}
+
+// emailDomain returns the lower-cased domain of an email address.
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(email[at+1:])
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
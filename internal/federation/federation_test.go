@@ -0,0 +1,470 @@
+package federation
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	testClientID     = "acme-users"
+	testClientSecret = "s3cret/with+symbols"
+	testRedirectURL  = "http://localhost:3000/auth/federated/callback"
+)
+
+// fakeIdP is an in-process OpenID Connect provider that issues codes for
+// whatever claims a test asks for.
+type fakeIdP struct {
+	server *httptest.Server
+	key    *auth.SigningKey
+	signer *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]issuedCode
+
+	// issuer overrides the issuer in the discovery document.
+	issuer string
+}
+
+type issuedCode struct {
+	challenge   string
+	redirectURI string
+	idToken     string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	t.Helper()
+
+	signer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key, err := auth.NewSigningKey("idp-key-1", signer)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	ring, _ := auth.NewKeyRing(key)
+
+	idp := &fakeIdP{key: key, signer: signer, codes: map[string]issuedCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := idp.server.URL
+		if idp.issuer != "" {
+			issuer = idp.issuer
+		}
+		json.NewEncoder(w).Encode(Metadata{
+			Issuer:                           issuer,
+			AuthorizationEndpoint:            idp.server.URL + "/authorize",
+			TokenEndpoint:                    idp.server.URL + "/token",
+			JWKSURI:                          idp.server.URL + "/jwks",
+			IDTokenSigningAlgValuesSupported: []string{"ES256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ring.JWKS())
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *fakeIdP) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != testClientID || secret != testClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	code, ok := idp.codes[r.PostFormValue("code")]
+	delete(idp.codes, r.PostFormValue("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || code.redirectURI != r.PostFormValue("redirect_uri") ||
+		code.challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "upstream-access-token",
+		"token_type":   "Bearer",
+		"id_token":     code.idToken,
+	})
+}
+
+// claims returns valid ID token claims for a sign-in with nonce.
+func (idp *fakeIdP) claims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "upstream-user-1",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          "vendor@corp.example",
+		"email_verified": true,
+		"name":           "Vera Vendor",
+	}
+}
+
+func (idp *fakeIdP) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = idp.key.ID
+	signed, err := token.SignedString(idp.signer)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return signed
+}
+
+// authorize plays the user signing in at the provider: it reads the
+// authorization request and returns the code and state the provider would
+// redirect back with. idToken builds the ID token from the request's nonce.
+func (idp *fakeIdP) authorize(t *testing.T, authorizationURL string, idToken func(nonce string) string) (string, string) {
+	t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("redirect_uri") != testRedirectURL {
+		t.Fatalf("unexpected authorization request %s", authorizationURL)
+	}
+
+	code, _ := randomString()
+	idp.mu.Lock()
+	idp.codes[code] = issuedCode{
+		challenge:   q.Get("code_challenge"),
+		redirectURI: q.Get("redirect_uri"),
+		idToken:     idToken(q.Get("nonce")),
+	}
+	idp.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func providerConfig(id string, idp *fakeIdP) config.FederatedProviderConfig {
+	return config.FederatedProviderConfig{
+		ID:           id,
+		Name:         "Corp SSO",
+		Issuer:       idp.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		Scopes:       []string{"openid", "email", "profile"},
+		RedirectURL:  testRedirectURL,
+	}
+}
+
+func newTestFederation(t *testing.T, providers ...config.FederatedProviderConfig) *Federation {
+	t.Helper()
+	f, err := New(config.FederationConfig{Providers: providers, StateTTL: time.Minute}, NewInMemoryStateStore(), nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return f
+}
+
+func TestFederatedSignIn(t *testing.T) {
+	idp := newFakeIdP(t)
+	f := newTestFederation(t, providerConfig("corp", idp))
+	ctx := context.Background()
+
+	authz, err := f.Begin(ctx, "corp")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	code, state := idp.authorize(t, authz.URL, func(nonce string) string {
+		return idp.sign(t, idp.claims(nonce))
+	})
+	if state != authz.State {
+		t.Fatalf("expected state %s, got %s", authz.State, state)
+	}
+
+	identity, err := f.Finish(ctx, "corp", code, state)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	want := Identity{
+		Provider:      "corp",
+		Subject:       "upstream-user-1",
+		Email:         "vendor@corp.example",
+		EmailVerified: true,
+		GivenName:     "Vera",
+		FamilyName:    "Vendor",
+	}
+	if *identity != want {
+		t.Fatalf("expected identity %+v, got %+v", want, *identity)
+	}
+
+	t.Run("state cannot be reused", func(t *testing.T) {
+		if _, err := f.Finish(ctx, "corp", code, state); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+}
+
+func TestFederatedSignInRejectsInvalidIDTokens(t *testing.T) {
+	idp := newFakeIdP(t)
+	f := newTestFederation(t, providerConfig("corp", idp))
+	ctx := context.Background()
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	tests := []struct {
+		name    string
+		idToken func(nonce string) string
+	}{
+		{"wrong nonce", func(nonce string) string {
+			return idp.sign(t, idp.claims("another-nonce"))
+		}},
+		{"wrong audience", func(nonce string) string {
+			claims := idp.claims(nonce)
+			claims["aud"] = "another-client"
+			return idp.sign(t, claims)
+		}},
+		{"wrong issuer", func(nonce string) string {
+			claims := idp.claims(nonce)
+			claims["iss"] = "https://evil.example"
+			return idp.sign(t, claims)
+		}},
+		{"expired", func(nonce string) string {
+			claims := idp.claims(nonce)
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return idp.sign(t, claims)
+		}},
+		{"missing subject", func(nonce string) string {
+			claims := idp.claims(nonce)
+			delete(claims, "sub")
+			return idp.sign(t, claims)
+		}},
+		{"multiple audiences without azp", func(nonce string) string {
+			claims := idp.claims(nonce)
+			claims["aud"] = []string{testClientID, "another-client"}
+			return idp.sign(t, claims)
+		}},
+		{"unknown signing key", func(nonce string) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodES256, idp.claims(nonce))
+			token.Header["kid"] = idp.key.ID
+			signed, _ := token.SignedString(otherKey)
+			return signed
+		}},
+		{"shared-secret signature", func(nonce string) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.claims(nonce))
+			signed, _ := token.SignedString([]byte(testClientSecret))
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authz, err := f.Begin(ctx, "corp")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			code, state := idp.authorize(t, authz.URL, tt.idToken)
+
+			if _, err := f.Finish(ctx, "corp", code, state); err != ErrInvalidIDToken {
+				t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFederatedSignInErrors(t *testing.T) {
+	idp := newFakeIdP(t)
+	f := newTestFederation(t, providerConfig("corp", idp), providerConfig("partner", idp))
+	ctx := context.Background()
+	validToken := func(nonce string) string { return idp.sign(t, idp.claims(nonce)) }
+
+	t.Run("unknown provider", func(t *testing.T) {
+		if _, err := f.Begin(ctx, "nope"); err != ErrUnknownProvider {
+			t.Fatalf("expected ErrUnknownProvider, got %v", err)
+		}
+	})
+
+	t.Run("unknown state", func(t *testing.T) {
+		if _, err := f.Finish(ctx, "corp", "code", "forged-state"); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("state from another provider", func(t *testing.T) {
+		authz, _ := f.Begin(ctx, "corp")
+		code, state := idp.authorize(t, authz.URL, validToken)
+
+		if _, err := f.Finish(ctx, "partner", code, state); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("code rejected by provider", func(t *testing.T) {
+		authz, _ := f.Begin(ctx, "corp")
+		_, state := idp.authorize(t, authz.URL, validToken)
+
+		if _, err := f.Finish(ctx, "corp", "wrong-code", state); err != ErrInvalidIDToken {
+			t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+		}
+	})
+
+	t.Run("discovery issuer mismatch", func(t *testing.T) {
+		other := newFakeIdP(t)
+		other.issuer = "https://evil.example"
+		f := newTestFederation(t, providerConfig("corp", other))
+
+		if _, err := f.Begin(ctx, "corp"); err != ErrProviderUnavailable {
+			t.Fatalf("expected ErrProviderUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("provider down", func(t *testing.T) {
+		down := newFakeIdP(t)
+		f := newTestFederation(t, providerConfig("corp", down))
+		down.server.Close()
+
+		if _, err := f.Begin(ctx, "corp"); err != ErrProviderUnavailable {
+			t.Fatalf("expected ErrProviderUnavailable, got %v", err)
+		}
+	})
+}
+
+func TestNewRejectsInvalidProviders(t *testing.T) {
+	valid := config.FederatedProviderConfig{
+		ID:          "corp",
+		Issuer:      "https://sso.corp.example",
+		ClientID:    testClientID,
+		Scopes:      []string{"openid", "email"},
+		RedirectURL: testRedirectURL,
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*config.FederatedProviderConfig)
+	}{
+		{"plain http issuer", func(c *config.FederatedProviderConfig) { c.Issuer = "http://sso.corp.example" }},
+		{"missing client ID", func(c *config.FederatedProviderConfig) { c.ClientID = "" }},
+		{"missing openid scope", func(c *config.FederatedProviderConfig) { c.Scopes = []string{"email"} }},
+		{"admin provisioning", func(c *config.FederatedProviderConfig) { c.Provision, c.DefaultRole = true, "admin" }},
+		{"unknown provisioning role", func(c *config.FederatedProviderConfig) { c.Provision, c.DefaultRole = true, "owner" }},
+	}
+
+	if _, err := New(config.FederationConfig{Providers: []config.FederatedProviderConfig{valid}}, NewInMemoryStateStore(), nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.mutate(&cfg)
+			if _, err := New(config.FederationConfig{Providers: []config.FederatedProviderConfig{cfg}}, NewInMemoryStateStore(), nil); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+
+	t.Run("duplicate ID", func(t *testing.T) {
+		cfg := config.FederationConfig{Providers: []config.FederatedProviderConfig{valid, valid}}
+		if _, err := New(cfg, NewInMemoryStateStore(), nil); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestFederatedLink(t *testing.T) {
+	idp := newFakeIdP(t)
+	f := newTestFederation(t, providerConfig("corp", idp))
+	ctx := context.Background()
+	validToken := func(nonce string) string { return idp.sign(t, idp.claims(nonce)) }
+
+	t.Run("same user", func(t *testing.T) {
+		authz, err := f.BeginLink(ctx, "corp", "user-1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		code, state := idp.authorize(t, authz.URL, validToken)
+
+		identity, err := f.FinishLink(ctx, "corp", "user-1", code, state)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if identity.Subject != "upstream-user-1" {
+			t.Fatalf("expected subject upstream-user-1, got %s", identity.Subject)
+		}
+	})
+
+	t.Run("another user", func(t *testing.T) {
+		authz, _ := f.BeginLink(ctx, "corp", "user-1")
+		code, state := idp.authorize(t, authz.URL, validToken)
+
+		if _, err := f.FinishLink(ctx, "corp", "user-2", code, state); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("link state used to sign in", func(t *testing.T) {
+		authz, _ := f.BeginLink(ctx, "corp", "user-1")
+		code, state := idp.authorize(t, authz.URL, validToken)
+
+		if _, err := f.Finish(ctx, "corp", code, state); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+
+	t.Run("sign-in state used to link", func(t *testing.T) {
+		authz, _ := f.Begin(ctx, "corp")
+		code, state := idp.authorize(t, authz.URL, validToken)
+
+		if _, err := f.FinishLink(ctx, "corp", "user-1", code, state); err != ErrInvalidState {
+			t.Fatalf("expected ErrInvalidState, got %v", err)
+		}
+	})
+}
+
+func TestProviderAllowsEmail(t *testing.T) {
+	p := newProvider(config.FederatedProviderConfig{ID: "corp", AllowedDomains: []string{"corp.example"}}, nil)
+
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"vera@corp.example", true},
+		{"Vera@CORP.example", true},
+		{"vera@evil.example", false},
+		{"vera@sub.corp.example", false},
+		{"corp.example", false},
+	}
+	for _, tt := range tests {
+		if got := p.AllowsEmail(tt.email); got != tt.want {
+			t.Fatalf("expected AllowsEmail(%q) = %v, got %v", tt.email, tt.want, got)
+		}
+	}
+
+	if !newProvider(config.FederatedProviderConfig{ID: "any"}, nil).AllowsEmail("anyone@example.com") {
+		t.Fatal("expected a provider without allowed domains to allow any email")
+	}
+}
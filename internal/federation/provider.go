@@ -0,0 +1,415 @@
+package federation
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	// maxResponseBytes bounds how much of a provider response is read.
+	maxResponseBytes = 1 << 20
+
+	// keyRefreshInterval is the minimum time between JWKS fetches, so ID
+	// tokens with unknown key IDs cannot be used to hammer the provider.
+	keyRefreshInterval = time.Minute
+
+	// clockSkew is the leeway allowed on ID token timestamps.
+	clockSkew = time.Minute
+)
+
+// supportedAlgorithms are the ID token signature algorithms accepted from
+// providers. Shared-secret and unsigned tokens are never accepted.
+var supportedAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "EdDSA"}
+
+// Metadata is the part of a provider's discovery document (OpenID Connect
+// Discovery 1.0) used to sign users in.
+type Metadata struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// Provider is an upstream OpenID Connect identity provider. Its discovery
+// document and signing keys are fetched on first use and cached.
+type Provider struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Provision and DefaultRole control just-in-time account creation.
+	Provision   bool   `json:"-"`
+	DefaultRole string `json:"-"`
+
+	// AllowedDomains are the email domains the provider is trusted to
+	// assert. Empty allows any domain.
+	AllowedDomains []string `json:"-"`
+
+	cfg        config.FederatedProviderConfig
+	httpClient *http.Client
+	logger     *logging.LoggerV2
+
+	mu            sync.Mutex
+	metadata      *Metadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+func newProvider(cfg config.FederatedProviderConfig, httpClient *http.Client) *Provider {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &Provider{
+		ID:             cfg.ID,
+		Name:           cfg.Name,
+		Provision:      cfg.Provision,
+		DefaultRole:    cfg.DefaultRole,
+		AllowedDomains: cfg.AllowedDomains,
+		cfg:            cfg,
+		httpClient:     httpClient,
+		logger:         logging.NewLoggerV2("federation"),
+	}
+}
+
+// AllowsEmail reports whether the provider may assert email, that is
+// whether its domain is one of AllowedDomains.
+func (p *Provider) AllowsEmail(email string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	domain := emailDomain(email)
+	for _, allowed := range p.AllowedDomains {
+		if domain != "" && strings.EqualFold(allowed, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Metadata returns the provider's discovery document, fetching it on first
+// use.
+func (p *Provider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := p.getJSON(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, err
+	}
+
+	// The issuer in the document must be exactly the one configured
+	// (OpenID Connect Discovery 1.0 section 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.cfg.Issuer {
+		p.logger.Error("discovery document issuer mismatch", logging.Fields{
+			"provider": p.ID,
+			"issuer":   metadata.Issuer,
+		})
+		return nil, ErrProviderUnavailable
+	}
+	for _, endpoint := range []string{metadata.AuthorizationEndpoint, metadata.TokenEndpoint, metadata.JWKSURI} {
+		if !validURL(endpoint) {
+			p.logger.Error("discovery document has an invalid endpoint", logging.Fields{
+				"provider": p.ID,
+				"endpoint": endpoint,
+			})
+			return nil, ErrProviderUnavailable
+		}
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// authorizationURL returns the URL that starts sign-in at the provider.
+func (p *Provider) authorizationURL(metadata *Metadata, state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// tokenResponse is the provider's reply to an authorization code exchange.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange redeems an authorization code at the provider's token endpoint
+// and returns the raw ID token.
+func (p *Provider) exchange(ctx context.Context, metadata *Metadata, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.cfg.ClientSecret == "" {
+		form.Set("client_id", p.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		p.logger.Error("token request failed", logging.Fields{
+			"provider": p.ID,
+			"error":    err.Error(),
+		})
+		return "", ErrProviderUnavailable
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&token); err != nil && resp.StatusCode == http.StatusOK {
+		return "", ErrProviderUnavailable
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK && token.IDToken != "":
+		return token.IDToken, nil
+	case resp.StatusCode >= 500:
+		p.logger.Error("token endpoint error", logging.Fields{
+			"provider": p.ID,
+			"status":   resp.StatusCode,
+		})
+		return "", ErrProviderUnavailable
+	default:
+		// A rejected code is the user's problem, not the provider's
+		p.logger.Warn("authorization code rejected", logging.Fields{
+			"provider":          p.ID,
+			"status":            resp.StatusCode,
+			"error":             token.Error,
+			"error_description": token.ErrorDescription,
+		})
+		return "", ErrInvalidIDToken
+	}
+}
+
+// idTokenClaims are the ID token claims used to identify the user.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty string `json:"azp,omitempty"`
+	Nonce           string `json:"nonce"`
+	Email           string `json:"email"`
+	EmailVerified   bool   `json:"email_verified"`
+	Name            string `json:"name"`
+	GivenName       string `json:"given_name"`
+	FamilyName      string `json:"family_name"`
+}
+
+// verifyIDToken checks an ID token's signature, issuer, audience, lifetime
+// and nonce (OpenID Connect Core 1.0 section 3.1.3.7) and returns the
+// identity it asserts.
+func (p *Provider) verifyIDToken(ctx context.Context, metadata *Metadata, raw, nonce string) (*Identity, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(p.algorithms(metadata)),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+
+	claims := &idTokenClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, metadata, kid)
+	})
+	if err != nil {
+		p.logger.Warn("ID token rejected", logging.Fields{
+			"provider": p.ID,
+			"error":    err.Error(),
+		})
+		return nil, ErrInvalidIDToken
+	}
+
+	switch {
+	case claims.Subject == "":
+		return nil, ErrInvalidIDToken
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID:
+		return nil, ErrInvalidIDToken
+	case claims.Nonce != nonce:
+		p.logger.Warn("ID token nonce mismatch", logging.Fields{"provider": p.ID})
+		return nil, ErrInvalidIDToken
+	}
+
+	identity := &Identity{
+		Provider:      p.ID,
+		Subject:       claims.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+	}
+	if identity.GivenName == "" && identity.FamilyName == "" {
+		identity.GivenName, identity.FamilyName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	return identity, nil
+}
+
+// algorithms returns the signature algorithms accepted for this provider:
+// those it advertises that are also supported, or RS256 if it advertises
+// none (OpenID Connect Discovery 1.0 section 3).
+func (p *Provider) algorithms(metadata *Metadata) []string {
+	if len(metadata.IDTokenSigningAlgValuesSupported) == 0 {
+		return []string{"RS256"}
+	}
+
+	var algs []string
+	for _, alg := range metadata.IDTokenSigningAlgValuesSupported {
+		for _, supported := range supportedAlgorithms {
+			if alg == supported {
+				algs = append(algs, alg)
+			}
+		}
+	}
+	return algs
+}
+
+// key returns the provider's signing key with ID kid, refetching the JWKS
+// when the key is unknown so provider key rotation is picked up.
+func (p *Provider) key(ctx context.Context, metadata *Metadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetchedAt) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set auth.JWKSet
+	if err := p.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	p.keysFetchedAt = time.Now()
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			p.logger.Warn("skipping provider signing key", logging.Fields{
+				"provider": p.ID,
+				"error":    err.Error(),
+			})
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a cached key. Tokens without a kid are accepted only
+// while the provider publishes a single key.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		p.logger.Error("provider request failed", logging.Fields{
+			"provider": p.ID,
+			"url":      target,
+			"error":    err.Error(),
+		})
+		return ErrProviderUnavailable
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		p.logger.Error("provider request failed", logging.Fields{
+			"provider": p.ID,
+			"url":      target,
+			"status":   resp.StatusCode,
+		})
+		return ErrProviderUnavailable
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v); err != nil {
+		p.logger.Error("invalid provider response", logging.Fields{
+			"provider": p.ID,
+			"url":      target,
+			"error":    err.Error(),
+		})
+		return ErrProviderUnavailable
+	}
+	return nil
+}
+
+// validURL reports whether u is an absolute https URL, or http on
+// the loopback interface for local development.
+func validURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" || parsed.User != nil {
+		return false
+	}
+
+	switch parsed.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := parsed.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
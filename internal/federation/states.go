@@ -0,0 +1,139 @@
+package federation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const loginStatePrefix = "federated_login:"
+
+// LoginState is the server-side state of a sign-in in progress at a
+// provider.
+type LoginState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+
+	// UserID is set when a signed-in user is linking the identity to their
+	// account, and only that user can finish the sign-in.
+	UserID string `json:"user_id,omitempty"`
+
+	// CodeVerifier is the PKCE verifier sent with the code exchange.
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// StateStore persists sign-in state keyed by state hash.
+type StateStore interface {
+	// Save stores new state until it expires.
+	Save(ctx context.Context, hash string, state *LoginState) error
+
+	// Consume atomically removes state and returns it, so a sign-in can
+	// only be finished once. It returns ErrInvalidState if none exists.
+	Consume(ctx context.Context, hash string) (*LoginState, error)
+}
+
+// hashState derives the storage key for a state value.
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
+
+// RedisStateStore stores sign-in state in Redis.
+type RedisStateStore struct {
+	client *redis.Client
+	logger *logging.LoggerV2
+}
+
+// NewRedisStateStore creates a new Redis-backed sign-in state store.
+func NewRedisStateStore(cfg config.RedisConfig) *RedisStateStore {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisStateStore{
+		client: client,
+		logger: logging.NewLoggerV2("federated-state-store"),
+	}
+}
+
+func (s *RedisStateStore) Save(ctx context.Context, hash string, state *LoginState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, loginStatePrefix+hash, data, time.Until(state.ExpiresAt)).Err()
+}
+
+// Consume reads and deletes state in one transaction so concurrent
+// requests cannot both finish the sign-in.
+func (s *RedisStateStore) Consume(ctx context.Context, hash string) (*LoginState, error) {
+	key := loginStatePrefix + hash
+
+	var get *redis.StringCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state LoginState
+	if err := json.Unmarshal([]byte(get.Val()), &state); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	return &state, nil
+}
+
+// InMemoryStateStore is a sign-in state store for tests and single-node development.
+type InMemoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]LoginState
+}
+
+func NewInMemoryStateStore() *InMemoryStateStore {
+	return &InMemoryStateStore{
+		states: make(map[string]LoginState),
+	}
+}
+
+func (s *InMemoryStateStore) Save(ctx context.Context, hash string, state *LoginState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[hash] = *state
+	return nil
+}
+
+func (s *InMemoryStateStore) Consume(ctx context.Context, hash string) (*LoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[hash]
+	if !ok {
+		return nil, ErrInvalidState
+	}
+	delete(s.states, hash)
+	return &state, nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ StateStore = (*RedisStateStore)(nil)
+	_ StateStore = (*InMemoryStateStore)(nil)
+)
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// CreateAPIKey handles POST /api/v2/users/me/api-keys
+func (h *Handlers) CreateAPIKey(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req service.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+		return
+	}
+
+	key, err := h.apiKeys.Create(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreatedAPIKeyResponse{
+		Success: true,
+		Data:    key,
+	})
+}
+
+// ListAPIKeys handles GET /api/v2/users/me/api-keys
+func (h *Handlers) ListAPIKeys(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	keys, err := h.apiKeys.List(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIKeysResponse{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+// RotateAPIKey handles POST /api/v2/users/me/api-keys/:id/rotate
+func (h *Handlers) RotateAPIKey(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	key, err := h.apiKeys.Rotate(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, CreatedAPIKeyResponse{
+		Success: true,
+		Data:    key,
+	})
+}
+
+// RevokeAPIKey handles DELETE /api/v2/users/me/api-keys/:id
+func (h *Handlers) RevokeAPIKey(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	if err := h.apiKeys.Revoke(c.Request.Context(), userID, c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "API key revoked",
+	})
+}
+
+// Request and response types
+
+type CreatedAPIKeyResponse struct {
+	Success bool                   `json:"success"`
+	Data    *service.CreatedAPIKey `json:"data"`
+}
+
+type APIKeysResponse struct {
+	Success bool                 `json:"success"`
+	Data    []*repository.APIKey `json:"data"`
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 200
+)
+
+// ListAuditEvents handles GET /api/v2/audit
+func (h *Handlers) ListAuditEvents(c *gin.Context) {
+	filter, err := h.parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	h.logger.Debug("ListAuditEvents called", logging.Fields{
+		"actor":   filter.ActorID,
+		"subject": filter.ResourceID,
+		"action":  string(filter.Action),
+	})
+
+	events, total, err := h.audit.List(c.Request.Context(), filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListAuditEventsResponse{
+		Success: true,
+		Data:    events,
+		Meta: PaginationMeta{
+			Total:  total,
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+		},
+	})
+}
+
+func (h *Handlers) parseAuditFilter(c *gin.Context) (*audit.Filter, error) {
+	filter := &audit.Filter{
+		ActorID:      c.Query("actor"),
+		ResourceID:   c.Query("subject"),
+		ResourceType: c.Query("resource_type"),
+		Action:       audit.Action(c.Query("action")),
+		Limit:        h.parseIntQuery(c, "limit", defaultAuditLimit),
+		Offset:       h.parseIntQuery(c, "offset", 0),
+	}
+
+	if filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return nil, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func parseTimeQuery(c *gin.Context, key string) (time.Time, error) {
+	val := c.Query(key)
+	if val == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid %s parameter, expected an RFC 3339 timestamp", key)
+	}
+	return t, nil
+}
+
+type ListAuditEventsResponse struct {
+	Success bool           `json:"success"`
+	Data    []*audit.Event `json:"data"`
+	Meta    PaginationMeta `json:"meta"`
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
 )
 
@@ -24,6 +27,7 @@ func (h *Handlers) Login(c *gin.Context) {
 
 	req.IPAddress = c.ClientIP()
 	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = h.deviceID(c)
 
 	h.logger.Info("login attempt", logging.Fields{
 		"email":      req.Email,
@@ -36,12 +40,33 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
+	h.writeLoginResponse(c, response)
+}
+
+// writeLoginResponse writes either the issued tokens or, when a second
+// factor is still needed, the MFA challenge.
+func (h *Handlers) writeLoginResponse(c *gin.Context, response *service.LoginResponse) {
+	if response.MFARequired {
+		c.JSON(http.StatusOK, MFAChallengeResponse{
+			Success:     true,
+			MFARequired: true,
+			MFAToken:    response.MFAToken,
+			ExpiresAt:   response.MFAExpiresAt,
+
+			PasswordBreached: response.PasswordBreached,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, LoginResponse{
-		Success:   true,
-		Token:     response.Token,
-		User:      response.User,
-		SessionID: response.SessionID,
-		ExpiresAt: response.ExpiresAt,
+		Success:          true,
+		Token:            response.Token,
+		RefreshToken:     response.RefreshToken,
+		RefreshExpiresAt: response.RefreshExpiresAt,
+		User:             response.User,
+		SessionID:        response.SessionID,
+		ExpiresAt:        response.ExpiresAt,
+		PasswordBreached: response.PasswordBreached,
 	})
 }
 
@@ -67,7 +92,7 @@ func (h *Handlers) LoginV1(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.LoginV1(c.Request.Context(), req.Email, req.Password)
+	response, err := h.authService.LoginV1(c.Request.Context(), req.Email, req.Password, c.ClientIP(), c.GetHeader("User-Agent"))
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -129,25 +154,72 @@ func (h *Handlers) LogoutAll(c *gin.Context) {
 
 // RefreshToken handles POST /api/v2/auth/refresh
 func (h *Handlers) RefreshToken(c *gin.Context) {
-	token := h.extractToken(c)
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
 			Success: false,
-			Error:   "No token provided",
+			Error:   "refresh_token is required",
 		})
 		return
 	}
 
-	response, err := h.authService.RefreshToken(c.Request.Context(), token)
+	response, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, RefreshTokenResponse{
-		Success:   true,
-		Token:     response.Token,
-		ExpiresAt: response.ExpiresAt,
+		Success:          true,
+		Token:            response.Token,
+		RefreshToken:     response.RefreshToken,
+		RefreshExpiresAt: response.RefreshExpiresAt,
+		ExpiresAt:        response.ExpiresAt,
+	})
+}
+
+// ForgotPassword handles POST /api/v2/auth/password/forgot
+func (h *Handlers) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !service.ValidateEmail(req.Email) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "A valid email is required",
+		})
+		return
+	}
+
+	if err := h.authService.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Identical for known and unknown emails
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Message: "If an account exists for this email, a password reset link has been sent",
+	})
+}
+
+// ResetPassword handles POST /api/v2/auth/password/reset
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" || req.NewPassword == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "token and new_password are required",
+		})
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Password reset successfully",
 	})
 }
 
@@ -164,9 +236,10 @@ func (h *Handlers) ValidateToken(c *gin.Context) {
 
 	claims, err := h.authService.ValidateToken(c.Request.Context(), token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		status, message := tokenError(err)
+		c.JSON(status, ErrorResponse{
 			Success: false,
-			Error:   err.Error(),
+			Error:   message,
 		})
 		return
 	}
@@ -189,7 +262,12 @@ func (h *Handlers) GetSessions(c *gin.Context) {
 		return
 	}
 
-	sessions, err := h.authService.GetSessions(c.Request.Context(), userID)
+	var currentSessionID string
+	if claims := claimsFromContext(c); claims != nil {
+		currentSessionID = claims.SessionID
+	}
+
+	sessions, err := h.authService.GetSessions(c.Request.Context(), userID, currentSessionID)
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -218,11 +296,76 @@ func (h *Handlers) RevokeSession(c *gin.Context) {
 	})
 }
 
+// JWKS handles GET /.well-known/jwks.json
+func (h *Handlers) JWKS(c *gin.Context) {
+	// Allow verifiers to cache keys briefly; rotation keeps old keys published
+	// for at least one token lifetime so short caching is safe.
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
 // AuthMiddleware validates JWT tokens for protected routes.
 func (h *Handlers) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := h.extractToken(c)
 		if token == "" {
+			if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+				key, user, err := h.apiKeys.Authenticate(c.Request.Context(), apiKey)
+				if err != nil {
+					status, message := apiKeyError(err)
+					c.AbortWithStatusJSON(status, ErrorResponse{
+						Success: false,
+						Error:   message,
+					})
+					return
+				}
+
+				// API key callers act as the key's owner, limited to its scopes
+				h.setCaller(c, &auth.JWTClaims{UserID: user.ID, Email: user.Email, Role: user.Role})
+				c.Set(apiKeyContextKey, key)
+				c.Next()
+				return
+			}
+
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Success: false,
+				Error:   "No token provided",
+			})
+			return
+		}
+
+		claims, err := h.authService.ValidateToken(c.Request.Context(), token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Success: false,
+				Error:   "Invalid token",
+			})
+			return
+		}
+
+		// Tokens issued to OAuth clients are limited to their scopes and
+		// cannot act as the user on the API
+		if claims.ClientID != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Success: false,
+				Error:   "OAuth client tokens cannot be used with this API",
+			})
+			return
+		}
+
+		h.setCaller(c, claims)
+		c.Next()
+	}
+}
+
+// ServiceAuthMiddleware authenticates other services on internal routes.
+// Only tokens a machine client obtained for itself with the
+// client_credentials grant are accepted; user tokens are refused.
+func (h *Handlers) ServiceAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := h.extractToken(c)
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="internal"`)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
 				Success: false,
 				Error:   "No token provided",
@@ -232,6 +375,7 @@ func (h *Handlers) AuthMiddleware() gin.HandlerFunc {
 
 		claims, err := h.authService.ValidateToken(c.Request.Context(), token)
 		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="internal", error="invalid_token"`)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
 				Success: false,
 				Error:   "Invalid token",
@@ -239,15 +383,59 @@ func (h *Handlers) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set user ID in context
-		ctx := logging.SetUserID(c.Request.Context(), claims.UserID)
-		c.Request = c.Request.WithContext(ctx)
+		if !claims.IsClientToken() {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Success: false,
+				Error:   "Only service client tokens can be used with this API",
+			})
+			return
+		}
 
+		c.Set(claimsContextKey, claims)
 		c.Next()
 	}
 }
 
+// setCaller sets the authenticated user ID and claims in the request context.
+func (h *Handlers) setCaller(c *gin.Context, claims *auth.JWTClaims) {
+	ctx := logging.SetUserID(c.Request.Context(), claims.UserID)
+	ctx = context.WithValue(ctx, middleware.ContextKeyUser, claims.UserID)
+	c.Request = c.Request.WithContext(ctx)
+	c.Set(claimsContextKey, claims)
+}
+
+// tokenError maps a token validation failure to a status and message
+// without exposing internal errors.
+func tokenError(err error) (int, string) {
+	switch err {
+	case auth.ErrExpiredToken, auth.ErrSessionExpired:
+		return http.StatusUnauthorized, "Token has expired"
+	case auth.ErrTokenRevoked, auth.ErrSessionRevoked:
+		return http.StatusUnauthorized, "Token has been revoked"
+	case auth.ErrInvalidToken, auth.ErrInvalidClaims, auth.ErrTokenNotYetValid,
+		auth.ErrSessionNotFound, auth.ErrSessionInvalid:
+		return http.StatusUnauthorized, "Invalid token"
+	default:
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
+
+// apiKeyError maps an API key authentication failure to a status and message.
+func apiKeyError(err error) (int, string) {
+	switch err {
+	case auth.ErrAPIKeyExpired:
+		return http.StatusUnauthorized, "API key has expired"
+	case auth.ErrAPIKeyInvalid, errors.ErrUserInactive:
+		return http.StatusUnauthorized, "Invalid API key"
+	default:
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
+
 // AuthMiddlewareV1 validates JWT tokens for v1 API routes.
+//
+// API keys are refused: v1 routes have no permission checks, so a key's
+// scopes could not be enforced.
 // Deprecated: Use AuthMiddleware instead.
 // TODO(TEAM-API): Remove after v1 API deprecation
 func (h *Handlers) AuthMiddlewareV1() gin.HandlerFunc {
@@ -256,26 +444,13 @@ func (h *Handlers) AuthMiddlewareV1() gin.HandlerFunc {
 
 		token := h.extractToken(c)
 		if token == "" {
-			// Try legacy API key auth
-			// TODO(TEAM-SEC): Remove legacy API key support
-			apiKey := c.GetHeader("X-API-Key")
-			if apiKey == "" {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"error": "Unauthorized",
-				})
-				return
-			}
-
-			// Validate legacy API key (simplified for demo)
-			if len(apiKey) < 16 {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"error": "Invalid API key",
-				})
-				return
+			message := "Unauthorized"
+			if c.GetHeader("X-API-Key") != "" {
+				message = "API keys are not accepted by the v1 API"
 			}
-
-			c.Set("user_id", "legacy-user-"+apiKey[:8])
-			c.Next()
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": message,
+			})
 			return
 		}
 
@@ -303,6 +478,28 @@ func (h *Handlers) extractToken(c *gin.Context) string {
 	return ""
 }
 
+const (
+	// deviceCookie holds the ID that recognises a returning browser across
+	// sessions. It grants no access on its own.
+	deviceCookie = "acme_device_id"
+
+	// deviceCookieMaxAge is 400 days, the longest browsers will keep a cookie.
+	deviceCookieMaxAge = 400 * 24 * 60 * 60
+)
+
+// deviceID returns the caller's device ID, issuing a new device ID cookie if
+// the request has none or a malformed one.
+func (h *Handlers) deviceID(c *gin.Context) string {
+	if id, err := c.Cookie(deviceCookie); err == nil && ids.IsDeviceID(id) {
+		return id
+	}
+
+	id := ids.NewDeviceID()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(deviceCookie, id, deviceCookieMaxAge, "/", "", h.config.IsProduction(), true)
+	return id
+}
+
 func (h *Handlers) extractSessionID(c *gin.Context) string {
 	token := h.extractToken(c)
 	if token == "" {
@@ -325,17 +522,35 @@ type LoginV1Request struct {
 }
 
 type LoginResponse struct {
-	Success   bool        `json:"success"`
-	Token     string      `json:"token"`
-	User      interface{} `json:"user"`
-	SessionID string      `json:"session_id"`
-	ExpiresAt interface{} `json:"expires_at"`
+	Success          bool        `json:"success"`
+	Token            string      `json:"token"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt interface{} `json:"refresh_expires_at"`
+	User             interface{} `json:"user"`
+	SessionID        string      `json:"session_id"`
+	ExpiresAt        interface{} `json:"expires_at"`
+	PasswordBreached bool        `json:"password_breached,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
 }
 
 type RefreshTokenResponse struct {
-	Success   bool        `json:"success"`
-	Token     string      `json:"token"`
-	ExpiresAt interface{} `json:"expires_at"`
+	Success          bool        `json:"success"`
+	Token            string      `json:"token"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt interface{} `json:"refresh_expires_at"`
+	ExpiresAt        interface{} `json:"expires_at"`
 }
 
 type ValidateTokenResponse struct {
@@ -345,6 +560,6 @@ type ValidateTokenResponse struct {
 }
 
 type SessionsResponse struct {
-	Success  bool            `json:"success"`
-	Sessions []*auth.Session `json:"sessions"`
+	Success  bool                   `json:"success"`
+	Sessions []*service.SessionInfo `json:"sessions"`
 }
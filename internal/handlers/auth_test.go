@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// newV1Router serves a stub /api/v1/users behind AuthMiddlewareV1.
+func newV1Router(jwtService *auth.JWTService) (*gin.Engine, *auth.InMemoryTokenDenylist) {
+	cfg := &config.Config{Features: config.FeatureFlags{EnableV1API: true}}
+	denylist := auth.NewInMemoryTokenDenylist()
+	authService := service.NewAuthService(nil, nil, nil, jwtService, nil, nil,
+		denylist, nil, nil, nil, nil, nil, nil, nil, cfg)
+	h := &Handlers{
+		authService: authService,
+		config:      cfg,
+		logger:      logging.NewLoggerV2("handlers-test"),
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/users", h.AuthMiddlewareV1(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router, denylist
+}
+
+func TestAuthMiddlewareV1(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key", time.Hour)
+	router, denylist := newV1Router(jwtService)
+	user := &models.User{ID: "user-1", Email: "jane@example.com", Role: models.RoleCustomer}
+
+	legacyToken, _ := jwtService.GenerateTokenV1(user.ID, user.Email)
+	revokedToken, _ := jwtService.GenerateTokenV1(user.ID, user.Email)
+	revoked, _ := jwtService.ValidateTokenV1(revokedToken)
+	denylist.Add(context.Background(), revoked.ID, revoked.ExpiresAt.Time)
+	accessToken, _ := jwtService.GenerateToken(user, "")
+	clientToken, _ := jwtService.GenerateClientToken("client-orders", []string{string(auth.PermUsersList)})
+	idToken, _ := jwtService.GenerateIDToken(&auth.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{"client-app"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"legacy token", legacyToken, http.StatusOK},
+		{"revoked legacy token", revokedToken, http.StatusUnauthorized},
+		{"v2 access token", accessToken, http.StatusUnauthorized},
+		{"client token", clientToken, http.StatusUnauthorized},
+		{"ID token", idToken, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	t.Run("API key", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("X-API-Key", "acme_key_secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", w.Code)
+		}
+	})
+}
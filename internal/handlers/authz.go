@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+const (
+	// claimsContextKey is the gin context key holding the caller's *auth.JWTClaims.
+	claimsContextKey = "auth_claims"
+
+	// apiKeyContextKey holds the *repository.APIKey of callers that
+	// authenticated with an API key.
+	apiKeyContextKey = "auth_api_key"
+)
+
+// OwnerFunc resolves the ID of the user that owns the resource targeted by a request.
+type OwnerFunc func(c *gin.Context) (string, error)
+
+// AccessRule describes which callers may use a route.
+// A zero AccessRule only requires an authenticated caller.
+type AccessRule struct {
+	// Any grants access to the route regardless of who owns the resource.
+	Any auth.Permission
+
+	// Self grants access when the caller owns the targeted resource.
+	Self auth.Permission
+
+	// Owner resolves the targeted resource's owner. Required when Self is set.
+	Owner OwnerFunc
+
+	// MFAExempt allows callers whose role requires MFA to use the route
+	// before they have completed it, so they can enroll or sign out.
+	MFAExempt bool
+}
+
+// Authorize enforces an AccessRule for routes behind AuthMiddleware.
+//
+// Callers using an API key are further limited to the key's scopes and can
+// only use routes that name a permission, so a key cannot manage keys,
+// change passwords or reach other account routes.
+func (h *Handlers) Authorize(rule AccessRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := claimsFromContext(c)
+		key := apiKeyFromContext(c)
+		if claims != nil && key == nil && !rule.MFAExempt && h.mfaIncomplete(claims) {
+			h.logger.Warn("MFA required", logging.Fields{
+				"user_id": claims.UserID,
+				"role":    claims.Role,
+				"route":   c.FullPath(),
+			})
+			h.handleError(c, auth.ErrMFARequired)
+			c.Abort()
+			return
+		}
+
+		if rule.Any == "" && rule.Self == "" && key == nil {
+			c.Next()
+			return
+		}
+
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Success: false,
+				Error:   "Not authenticated",
+			})
+			return
+		}
+
+		granted := func(perm auth.Permission) bool {
+			return permissionGranted(claims, key, perm)
+		}
+
+		if rule.Any != "" && granted(rule.Any) {
+			c.Next()
+			return
+		}
+
+		if rule.Self != "" && rule.Owner != nil && granted(rule.Self) {
+			ownerID, err := rule.Owner(c)
+			if err != nil {
+				h.handleError(c, err)
+				c.Abort()
+				return
+			}
+			if ownerID != "" && ownerID == claims.UserID {
+				c.Next()
+				return
+			}
+		}
+
+		fields := logging.Fields{
+			"user_id": claims.UserID,
+			"role":    claims.Role,
+			"method":  c.Request.Method,
+			"route":   c.FullPath(),
+		}
+		if key != nil {
+			fields["key_id"] = key.ID
+		}
+		h.logger.Warn("permission denied", fields)
+
+		h.handleError(c, errors.ErrForbidden)
+		c.Abort()
+	}
+}
+
+// RequireScope authorizes routes behind ServiceAuthMiddleware by the scopes
+// granted to the calling client, regardless of any role.
+func (h *Handlers) RequireScope(scope auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := claimsFromContext(c)
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Success: false,
+				Error:   "Not authenticated",
+			})
+			return
+		}
+
+		if !claims.HasScope(string(scope)) {
+			h.logger.Warn("insufficient scope", logging.Fields{
+				"client_id": claims.ClientID,
+				"scope":     scope,
+				"method":    c.Request.Method,
+				"route":     c.FullPath(),
+			})
+			c.Header("WWW-Authenticate", `Bearer realm="internal", error="insufficient_scope", scope="`+string(scope)+`"`)
+			h.handleError(c, errors.ErrForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// callerHasPermission reports whether the authenticated caller's role grants
+// perm, within the scopes of their API key if they used one.
+func callerHasPermission(c *gin.Context, perm auth.Permission) bool {
+	claims := claimsFromContext(c)
+	return claims != nil && permissionGranted(claims, apiKeyFromContext(c), perm)
+}
+
+func permissionGranted(claims *auth.JWTClaims, key *repository.APIKey, perm auth.Permission) bool {
+	return auth.HasPermission(claims.Role, perm) && (key == nil || key.HasScope(string(perm)))
+}
+
+// mfaIncomplete reports whether the caller's role requires MFA and their
+// token was issued without it.
+func (h *Handlers) mfaIncomplete(claims *auth.JWTClaims) bool {
+	return h.config.MFA.RoleRequiresMFA(string(claims.Role)) &&
+		!auth.HasAuthMethod(claims.AuthMethods, auth.AuthMethodMFA)
+}
+
+// OwnerFromParam treats a path parameter as the owning user's ID.
+func OwnerFromParam(name string) OwnerFunc {
+	return func(c *gin.Context) (string, error) {
+		return c.Param(name), nil
+	}
+}
+
+// SessionOwner resolves the owner of the session named by the :id path parameter.
+func (h *Handlers) SessionOwner(c *gin.Context) (string, error) {
+	session, err := h.authService.GetSession(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		return "", err
+	}
+	return session.UserID, nil
+}
+
+// apiKeyFromContext returns the API key the caller authenticated with, or
+// nil for token-authenticated callers.
+func apiKeyFromContext(c *gin.Context) *repository.APIKey {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if key, ok := v.(*repository.APIKey); ok {
+			return key
+		}
+	}
+	return nil
+}
+
+func claimsFromContext(c *gin.Context) *auth.JWTClaims {
+	if v, ok := c.Get(claimsContextKey); ok {
+		if claims, ok := v.(*auth.JWTClaims); ok {
+			return claims
+		}
+	}
+	return nil
+}
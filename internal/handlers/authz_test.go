@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+func newAuthzRouter(claims *auth.JWTClaims, rule AccessRule) *gin.Engine {
+	return newAPIKeyAuthzRouter(claims, nil, rule)
+}
+
+func newAPIKeyAuthzRouter(claims *auth.JWTClaims, key *repository.APIKey, rule AccessRule) *gin.Engine {
+	h := &Handlers{
+		config: &config.Config{MFA: config.MFAConfig{RequiredRoles: []string{string(models.RoleAdmin)}}},
+		logger: logging.NewLoggerV2("handlers-test"),
+	}
+
+	router := gin.New()
+	router.GET("/users/:id",
+		func(c *gin.Context) {
+			if claims != nil {
+				c.Set(claimsContextKey, claims)
+			}
+			if key != nil {
+				c.Set(apiKeyContextKey, key)
+			}
+			c.Next()
+		},
+		h.Authorize(rule),
+		func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		},
+	)
+	return router
+}
+
+func TestAuthorize(t *testing.T) {
+	userRule := AccessRule{
+		Any:   auth.PermUsersUpdateAny,
+		Self:  auth.PermUsersUpdateSelf,
+		Owner: OwnerFromParam("id"),
+	}
+	adminOnly := AccessRule{Any: auth.PermUsersList}
+	mfaExempt := AccessRule{MFAExempt: true}
+
+	// Admins must use MFA in these tests
+	mfa := []string{auth.AuthMethodPassword, auth.AuthMethodOTP, auth.AuthMethodMFA}
+
+	tests := []struct {
+		name     string
+		claims   *auth.JWTClaims
+		rule     AccessRule
+		target   string
+		expected int
+	}{
+		{"admin on other account", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin, AuthMethods: mfa}, userRule, "user-2", http.StatusOK},
+		{"customer on own account", &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, userRule, "user-1", http.StatusOK},
+		{"customer on other account", &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, userRule, "user-2", http.StatusForbidden},
+		{"vendor on other account", &auth.JWTClaims{UserID: "user-1", Role: models.RoleVendor}, userRule, "user-2", http.StatusForbidden},
+		{"unknown role on own account", &auth.JWTClaims{UserID: "user-1", Role: "guest"}, userRule, "user-1", http.StatusForbidden},
+		{"customer on admin-only route", &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, adminOnly, "user-1", http.StatusForbidden},
+		{"admin on admin-only route", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin, AuthMethods: mfa}, adminOnly, "user-1", http.StatusOK},
+		{"zero rule", &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, AccessRule{}, "user-2", http.StatusOK},
+		{"missing claims", nil, userRule, "user-1", http.StatusUnauthorized},
+		{"admin without MFA", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin}, adminOnly, "user-1", http.StatusForbidden},
+		{"admin without MFA on zero rule", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin}, AccessRule{}, "user-1", http.StatusForbidden},
+		{"admin without MFA on exempt route", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin}, mfaExempt, "user-1", http.StatusOK},
+		{"customer without MFA", &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, userRule, "user-1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAuthzRouter(tt.claims, tt.rule)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users/"+tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAPIKey(t *testing.T) {
+	userRule := AccessRule{
+		Any:   auth.PermUsersReadAny,
+		Self:  auth.PermUsersReadSelf,
+		Owner: OwnerFromParam("id"),
+	}
+	adminOnly := AccessRule{Any: auth.PermUsersList}
+	readSelf := &repository.APIKey{ID: "key-1", Scopes: []string{string(auth.PermUsersReadSelf)}}
+	listUsers := &repository.APIKey{ID: "key-2", Scopes: []string{string(auth.PermUsersList)}}
+	customer := &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}
+	admin := &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin}
+
+	tests := []struct {
+		name     string
+		claims   *auth.JWTClaims
+		key      *repository.APIKey
+		rule     AccessRule
+		target   string
+		expected int
+	}{
+		{"scope granted on own account", customer, readSelf, userRule, "user-1", http.StatusOK},
+		{"scope granted on other account", customer, readSelf, userRule, "user-2", http.StatusForbidden},
+		{"scope missing", customer, listUsers, userRule, "user-1", http.StatusForbidden},
+		{"scope beyond role", customer, listUsers, adminOnly, "user-1", http.StatusForbidden},
+		{"admin key skips MFA", admin, listUsers, adminOnly, "user-1", http.StatusOK},
+		{"zero rule", customer, readSelf, AccessRule{}, "user-1", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newAPIKeyAuthzRouter(tt.claims, tt.key, tt.rule)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users/"+tt.target, nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestUpdateUserAdminFields(t *testing.T) {
+	h := &Handlers{
+		config: &config.Config{},
+		logger: logging.NewLoggerV2("handlers-test"),
+	}
+	customer := &auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}
+	setClaims := func(c *gin.Context) {
+		c.Set(claimsContextKey, customer)
+		c.Next()
+	}
+
+	router := gin.New()
+	router.PUT("/users/:id", setClaims, h.Authorize(AccessRule{
+		Any: auth.PermUsersUpdateAny, Self: auth.PermUsersUpdateSelf, Owner: OwnerFromParam("id"),
+	}), h.UpdateUser)
+	router.PUT("/users/me", setClaims, h.Authorize(AccessRule{}), h.UpdateUserProfile)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"reactivate own account", "/users/user-1", `{"active": true}`},
+		{"deactivate own account", "/users/user-1", `{"active": false}`},
+		{"reactivate through profile", "/users/me", `{"first_name": "Jane", "active": true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("PUT", tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), middleware.ContextKeyUser, customer.UserID))
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected status %d, got %d", http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestAuthorizeOwnerError(t *testing.T) {
+	rule := AccessRule{
+		Self: auth.PermSessionsRevokeSelf,
+		Owner: func(c *gin.Context) (string, error) {
+			return "", auth.ErrSessionNotFound
+		},
+	}
+	router := newAuthzRouter(&auth.JWTClaims{UserID: "user-1", Role: models.RoleCustomer}, rule)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/sess-1", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	h := &Handlers{logger: logging.NewLoggerV2("handlers-test")}
+	orders := &auth.JWTClaims{ClientID: "client-orders", Scope: "users:read:any"}
+
+	tests := []struct {
+		name     string
+		claims   *auth.JWTClaims
+		scope    auth.Permission
+		expected int
+	}{
+		{"scope granted", orders, auth.PermUsersReadAny, http.StatusOK},
+		{"scope missing", orders, auth.PermUsersList, http.StatusForbidden},
+		{"admin role without scope", &auth.JWTClaims{UserID: "user-1", Role: models.RoleAdmin}, auth.PermUsersReadAny, http.StatusForbidden},
+		{"missing claims", nil, auth.PermUsersReadAny, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/internal/users/:id",
+				func(c *gin.Context) {
+					if tt.claims != nil {
+						c.Set(claimsContextKey, tt.claims)
+					}
+					c.Next()
+				},
+				h.RequireScope(tt.scope),
+				func(c *gin.Context) {
+					c.Status(http.StatusOK)
+				},
+			)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/internal/users/user-2", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	if !auth.HasPermission(models.RoleAdmin, auth.PermUsersDeleteAny) {
+		t.Fatal("expected admin to be able to delete any user")
+	}
+	if auth.HasPermission(models.RoleCustomer, auth.PermUsersDeleteAny) {
+		t.Fatal("expected customer not to be able to delete any user")
+	}
+	if !auth.HasPermission(models.RoleCustomer, auth.PermUsersUpdateSelf) {
+		t.Fatal("expected customer to be able to update their own account")
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// GetEmailStatus handles GET /api/v2/users/me/email
+func (h *Handlers) GetEmailStatus(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	status, err := h.userService.GetEmailVerification(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, EmailStatusResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// ChangeEmail handles PUT /api/v2/users/me/email
+func (h *Handlers) ChangeEmail(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Email == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "email and password are required",
+		})
+		return
+	}
+
+	if err := h.userService.RequestEmailChange(c.Request.Context(), userID, req.Email, req.Password); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Message: "A confirmation link has been sent to the new address",
+	})
+}
+
+// VerifyEmail handles POST /api/v2/users/me/email/verify
+func (h *Handlers) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "token is required",
+		})
+		return
+	}
+
+	status, err := h.userService.VerifyEmail(c.Request.Context(), req.Token)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, EmailStatusResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// ResendVerification handles POST /api/v2/auth/email/resend
+func (h *Handlers) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !service.ValidateEmail(req.Email) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "A valid email is required",
+		})
+		return
+	}
+
+	if err := h.userService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Identical for known, unknown and already verified emails
+	c.JSON(http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Message: "If this email needs verification, a new link has been sent",
+	})
+}
+
+// Request and response types
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type EmailStatusResponse struct {
+	Success bool                          `json:"success"`
+	Data    *repository.EmailVerification `json:"data"`
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/federation"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// ListFederatedProviders handles GET /api/v2/auth/federated
+func (h *Handlers) ListFederatedProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, FederatedProvidersResponse{
+		Success: true,
+		Data:    h.federation.Providers(),
+	})
+}
+
+// BeginFederatedLogin handles POST /api/v2/auth/federated/:provider/begin
+func (h *Handlers) BeginFederatedLogin(c *gin.Context) {
+	authz, err := h.federation.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FederatedAuthorizationResponse{
+		Success:       true,
+		Authorization: authz,
+	})
+}
+
+// FinishFederatedLogin handles POST /api/v2/auth/federated/:provider/finish
+func (h *Handlers) FinishFederatedLogin(c *gin.Context) {
+	var req service.FederatedLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "code and state are required",
+		})
+		return
+	}
+
+	req.Provider = c.Param("provider")
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = h.deviceID(c)
+
+	response, err := h.federation.FinishLogin(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.writeLoginResponse(c, response)
+}
+
+// BeginFederatedLink handles POST /api/v2/users/me/federated/:provider/begin
+func (h *Handlers) BeginFederatedLink(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	authz, err := h.federation.BeginLink(c.Request.Context(), userID, c.Param("provider"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FederatedAuthorizationResponse{
+		Success:       true,
+		Authorization: authz,
+	})
+}
+
+// FinishFederatedLink handles POST /api/v2/users/me/federated/:provider/finish
+func (h *Handlers) FinishFederatedLink(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req service.FederatedLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.State == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "code and state are required",
+		})
+		return
+	}
+	req.Provider = c.Param("provider")
+
+	if err := h.federation.FinishLink(c.Request.Context(), userID, &req); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Identity provider linked",
+	})
+}
+
+// Request and response types
+
+type FederatedProvidersResponse struct {
+	Success bool                   `json:"success"`
+	Data    []*federation.Provider `json:"data"`
+}
+
+type FederatedAuthorizationResponse struct {
+	Success bool `json:"success"`
+	*federation.Authorization
+}
@@ -2,28 +2,52 @@ package handlers
 
 import (
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/health"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
 )
 
 // Handlers holds all HTTP handlers for the users service.
 type Handlers struct {
-	userService *service.UserService
-	authService *service.AuthService
-	config      *config.Config
-	logger      *logging.LoggerV2
+	userService  *service.UserService
+	authService  *service.AuthService
+	mfaService   *service.MFAService
+	apiKeys      *service.APIKeyService
+	oauthClients *service.OAuthClientService
+	federation   *service.FederationService
+	sso          *auth.SSOVerifier
+	audit        *audit.Recorder
+	health       *health.Registry
+	config       *config.Config
+	logger       *logging.LoggerV2
 }
 
 // NewHandlers creates a new handlers instance.
 func NewHandlers(
 	userService *service.UserService,
 	authService *service.AuthService,
+	mfaService *service.MFAService,
+	apiKeyService *service.APIKeyService,
+	oauthClientService *service.OAuthClientService,
+	federationService *service.FederationService,
+	ssoVerifier *auth.SSOVerifier,
+	auditLog *audit.Recorder,
+	healthChecks *health.Registry,
 	cfg *config.Config,
 ) *Handlers {
 	return &Handlers{
-		userService: userService,
-		authService: authService,
-		config:      cfg,
-		logger:      logging.NewLoggerV2("handlers"),
+		userService:  userService,
+		authService:  authService,
+		mfaService:   mfaService,
+		apiKeys:      apiKeyService,
+		oauthClients: oauthClientService,
+		federation:   federationService,
+		sso:          ssoVerifier,
+		audit:        auditLog,
+		health:       healthChecks,
+		config:       cfg,
+		logger:       logging.NewLoggerV2("handlers"),
 	}
 }
@@ -2,12 +2,19 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/health"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
 )
 
 func init() {
@@ -187,3 +194,62 @@ func TestLoginRequest(t *testing.T) {
 		t.Fatal("password mismatch")
 	}
 }
+
+func TestMetricsEndpoint(t *testing.T) {
+	metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginSuccess).Inc()
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected int
+	}{
+		{"enabled", true, http.StatusOK},
+		{"disabled", false, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{config: &config.Config{
+				Features: config.FeatureFlags{EnableMetrics: tt.enabled},
+			}}
+			router := gin.New()
+			router.GET("/metrics", h.Metrics)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/metrics", nil)
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d", tt.expected, w.Code)
+			}
+			if tt.enabled && !strings.Contains(w.Body.String(), `users_service_login_attempts_total{reason="success",version="v2"}`) {
+				t.Fatalf("expected login counter in output, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadyHidesDependencyErrors(t *testing.T) {
+	checks := health.NewRegistry()
+	checks.Register("postgres", func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.3.7:5432: connect: connection refused")
+	}, time.Second, true)
+
+	h := &Handlers{health: checks}
+	router := gin.New()
+	router.GET("/ready", h.Ready)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/ready", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if strings.Contains(w.Body.String(), "10.0.3.7") {
+		t.Fatalf("expected no dependency error in body, got %s", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"status":"unhealthy"`) {
+		t.Fatalf("expected unhealthy status, got %s", w.Body.String())
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/health"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
 )
 
 var startTime = time.Now()
@@ -20,18 +22,22 @@ func (h *Handlers) Health(c *gin.Context) {
 	})
 }
 
-// HealthDetailed handles GET /health/detailed
+// HealthDetailed handles GET /health/detailed. It includes raw dependency
+// errors, so it is only served to authenticated admins.
 func (h *Handlers) HealthDetailed(c *gin.Context) {
 	h.logger.Debug("detailed health check", logging.Fields{})
 
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
+	report := h.health.Run(c.Request.Context())
+
 	c.JSON(http.StatusOK, DetailedHealthResponse{
-		Status:  "healthy",
-		Service: "users-service",
-		Version: h.config.ServiceVersion,
-		Uptime:  time.Since(startTime).String(),
+		Status:       string(report.Status),
+		Service:      "users-service",
+		Version:      h.config.ServiceVersion,
+		Uptime:       time.Since(startTime).String(),
+		Dependencies: report.Checks,
 		Runtime: RuntimeInfo{
 			GoVersion:    runtime.Version(),
 			NumGoroutine: runtime.NumGoroutine(),
@@ -49,13 +55,19 @@ func (h *Handlers) HealthDetailed(c *gin.Context) {
 	})
 }
 
-// Ready handles GET /ready
+// Ready handles GET /ready. It is unauthenticated, so it only reports the
+// overall status; failed checks are logged by the registry.
 func (h *Handlers) Ready(c *gin.Context) {
-	// Check database connection
-	// In a real implementation, this would check DB and Redis connectivity
-	
-	c.JSON(http.StatusOK, ReadyResponse{
-		Ready: true,
+	report := h.health.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, ReadyResponse{
+		Ready:  report.Ready(),
+		Status: string(report.Status),
 	})
 }
 
@@ -73,16 +85,7 @@ func (h *Handlers) Metrics(c *gin.Context) {
 		return
 	}
 
-	// In a real implementation, this would use prometheus client
-	// For demo purposes, return a simple metrics response
-	c.String(http.StatusOK, `# HELP users_service_requests_total Total number of requests
-# TYPE users_service_requests_total counter
-users_service_requests_total{method="GET",path="/api/v2/users"} 100
-users_service_requests_total{method="POST",path="/api/v2/auth/login"} 50
-# HELP users_service_active_sessions Number of active sessions
-# TYPE users_service_active_sessions gauge
-users_service_active_sessions 42
-`)
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
 }
 
 // DebugInfo handles GET /debug/info
@@ -121,12 +124,13 @@ type HealthResponse struct {
 }
 
 type DetailedHealthResponse struct {
-	Status   string      `json:"status"`
-	Service  string      `json:"service"`
-	Version  string      `json:"version"`
-	Uptime   string      `json:"uptime"`
-	Runtime  RuntimeInfo `json:"runtime"`
-	Features FeatureInfo `json:"features"`
+	Status       string          `json:"status"`
+	Service      string          `json:"service"`
+	Version      string          `json:"version"`
+	Uptime       string          `json:"uptime"`
+	Dependencies []health.Result `json:"dependencies"`
+	Runtime      RuntimeInfo     `json:"runtime"`
+	Features     FeatureInfo     `json:"features"`
 }
 
 type RuntimeInfo struct {
@@ -146,7 +150,8 @@ type FeatureInfo struct {
 }
 
 type ReadyResponse struct {
-	Ready bool `json:"ready"`
+	Ready  bool   `json:"ready"`
+	Status string `json:"status"`
 }
 
 type DebugInfoResponse struct {
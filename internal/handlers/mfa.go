@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// VerifyMFA handles POST /api/v2/auth/mfa/verify
+func (h *Handlers) VerifyMFA(c *gin.Context) {
+	var req service.VerifyMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "mfa_token and code are required",
+		})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = h.deviceID(c)
+
+	response, err := h.authService.VerifyMFA(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.writeLoginResponse(c, response)
+}
+
+// GetMFAStatus handles GET /api/v2/users/me/mfa
+func (h *Handlers) GetMFAStatus(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	status, err := h.mfaService.Status(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAStatusResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// BeginTOTPEnrollment handles POST /api/v2/users/me/mfa/totp
+func (h *Handlers) BeginTOTPEnrollment(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollmentResponse{
+		Success: true,
+		Data:    enrollment,
+	})
+}
+
+// ConfirmTOTPEnrollment handles POST /api/v2/users/me/mfa/totp/confirm
+func (h *Handlers) ConfirmTOTPEnrollment(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "code is required",
+		})
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmEnrollment(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{
+		Success:       true,
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableTOTP handles DELETE /api/v2/users/me/mfa/totp
+func (h *Handlers) DisableTOTP(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "password and code are required",
+		})
+		return
+	}
+
+	if err := h.mfaService.Disable(c.Request.Context(), userID, req.Password, req.Code); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Multi-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes handles POST /api/v2/users/me/mfa/recovery-codes
+func (h *Handlers) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "code is required",
+		})
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{
+		Success:       true,
+		RecoveryCodes: codes,
+	})
+}
+
+// Request and response types
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type MFAChallengeResponse struct {
+	Success     bool      `json:"success"`
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+
+	PasswordBreached bool `json:"password_breached,omitempty"`
+}
+
+type MFAStatusResponse struct {
+	Success bool               `json:"success"`
+	Data    *service.MFAStatus `json:"data"`
+}
+
+type MFAEnrollmentResponse struct {
+	Success bool                   `json:"success"`
+	Data    *service.MFAEnrollment `json:"data"`
+}
+
+type RecoveryCodesResponse struct {
+	Success       bool     `json:"success"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// CreateOAuthClient handles POST /api/v2/oauth/clients
+func (h *Handlers) CreateOAuthClient(c *gin.Context) {
+	var req service.CreateOAuthClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+		return
+	}
+
+	client, err := h.oauthClients.Create(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreatedOAuthClientResponse{
+		Success: true,
+		Data:    client,
+	})
+}
+
+// ListOAuthClients handles GET /api/v2/oauth/clients
+func (h *Handlers) ListOAuthClients(c *gin.Context) {
+	clients, err := h.oauthClients.List(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, OAuthClientsResponse{
+		Success: true,
+		Data:    clients,
+	})
+}
+
+// DeleteOAuthClient handles DELETE /api/v2/oauth/clients/:id
+func (h *Handlers) DeleteOAuthClient(c *gin.Context) {
+	if err := h.oauthClients.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "OAuth client deleted",
+	})
+}
+
+// Request and response types
+
+type CreatedOAuthClientResponse struct {
+	Success bool                        `json:"success"`
+	Data    *service.CreatedOAuthClient `json:"data"`
+}
+
+type OAuthClientsResponse struct {
+	Success bool           `json:"success"`
+	Data    []*oidc.Client `json:"data"`
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+)
+
+// VerifySSOAssertion handles POST /internal/sso/verify
+//
+// Services that receive assertions from the legacy SSO provider verify them
+// here, so every verification is counted by signature version and MD5
+// signatures are only accepted while ENABLE_LEGACY_SSO_MD5 is set.
+func (h *Handlers) VerifySSOAssertion(c *gin.Context) {
+	if h.sso == nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Legacy SSO is not configured",
+		})
+		return
+	}
+
+	var assertion auth.SSOAssertion
+	if err := c.ShouldBindJSON(&assertion); err != nil || assertion.Signature == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "payload and signature are required",
+		})
+		return
+	}
+
+	if err := h.sso.Verify(c.Request.Context(), &assertion); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SSOVerificationResponse{
+		Success: true,
+		Payload: assertion.Payload,
+	})
+}
+
+type SSOVerificationResponse struct {
+	Success bool   `json:"success"`
+	Payload string `json:"payload"`
+}
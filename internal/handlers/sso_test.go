@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+func TestVerifySSOAssertion(t *testing.T) {
+	const secret = "sso-test-secret"
+	nonce := strings.Repeat("n", 32)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		verifier  *auth.SSOVerifier
+		assertion auth.SSOAssertion
+		expected  int
+	}{
+		{
+			"valid v1 assertion",
+			auth.NewSSOVerifier(config.LegacySSOConfig{Secret: secret, MaxAge: time.Minute}, auth.NewInMemorySSONonceStore(), false),
+			auth.SSOAssertion{Payload: "user-1", Timestamp: now, Nonce: nonce, Signature: auth.SignSSOAssertion(secret, "user-1", now, nonce)},
+			http.StatusOK,
+		},
+		{
+			"md5 signature while disabled",
+			auth.NewSSOVerifier(config.LegacySSOConfig{Secret: secret, MaxAge: time.Minute}, auth.NewInMemorySSONonceStore(), false),
+			auth.SSOAssertion{Payload: "user-1", Signature: "0123456789abcdef0123456789abcdef"},
+			http.StatusUnauthorized,
+		},
+		{
+			"not configured",
+			nil,
+			auth.SSOAssertion{Payload: "user-1", Timestamp: now, Nonce: nonce, Signature: auth.SignSSOAssertion(secret, "user-1", now, nonce)},
+			http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handlers{sso: tt.verifier, logger: logging.NewLoggerV2("handlers-test")}
+			router := gin.New()
+			router.POST("/internal/sso/verify", h.VerifySSOAssertion)
+
+			body, _ := json.Marshal(tt.assertion)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/internal/sso/verify", bytes.NewReader(body))
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+		})
+	}
+}
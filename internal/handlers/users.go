@@ -9,6 +9,8 @@ import (
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/federation"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
 )
 
@@ -135,6 +137,9 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 		})
 		return
 	}
+	if !h.mayUpdateUserFields(c, &req) {
+		return
+	}
 
 	h.logger.Info("UpdateUser called", logging.Fields{"user_id": userID})
 
@@ -150,6 +155,25 @@ func (h *Handlers) UpdateUser(c *gin.Context) {
 	})
 }
 
+// mayUpdateUserFields rejects changes to admin-only fields from callers who
+// may only update their own account, so a deactivated user cannot reactivate
+// themselves from a session that is still valid.
+func (h *Handlers) mayUpdateUserFields(c *gin.Context, req *models.UpdateUserRequest) bool {
+	if req.Active == nil || callerHasPermission(c, auth.PermUsersUpdateAny) {
+		return true
+	}
+
+	h.logger.Warn("non-admin attempted to change account status", logging.Fields{
+		"user_id": middleware.GetUserFromContext(c.Request.Context()),
+		"target":  c.Param("id"),
+	})
+	c.JSON(http.StatusForbidden, ErrorResponse{
+		Success: false,
+		Error:   "Only administrators can activate or deactivate accounts",
+	})
+	return false
+}
+
 // DeleteUser handles DELETE /api/v2/users/:id
 func (h *Handlers) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
@@ -167,6 +191,23 @@ func (h *Handlers) DeleteUser(c *gin.Context) {
 	})
 }
 
+// UnlockUser handles POST /api/v2/users/:id/unlock
+func (h *Handlers) UnlockUser(c *gin.Context) {
+	userID := c.Param("id")
+
+	h.logger.Info("UnlockUser called", logging.Fields{"user_id": userID})
+
+	if err := h.authService.UnlockAccount(c.Request.Context(), userID); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
 // ListUsers handles GET /api/v2/users
 func (h *Handlers) ListUsers(c *gin.Context) {
 	filter := h.parseUserListFilter(c)
@@ -264,6 +305,9 @@ func (h *Handlers) UpdateUserProfile(c *gin.Context) {
 		})
 		return
 	}
+	if !h.mayUpdateUserFields(c, &req) {
+		return
+	}
 
 	user, err := h.userService.UpdateUser(c.Request.Context(), userID, &req)
 	if err != nil {
@@ -338,6 +382,15 @@ func (h *Handlers) parseIntQuery(c *gin.Context, key string, defaultValue int) i
 }
 
 func (h *Handlers) handleError(c *gin.Context, err error) {
+	if policyErr, ok := err.(*auth.PasswordPolicyError); ok {
+		c.JSON(http.StatusBadRequest, PasswordPolicyErrorResponse{
+			Success:    false,
+			Error:      "Password does not meet the password policy",
+			Violations: policyErr.Violations,
+		})
+		return
+	}
+
 	switch err {
 	case errors.ErrNotFound:
 		c.JSON(http.StatusNotFound, ErrorResponse{
@@ -359,6 +412,147 @@ func (h *Handlers) handleError(c *gin.Context, err error) {
 			Success: false,
 			Error:   "User account is inactive",
 		})
+	case errors.ErrForbidden:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "Insufficient permissions",
+		})
+	case auth.ErrInvalidToken, auth.ErrExpiredToken, auth.ErrRefreshTokenReused,
+		auth.ErrSessionExpired, auth.ErrSessionInvalid:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Invalid or expired token",
+		})
+	case auth.ErrAccountLocked:
+		c.JSON(http.StatusLocked, ErrorResponse{
+			Success: false,
+			Error:   "Account is temporarily locked",
+		})
+	case auth.ErrTooManyAttempts:
+		c.JSON(http.StatusTooManyRequests, ErrorResponse{
+			Success: false,
+			Error:   "Too many failed login attempts",
+		})
+	case errors.ErrValidation:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid request",
+		})
+	case errors.ErrAlreadyExists:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Resource already exists",
+		})
+	case auth.ErrPasswordMismatch:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Current password is incorrect",
+		})
+	case auth.ErrEmailNotVerified:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "Email address is not verified",
+		})
+	case auth.ErrMFARequired:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "Multi-factor authentication is required",
+		})
+	case auth.ErrInvalidMFACode:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Invalid authentication code",
+		})
+	case auth.ErrMFAAlreadyEnabled:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Multi-factor authentication is already enabled",
+		})
+	case auth.ErrMFANotEnabled:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Multi-factor authentication is not enabled",
+		})
+	case auth.ErrWebAuthnInvalidResponse:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Invalid passkey response",
+		})
+	case auth.ErrTooManySessions:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Too many active sessions; sign out of another device first",
+		})
+	case auth.ErrSessionNotFound:
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Session not found",
+		})
+	case federation.ErrUnknownProvider:
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Success: false,
+			Error:   "Identity provider not found",
+		})
+	case federation.ErrInvalidState:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "Sign-in request is invalid or has expired",
+		})
+	case federation.ErrInvalidIDToken:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Identity provider sign-in failed",
+		})
+	case federation.ErrProviderUnavailable:
+		c.JSON(http.StatusBadGateway, ErrorResponse{
+			Success: false,
+			Error:   "Identity provider is unavailable",
+		})
+	case auth.ErrFederatedEmailUnverified:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "Identity provider did not verify your email address",
+		})
+	case auth.ErrFederatedAccountUnverified:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Verify your email address before signing in with this identity provider",
+		})
+	case auth.ErrFederatedAccountNotFound:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "No account exists for this identity",
+		})
+	case auth.ErrFederatedDomainNotAllowed:
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Success: false,
+			Error:   "This identity provider cannot be used for your email address",
+		})
+	case auth.ErrFederatedLinkRequired:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "Sign in to your account and link this identity provider first",
+		})
+	case auth.ErrFederatedIdentityInUse:
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Success: false,
+			Error:   "This identity is linked to another account",
+		})
+	case auth.ErrInvalidSSOSignature, auth.ErrLegacySSOMD5Disabled:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Invalid SSO assertion",
+		})
+	case auth.ErrSSOAssertionExpired:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "SSO assertion has expired",
+		})
+	case auth.ErrSSOAssertionReplayed:
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "SSO assertion has already been used",
+		})
 	default:
 		h.logger.Error("handler error", logging.Fields{
 			"error": err.Error(),
@@ -394,6 +588,14 @@ type ErrorResponse struct {
 	Error   string `json:"error"`
 }
 
+// PasswordPolicyErrorResponse lists every password policy rule a new
+// password failed, so clients can show them all at once.
+type PasswordPolicyErrorResponse struct {
+	Success    bool                     `json:"success"`
+	Error      string                   `json:"error"`
+	Violations []auth.PasswordViolation `json:"violations"`
+}
+
 type SuccessResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/service"
+)
+
+// BeginPasskeyRegistration handles POST /api/v2/auth/webauthn/register/begin
+func (h *Handlers) BeginPasskeyRegistration(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	options, err := h.authService.BeginPasskeyRegistration(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PasskeyRegistrationOptionsResponse{
+		Success:   true,
+		PublicKey: options,
+	})
+}
+
+// FinishPasskeyRegistration handles POST /api/v2/auth/webauthn/register/finish
+func (h *Handlers) FinishPasskeyRegistration(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	var req FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Credential.ID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "credential is required",
+		})
+		return
+	}
+
+	cred, err := h.authService.FinishPasskeyRegistration(c.Request.Context(), userID, req.Name, &req.Credential)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, PasskeyResponse{
+		Success: true,
+		Data:    cred,
+	})
+}
+
+// ListPasskeys handles GET /api/v2/auth/webauthn/credentials
+func (h *Handlers) ListPasskeys(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	creds, err := h.authService.ListPasskeys(c.Request.Context(), userID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PasskeysResponse{
+		Success: true,
+		Data:    creds,
+	})
+}
+
+// DeletePasskey handles DELETE /api/v2/auth/webauthn/credentials/:id
+func (h *Handlers) DeletePasskey(c *gin.Context) {
+	userID := middleware.GetUserFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Success: false,
+			Error:   "Not authenticated",
+		})
+		return
+	}
+
+	if err := h.authService.DeletePasskey(c.Request.Context(), userID, c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: "Passkey removed",
+	})
+}
+
+// BeginPasskeyLogin handles POST /api/v2/auth/webauthn/login/begin
+func (h *Handlers) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.authService.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PasskeyLoginOptionsResponse{
+		Success:   true,
+		PublicKey: options,
+	})
+}
+
+// FinishPasskeyLogin handles POST /api/v2/auth/webauthn/login/finish
+func (h *Handlers) FinishPasskeyLogin(c *gin.Context) {
+	var req service.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Credential.ID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "credential is required",
+		})
+		return
+	}
+
+	req.IPAddress = c.ClientIP()
+	req.UserAgent = c.GetHeader("User-Agent")
+	req.DeviceID = h.deviceID(c)
+
+	response, err := h.authService.FinishPasskeyLogin(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.writeLoginResponse(c, response)
+}
+
+// Request and response types
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string                    `json:"name"`
+	Credential auth.RegistrationResponse `json:"credential"`
+}
+
+type PasskeyRegistrationOptionsResponse struct {
+	Success   bool                            `json:"success"`
+	PublicKey *auth.CredentialCreationOptions `json:"publicKey"`
+}
+
+type PasskeyLoginOptionsResponse struct {
+	Success   bool                           `json:"success"`
+	PublicKey *auth.CredentialRequestOptions `json:"publicKey"`
+}
+
+type PasskeyResponse struct {
+	Success bool                           `json:"success"`
+	Data    *repository.WebAuthnCredential `json:"data"`
+}
+
+type PasskeysResponse struct {
+	Success bool                             `json:"success"`
+	Data    []*repository.WebAuthnCredential `json:"data"`
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Probe checks a single dependency and returns an error if it is unavailable.
+type Probe func(ctx context.Context) error
+
+// Status is the state of a dependency or of the service as a whole.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+
+	// StatusHealthy means every dependency is up.
+	StatusHealthy Status = "healthy"
+	// StatusDegraded means only non-critical dependencies are down.
+	StatusDegraded Status = "degraded"
+	// StatusUnhealthy means at least one critical dependency is down.
+	StatusUnhealthy Status = "unhealthy"
+)
+
+// Check is a registered dependency probe.
+type Check struct {
+	Name    string
+	Probe   Probe
+	Timeout time.Duration
+
+	// Critical dependencies make the service unready when they are down.
+	Critical bool
+}
+
+// Result is the outcome of running one check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of running every registered check.
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready reports whether every critical dependency is up.
+func (r *Report) Ready() bool {
+	return r.Status != StatusUnhealthy
+}
+
+// Registry holds the dependency checks of the service.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []Check
+	logger *logging.LoggerV2
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		logger: logging.NewLoggerV2("health"),
+	}
+}
+
+// Register adds a dependency probe. Each run of the probe is cancelled after
+// timeout.
+func (r *Registry) Register(name string, probe Probe, timeout time.Duration, critical bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, Check{
+		Name:     name,
+		Probe:    probe,
+		Timeout:  timeout,
+		Critical: critical,
+	})
+}
+
+// Run executes every check concurrently and aggregates the results in
+// registration order.
+func (r *Registry) Run(ctx context.Context) *Report {
+	r.mu.RLock()
+	checks := make([]Check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = r.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusHealthy, Checks: results}
+	for _, res := range results {
+		if res.Status == StatusUp {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusUnhealthy
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runProbe(ctx, check.Probe)
+	latency := time.Since(start)
+
+	res := Result{
+		Name:      check.Name,
+		Status:    StatusUp,
+		Critical:  check.Critical,
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+
+		r.logger.Warn("dependency check failed", logging.Fields{
+			"dependency": check.Name,
+			"critical":   check.Critical,
+			"error":      err.Error(),
+		})
+	}
+
+	return res
+}
+
+// runProbe waits for the probe or the deadline, whichever comes first, so a
+// probe that ignores its context cannot stall the report.
+func runProbe(ctx context.Context, probe Probe) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- probe(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
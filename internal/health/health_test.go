@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func up(ctx context.Context) error { return nil }
+
+func down(ctx context.Context) error { return errors.New("connection refused") }
+
+func hang(ctx context.Context) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestRegistryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		cache    Probe
+		database Probe
+		expected Status
+		ready    bool
+	}{
+		{"all up", up, up, StatusHealthy, true},
+		{"non-critical down", down, up, StatusDegraded, true},
+		{"critical down", up, down, StatusUnhealthy, false},
+		{"critical timeout", up, hang, StatusUnhealthy, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			r.Register("postgres", tt.database, 20*time.Millisecond, true)
+			r.Register("cache_redis", tt.cache, 20*time.Millisecond, false)
+
+			report := r.Run(context.Background())
+
+			if report.Status != tt.expected {
+				t.Fatalf("expected status %s, got %s", tt.expected, report.Status)
+			}
+			if report.Ready() != tt.ready {
+				t.Fatalf("expected ready=%v, got %v", tt.ready, report.Ready())
+			}
+			if len(report.Checks) != 2 || report.Checks[0].Name != "postgres" {
+				t.Fatalf("expected results in registration order, got %+v", report.Checks)
+			}
+		})
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Register("slow", hang, 20*time.Millisecond, true)
+
+	start := time.Now()
+	report := r.Run(context.Background())
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected probe to be cut off at its timeout, took %v", elapsed)
+	}
+	if report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected deadline error, got %q", report.Checks[0].Error)
+	}
+}
@@ -0,0 +1,130 @@
+// Package ids generates identifiers for users, sessions and other records
+// from crypto/rand.
+package ids
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+// ID prefixes. IDs issued before this package existed use the same prefixes
+// with shorter bodies and remain valid.
+const (
+	UserPrefix    = "user-"
+	SessionPrefix = "sess-"
+	APIKeyPrefix  = "key-"
+	DevicePrefix  = "dev-"
+	ClientPrefix  = "client-"
+)
+
+const (
+	// sessionBytes gives session IDs 256 bits of entropy.
+	sessionBytes = 32
+
+	// deviceBytes gives device IDs 128 bits of entropy. Device IDs only
+	// recognise returning browsers; they grant no access.
+	deviceBytes = 16
+
+	// maxBodyLen bounds accepted ID bodies; IDs are stored in VARCHAR(50).
+	maxBodyLen = 45
+
+	// crockford is the ULID alphabet: Crockford's base32 without I, L, O, U.
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// NewUserID returns a ULID-based user ID. User IDs sort by creation time, to
+// the millisecond.
+func NewUserID() string {
+	return UserPrefix + NewULID(time.Now())
+}
+
+// NewSessionID returns a session ID carrying 256 random bits.
+func NewSessionID() string {
+	return SessionPrefix + base64.RawURLEncoding.EncodeToString(randomBytes(sessionBytes))
+}
+
+// NewDeviceID returns a random ID for a browser or app installation.
+func NewDeviceID() string {
+	return DevicePrefix + base64.RawURLEncoding.EncodeToString(randomBytes(deviceBytes))
+}
+
+// NewAPIKeyID returns a ULID-based API key ID.
+func NewAPIKeyID() string {
+	return APIKeyPrefix + NewULID(time.Now())
+}
+
+// NewClientID returns a ULID-based OAuth client ID.
+func NewClientID() string {
+	return ClientPrefix + NewULID(time.Now())
+}
+
+// NewTokenID returns a ULID to identify a JWT in its jti claim, so a single
+// token can be revoked.
+func NewTokenID() string {
+	return NewULID(time.Now())
+}
+
+// NewULID returns a ULID: a 48-bit millisecond timestamp followed by 80
+// random bits, encoded as 26 Crockford base32 characters. ULIDs created in
+// the same millisecond are not ordered relative to each other.
+func NewULID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	copy(b[6:], randomBytes(10))
+	return encodeULID(b)
+}
+
+// IsSessionID reports whether id is a well-formed session ID, either a
+// current one or one issued before this package existed.
+func IsSessionID(id string) bool {
+	return hasRandomBody(id, SessionPrefix)
+}
+
+// IsDeviceID reports whether id is a well-formed device ID.
+func IsDeviceID(id string) bool {
+	return hasRandomBody(id, DevicePrefix)
+}
+
+// hasRandomBody reports whether id is prefix followed by a base64url body.
+func hasRandomBody(id, prefix string) bool {
+	body, ok := strings.CutPrefix(id, prefix)
+	if !ok || body == "" || len(body) > maxBodyLen {
+		return false
+	}
+	for _, c := range body {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, most significant
+// first. The leading character only carries two bits.
+func encodeULID(b [16]byte) string {
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// randomBytes panics if the system random source fails: issuing a
+// predictable ID is worse than failing the request.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("ids: crypto/rand failed: " + err.Error())
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+package ids
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewUserID(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := NewUserID()
+		if !strings.HasPrefix(id, UserPrefix) || len(id) != len(UserPrefix)+26 {
+			t.Fatalf("expected user- prefixed ULID, got %s", id)
+		}
+		if seen[id] {
+			t.Fatalf("expected unique IDs, got %s twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestNewULIDSortsByTime(t *testing.T) {
+	earlier := NewULID(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := NewULID(time.Date(2024, 1, 1, 0, 0, 0, int(time.Millisecond), time.UTC))
+
+	if earlier >= later {
+		t.Fatalf("expected %s to sort before %s", earlier, later)
+	}
+	if strings.Trim(earlier, crockford) != "" {
+		t.Fatalf("expected only Crockford base32 characters, got %s", earlier)
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	// Maximum ULID from the specification
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+	if got := encodeULID(max); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("expected 7ZZZZZZZZZZZZZZZZZZZZZZZZZ, got %s", got)
+	}
+
+	// The timestamp occupies the first 10 characters
+	id := NewULID(time.UnixMilli(1469918176385))
+	if id[:10] != "01ARYZ6S41" {
+		t.Fatalf("expected timestamp 01ARYZ6S41, got %s", id[:10])
+	}
+}
+
+func TestNewSessionID(t *testing.T) {
+	first, second := NewSessionID(), NewSessionID()
+	if first == second {
+		t.Fatal("expected session IDs to differ")
+	}
+	if len(first) != len(SessionPrefix)+43 {
+		t.Fatalf("expected 256-bit session ID, got %s", first)
+	}
+	if !IsSessionID(first) {
+		t.Fatalf("expected %s to be a valid session ID", first)
+	}
+}
+
+func TestIsSessionID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"sess-abcdefghijklmnopqrstuvwx", true},
+		{"sess-Ab9_-x", true},
+		{"sess-", false},
+		{"user-abcdef", false},
+		{"sess-abc:def", false},
+		{"sess-" + strings.Repeat("a", 46), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSessionID(tt.id); got != tt.valid {
+			t.Fatalf("expected IsSessionID(%q) = %v, got %v", tt.id, tt.valid, got)
+		}
+	}
+}
+
+func TestNewDeviceID(t *testing.T) {
+	id := NewDeviceID()
+	if len(id) != len(DevicePrefix)+22 {
+		t.Fatalf("expected 128-bit device ID, got %s", id)
+	}
+	if !IsDeviceID(id) {
+		t.Fatalf("expected %s to be a valid device ID", id)
+	}
+	if IsDeviceID(NewSessionID()) || IsDeviceID("dev-a b") {
+		t.Fatal("expected malformed device IDs to be rejected")
+	}
+}
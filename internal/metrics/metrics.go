@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+const namespace = "users_service"
+
+// Login outcomes used as the reason label of LoginAttempts.
+const (
+	LoginSuccess         = "success"
+	LoginUnknownUser     = "unknown_user"
+	LoginInvalidPassword = "invalid_password"
+	LoginInactive        = "inactive"
+	LoginUnverified      = "unverified"
+	LoginMFAPending      = "mfa_pending"
+	LoginInvalidMFACode  = "invalid_mfa_code"
+	LoginMFARequired     = "mfa_required"
+	LoginInvalidPasskey  = "invalid_passkey"
+	LoginFederatedFailed = "federated_failed"
+	LoginUnlinked        = "unlinked"
+	LoginLocked          = "locked"
+	LoginThrottled       = "throttled"
+	LoginError           = "error"
+)
+
+// Registry holds every collector exported by the service. A dedicated
+// registry keeps test binaries and library defaults out of /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes request latency by route template, so
+	// path parameters do not create a series per user.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// LoginAttempts counts logins by API version and outcome.
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by API version and outcome.",
+	}, []string{"version", "reason"})
+
+	// PasswordChecks counts password verifications by stored hash type, which
+	// tracks progress of the legacy hash migration.
+	PasswordChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "password_checks_total",
+		Help:      "Password verifications by stored hash type and result.",
+	}, []string{"hash_type", "result"})
+
+	// SSOVerifications counts SSO assertion verifications by signature
+	// version, which tracks callers still sending legacy MD5 signatures.
+	SSOVerifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sso_verifications_total",
+		Help:      "SSO assertion verifications by signature version and result.",
+	}, []string{"version", "result"})
+
+	// BreachedPasswords counts passwords found in the breached-password
+	// corpus, whether offered as a new password or used to log in.
+	BreachedPasswords = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "breached_passwords_total",
+		Help:      "Passwords found in the breached-password corpus.",
+	})
+
+	// UserCacheRequests counts user cache lookups by result.
+	UserCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "user_cache_requests_total",
+		Help:      "User cache lookups by result (hit, miss or error).",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		LoginAttempts,
+		PasswordChecks,
+		SSOVerifications,
+		BreachedPasswords,
+		UserCacheRequests,
+	)
+}
+
+// scrapeTimeout bounds lookups performed while serving /metrics.
+const scrapeTimeout = 2 * time.Second
+
+// RegisterDBStats exports connection pool statistics for db.
+func RegisterDBStats(db *sql.DB, dbName string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterActiveSessions exports the number of active sessions, counted at
+// scrape time so the value is shared by every replica.
+func RegisterActiveSessions(count func(ctx context.Context) (int64, error)) {
+	logger := logging.NewLoggerV2("metrics")
+
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Number of unexpired, unrevoked sessions.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+		defer cancel()
+
+		n, err := count(ctx)
+		if err != nil {
+			logger.Warn("failed to count active sessions", logging.Fields{"error": err.Error()})
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
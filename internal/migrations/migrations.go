@@ -224,4 +224,264 @@ var allMigrations = []Migration{
 		`,
 		Rollback: `DROP TABLE IF EXISTS api_keys;`,
 	},
+	{
+		ID:   6,
+		Name: "extend_audit_log_table",
+		SQL: `
+			-- user_id is the actor. Audit records must outlive the users they
+			-- mention, so the column no longer references users.
+			ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_user_id_fkey;
+			ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS request_id VARCHAR(100);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource_type, resource_id);
+			CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
+		`,
+		Rollback: `
+			DROP INDEX IF EXISTS idx_audit_log_action;
+			DROP INDEX IF EXISTS idx_audit_log_resource;
+			ALTER TABLE audit_log DROP COLUMN IF EXISTS request_id;
+		`,
+	},
+	{
+		ID:   7,
+		Name: "add_email_verification_columns",
+		SQL: `
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT false;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+			-- Existing accounts predate verification and are trusted as they
+			-- are, so that REQUIRE_EMAIL_VERIFICATION does not lock them out.
+			-- Only accounts created from now on start unverified.
+			UPDATE users SET email_verified = true, email_verified_at = created_at
+			WHERE email_verified = false AND email_verified_at IS NULL;
+
+			-- Requested address, held until confirmed; email keeps the old one
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(255);
+		`,
+		Rollback: `
+			ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
+			ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
+			ALTER TABLE users DROP COLUMN IF EXISTS email_verified;
+		`,
+	},
+	{
+		ID:   8,
+		Name: "create_mfa_tables",
+		SQL: `
+			-- totp_secret is encrypted by the application, never stored in clear.
+			-- last_used_step prevents a code from being accepted twice.
+			CREATE TABLE IF NOT EXISTS user_mfa (
+				user_id VARCHAR(50) PRIMARY KEY REFERENCES users(id),
+				totp_secret TEXT NOT NULL,
+				enabled BOOLEAN NOT NULL DEFAULT false,
+				last_used_step BIGINT,
+				confirmed_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+
+			CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+				id SERIAL PRIMARY KEY,
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id),
+				code_hash VARCHAR(64) NOT NULL,
+				used_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+		`,
+		Rollback: `
+			DROP TABLE IF EXISTS mfa_recovery_codes;
+			DROP TABLE IF EXISTS user_mfa;
+		`,
+	},
+	{
+		ID:   9,
+		Name: "create_webauthn_credentials_table",
+		SQL: `
+			-- id is the base64url credential ID chosen by the authenticator;
+			-- public_key is the COSE-encoded credential public key
+			CREATE TABLE IF NOT EXISTS webauthn_credentials (
+				id VARCHAR(1400) PRIMARY KEY,
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id),
+				name VARCHAR(100) NOT NULL,
+				public_key BYTEA NOT NULL,
+				sign_count BIGINT NOT NULL DEFAULT 0,
+				transports TEXT[] NOT NULL DEFAULT '{}',
+				aaguid VARCHAR(32),
+				created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+				last_used_at TIMESTAMP
+			);
+			CREATE INDEX idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+		`,
+		Rollback: `DROP TABLE IF EXISTS webauthn_credentials;`,
+	},
+	{
+		ID:   10,
+		Name: "add_api_key_prefix_and_scopes",
+		SQL: `
+			-- key_prefix is the lookup ID embedded in each key; key_hash is a
+			-- salted hash of the whole key. Keys created before prefixes
+			-- existed cannot be looked up and are deactivated.
+			ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_prefix VARCHAR(16);
+			ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+			ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMP;
+			UPDATE api_keys SET active = false, revoked_at = NOW() WHERE key_prefix IS NULL AND active;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_prefix ON api_keys(key_prefix);
+		`,
+		Rollback: `
+			DROP INDEX IF EXISTS idx_api_keys_key_prefix;
+			ALTER TABLE api_keys DROP COLUMN IF EXISTS revoked_at;
+			ALTER TABLE api_keys DROP COLUMN IF EXISTS scopes;
+			ALTER TABLE api_keys DROP COLUMN IF EXISTS key_prefix;
+		`,
+	},
+	{
+		ID:   11,
+		Name: "create_password_history_table",
+		SQL: `
+			-- Previous password hashes, so users cannot cycle back to one
+			CREATE TABLE IF NOT EXISTS password_history (
+				id BIGSERIAL PRIMARY KEY,
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id),
+				password_hash VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT NOW()
+			);
+			CREATE INDEX idx_password_history_user_id ON password_history(user_id, created_at DESC);
+		`,
+		Rollback: `DROP TABLE IF EXISTS password_history;`,
+	},
+	{
+		ID:   12,
+		Name: "add_password_breached_flag",
+		SQL: `
+			-- Set when a login shows the current password is in the breached
+			-- password corpus; cleared whenever the password changes
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS password_breached BOOLEAN NOT NULL DEFAULT false;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS password_breached_at TIMESTAMP;
+		`,
+		Rollback: `
+			ALTER TABLE users DROP COLUMN IF EXISTS password_breached_at;
+			ALTER TABLE users DROP COLUMN IF EXISTS password_breached;
+		`,
+	},
+	{
+		ID:   13,
+		Name: "add_session_store_columns",
+		SQL: `
+			-- Lets the sessions table back auth.PostgresSessionStore
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS email VARCHAR(255) NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS role VARCHAR(50) NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS auth_methods TEXT[] NOT NULL DEFAULT '{}';
+		`,
+		Rollback: `
+			ALTER TABLE sessions DROP COLUMN IF EXISTS auth_methods;
+			ALTER TABLE sessions DROP COLUMN IF EXISTS role;
+			ALTER TABLE sessions DROP COLUMN IF EXISTS email;
+		`,
+	},
+	{
+		ID:   14,
+		Name: "add_session_activity_columns",
+		SQL: `
+			-- Sessions expire after an idle timeout that slides with activity,
+			-- capped by an absolute lifetime. Existing sessions keep their
+			-- current expiry as both.
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP;
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS max_expires_at TIMESTAMP;
+			UPDATE sessions SET last_activity_at = created_at, max_expires_at = expires_at
+				WHERE last_activity_at IS NULL;
+			ALTER TABLE sessions ALTER COLUMN last_activity_at SET NOT NULL;
+			ALTER TABLE sessions ALTER COLUMN max_expires_at SET NOT NULL;
+		`,
+		Rollback: `
+			ALTER TABLE sessions DROP COLUMN IF EXISTS max_expires_at;
+			ALTER TABLE sessions DROP COLUMN IF EXISTS last_activity_at;
+		`,
+	},
+	{
+		ID:   15,
+		Name: "create_user_devices_table",
+		SQL: `
+			-- Browsers and apps each user has logged in from, identified by
+			-- the device ID cookie
+			CREATE TABLE IF NOT EXISTS user_devices (
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				device_id VARCHAR(50) NOT NULL,
+				browser VARCHAR(50) NOT NULL DEFAULT '',
+				os VARCHAR(50) NOT NULL DEFAULT '',
+				device_type VARCHAR(20) NOT NULL DEFAULT '',
+				last_ip_address VARCHAR(50),
+				first_seen_at TIMESTAMP NOT NULL,
+				last_seen_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, device_id)
+			);
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS device_id VARCHAR(50) NOT NULL DEFAULT '';
+		`,
+		Rollback: `
+			ALTER TABLE sessions DROP COLUMN IF EXISTS device_id;
+			DROP TABLE IF EXISTS user_devices;
+		`,
+	},
+	{
+		ID:   16,
+		Name: "create_oauth_tables",
+		SQL: `
+			-- Applications that sign users in through the OpenID Connect
+			-- provider. Public clients have no secret and rely on PKCE.
+			CREATE TABLE IF NOT EXISTS oauth_clients (
+				id VARCHAR(50) PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				secret_hash VARCHAR(255),
+				redirect_uris TEXT[] NOT NULL,
+				trusted BOOLEAN NOT NULL DEFAULT FALSE,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS oauth_consents (
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				client_id VARCHAR(50) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+				scopes TEXT[] NOT NULL,
+				granted_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (user_id, client_id)
+			);
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS client_id VARCHAR(50) NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+		`,
+		Rollback: `
+			ALTER TABLE sessions DROP COLUMN IF EXISTS scopes;
+			ALTER TABLE sessions DROP COLUMN IF EXISTS client_id;
+			DROP TABLE IF EXISTS oauth_consents;
+			DROP TABLE IF EXISTS oauth_clients;
+		`,
+	},
+	{
+		ID:   17,
+		Name: "create_federated_identities_table",
+		SQL: `
+			-- Accounts at external identity providers linked to local users
+			CREATE TABLE IF NOT EXISTS federated_identities (
+				provider VARCHAR(50) NOT NULL,
+				subject VARCHAR(255) NOT NULL,
+				user_id VARCHAR(50) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				email VARCHAR(255) NOT NULL,
+				created_at TIMESTAMP NOT NULL,
+				last_login_at TIMESTAMP,
+				PRIMARY KEY (provider, subject)
+			);
+			CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities(user_id);
+		`,
+		Rollback: `
+			DROP TABLE IF EXISTS federated_identities;
+		`,
+	},
+	{
+		ID:   18,
+		Name: "add_oauth_client_scopes",
+		SQL: `
+			-- Service scopes machine clients may request for themselves
+			-- with the client_credentials grant
+			ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+		`,
+		Rollback: `
+			ALTER TABLE oauth_clients DROP COLUMN IF EXISTS scopes;
+		`,
+	},
 }
@@ -3,6 +3,7 @@ package migrations
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
@@ -199,6 +200,9 @@ func detectHashType(hash string) string {
 	if len(hash) >= 4 && hash[:2] == "$2" {
 		return "bcrypt"
 	}
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return "argon2id"
+	}
 	if len(hash) == 32 {
 		return "md5"
 	}
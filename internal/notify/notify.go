@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+)
+
+// Email template IDs understood by the notifications service.
+const (
+	TemplatePasswordReset        = "users.password_reset"
+	TemplateEmailVerification    = "users.email_verification"
+	TemplateEmailChangeRequested = "users.email_change_requested"
+	TemplateNewDeviceLogin       = "users.new_device_login"
+)
+
+// Sender delivers notifications to users. It is the subset of the shared
+// interfaces.NotificationSender this service needs, so the notifications
+// service client can be used directly.
+type Sender interface {
+	Send(ctx context.Context, req *models.SendNotificationRequest) (*models.NotificationResult, error)
+}
+
+// LogSender logs notifications instead of delivering them.
+// TODO(TEAM-PLATFORM): Replace with the notifications-service client
+type LogSender struct {
+	// IncludeData logs template data, which may contain secrets such as
+	// reset links. Only enable it in local development.
+	IncludeData bool
+
+	logger *logging.LoggerV2
+}
+
+// NewLogSender creates a sender that writes notifications to the log.
+func NewLogSender(includeData bool) *LogSender {
+	return &LogSender{
+		IncludeData: includeData,
+		logger:      logging.NewLoggerV2("notify"),
+	}
+}
+
+// Send logs the notification and reports it as sent.
+func (s *LogSender) Send(ctx context.Context, req *models.SendNotificationRequest) (*models.NotificationResult, error) {
+	fields := logging.Fields{
+		"type":      string(req.Type),
+		"recipient": req.Recipient,
+		"template":  req.TemplateID,
+	}
+	if s.IncludeData {
+		fields["data"] = req.TemplateData
+	}
+	s.logger.Info("notification sent", fields)
+
+	return &models.NotificationResult{
+		NotificationID: fmt.Sprintf("log-%d", time.Now().UnixNano()),
+		Status:         models.NotificationStatusSent,
+	}, nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ Sender = (*LogSender)(nil)
+)
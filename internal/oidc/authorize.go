@@ -0,0 +1,348 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+)
+
+// authorizeRequest is a validated authorization request.
+type authorizeRequest struct {
+	client        *Client
+	redirectURI   string
+	state         string
+	nonce         string
+	scopes        []string
+	codeChallenge string
+	prompt        map[string]bool
+
+	// query is the raw request query. The provider's forms post back to the
+	// same URL so every step re-validates the original request.
+	query string
+}
+
+// Authorize handles GET /oauth/authorize
+func (p *Provider) Authorize(c *gin.Context) {
+	req, ok := p.parseAuthorizeRequest(c)
+	if !ok {
+		return
+	}
+
+	session := p.browserSession(c)
+	if req.prompt["login"] {
+		session = nil
+	}
+	p.authorize(c, req, session)
+}
+
+// SubmitAuthorize handles POST /oauth/authorize, submitted by the sign-in,
+// second factor and consent pages.
+func (p *Provider) SubmitAuthorize(c *gin.Context) {
+	req, ok := p.parseAuthorizeRequest(c)
+	if !ok {
+		return
+	}
+
+	cookie, err := c.Cookie(csrfCookie)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.PostForm("csrf_token"))) != 1 {
+		p.renderError(c, http.StatusBadRequest, "Your sign-in form expired. Go back to the application and try again.")
+		return
+	}
+
+	ctx := c.Request.Context()
+	switch c.PostForm("action") {
+	case "login":
+		result, err := p.accounts.Login(ctx, &LoginRequest{
+			Email:     c.PostForm("email"),
+			Password:  c.PostForm("password"),
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		p.completeLogin(c, req, result, err)
+
+	case "mfa":
+		result, err := p.accounts.VerifyMFA(ctx, &MFARequest{
+			MFAToken:  c.PostForm("mfa_token"),
+			Code:      c.PostForm("code"),
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+		if err == auth.ErrInvalidMFACode {
+			p.renderMFA(c, req, c.PostForm("mfa_token"), "That code is not valid. Try again.")
+			return
+		}
+		p.completeLogin(c, req, result, err)
+
+	case "consent":
+		session := p.browserSession(c)
+		if session == nil {
+			p.renderLogin(c, req, "")
+			return
+		}
+		if err := p.saveConsent(c, req, session.UserID); err != nil {
+			p.serverError(c, err)
+			return
+		}
+		p.issueCode(c, req, session)
+
+	case "deny":
+		p.redirectError(c, req, "access_denied", "The user denied the request")
+
+	default:
+		p.renderError(c, http.StatusBadRequest, "The request could not be understood.")
+	}
+}
+
+// completeLogin continues an authorization request once the user has signed
+// in, or shows the next sign-in step.
+func (p *Provider) completeLogin(c *gin.Context, req *authorizeRequest, result *LoginResult, err error) {
+	if err != nil {
+		p.renderLogin(c, req, loginErrorMessage(err))
+		return
+	}
+	if result.MFAToken != "" {
+		p.renderMFA(c, req, result.MFAToken, "")
+		return
+	}
+
+	p.setBrowserSession(c, result.Session)
+	p.authorize(c, req, result.Session)
+}
+
+// authorize issues a code for req if the user is signed in and has consented,
+// and otherwise asks them to, unless the client asked for no interaction.
+func (p *Provider) authorize(c *gin.Context, req *authorizeRequest, session *auth.Session) {
+	if session == nil {
+		if req.prompt["none"] {
+			p.redirectError(c, req, "login_required", "The user is not signed in")
+			return
+		}
+		p.renderLogin(c, req, "")
+		return
+	}
+
+	consented, err := p.hasConsent(c, req, session.UserID)
+	if err != nil {
+		p.serverError(c, err)
+		return
+	}
+	if !consented || req.prompt["consent"] {
+		if req.prompt["none"] {
+			p.redirectError(c, req, "consent_required", "The user has not authorized the client")
+			return
+		}
+		p.renderConsent(c, req)
+		return
+	}
+
+	p.issueCode(c, req, session)
+}
+
+// hasConsent reports whether the user has already allowed the client every
+// requested scope. Trusted clients never ask.
+func (p *Provider) hasConsent(c *gin.Context, req *authorizeRequest, userID string) (bool, error) {
+	if req.client.Trusted {
+		return true, nil
+	}
+
+	consent, err := p.clients.GetConsent(c.Request.Context(), userID, req.client.ID)
+	if err == errors.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return consent.Covers(req.scopes), nil
+}
+
+// saveConsent records that the user allowed the requested scopes, in
+// addition to any they allowed before.
+func (p *Provider) saveConsent(c *gin.Context, req *authorizeRequest, userID string) error {
+	ctx := c.Request.Context()
+
+	scopes := append([]string(nil), req.scopes...)
+	previous, err := p.clients.GetConsent(ctx, userID, req.client.ID)
+	if err != nil && err != errors.ErrNotFound {
+		return err
+	}
+	if previous != nil {
+		for _, scope := range previous.Scopes {
+			if !containsScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return p.clients.SaveConsent(ctx, &Consent{
+		UserID:    userID,
+		ClientID:  req.client.ID,
+		Scopes:    scopes,
+		GrantedAt: time.Now().UTC(),
+	})
+}
+
+// issueCode redirects the browser back to the client with a new
+// authorization code.
+func (p *Provider) issueCode(c *gin.Context, req *authorizeRequest, session *auth.Session) {
+	code, hash, err := newCode()
+	if err != nil {
+		p.serverError(c, err)
+		return
+	}
+
+	err = p.codes.Save(c.Request.Context(), hash, &AuthorizationCode{
+		ClientID:      req.client.ID,
+		RedirectURI:   req.redirectURI,
+		SessionID:     session.ID,
+		UserID:        session.UserID,
+		Scopes:        req.scopes,
+		Nonce:         req.nonce,
+		AuthTime:      session.CreatedAt,
+		CodeChallenge: req.codeChallenge,
+		ExpiresAt:     time.Now().Add(p.cfg.CodeTTL),
+	})
+	if err != nil {
+		p.serverError(c, err)
+		return
+	}
+
+	p.logger.Info("authorization code issued", logging.Fields{
+		"client_id":  req.client.ID,
+		"user_id":    session.UserID,
+		"session_id": session.ID,
+	})
+
+	p.redirect(c, req, url.Values{"code": {code}})
+}
+
+// parseAuthorizeRequest validates the query of an authorization request.
+// Until the client and redirect URI are known to be genuine, errors are
+// shown to the user rather than sent to the redirect URI, so the endpoint
+// cannot be used as an open redirector.
+func (p *Provider) parseAuthorizeRequest(c *gin.Context) (*authorizeRequest, bool) {
+	query := c.Request.URL.Query()
+
+	client, err := p.clients.GetClient(c.Request.Context(), query.Get("client_id"))
+	if err == errors.ErrNotFound {
+		p.renderError(c, http.StatusBadRequest, "The application that sent you here is not registered.")
+		return nil, false
+	}
+	if err != nil {
+		p.serverError(c, err)
+		return nil, false
+	}
+
+	redirectURI := query.Get("redirect_uri")
+	if !client.AllowsRedirectURI(redirectURI) {
+		p.renderError(c, http.StatusBadRequest, "The application that sent you here is not configured correctly.")
+		return nil, false
+	}
+
+	req := &authorizeRequest{
+		client:        client,
+		redirectURI:   redirectURI,
+		state:         query.Get("state"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		prompt:        make(map[string]bool),
+		query:         c.Request.URL.RawQuery,
+	}
+
+	if query.Get("response_type") != "code" {
+		p.redirectError(c, req, "unsupported_response_type", "Only the code response type is supported")
+		return nil, false
+	}
+
+	scopes, ok := parseScopes(query.Get("scope"))
+	if !ok || !containsScope(scopes, ScopeOpenID) {
+		p.redirectError(c, req, "invalid_scope", "The openid scope is required and only openid, profile and email are supported")
+		return nil, false
+	}
+	req.scopes = scopes
+
+	// Every client, confidential or not, must use PKCE
+	if query.Get("code_challenge_method") != "S256" || !validPKCEValue(req.codeChallenge) {
+		p.redirectError(c, req, "invalid_request", "PKCE with the S256 method is required")
+		return nil, false
+	}
+
+	for _, prompt := range strings.Fields(query.Get("prompt")) {
+		switch prompt {
+		case "none", "login", "consent":
+			req.prompt[prompt] = true
+		default:
+			p.redirectError(c, req, "invalid_request", "Unsupported prompt value")
+			return nil, false
+		}
+	}
+	if req.prompt["none"] && len(req.prompt) > 1 {
+		p.redirectError(c, req, "invalid_request", "prompt=none cannot be combined with other values")
+		return nil, false
+	}
+
+	return req, true
+}
+
+// redirectError sends an authorization error back to the client.
+func (p *Provider) redirectError(c *gin.Context, req *authorizeRequest, code, description string) {
+	p.redirect(c, req, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+// redirect sends the browser to the request's redirect URI with params, the
+// request's state and the issuer added to its query.
+func (p *Provider) redirect(c *gin.Context, req *authorizeRequest, params url.Values) {
+	target, err := url.Parse(req.redirectURI)
+	if err != nil {
+		p.serverError(c, err)
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.state != "" {
+		query.Set("state", req.state)
+	}
+	query.Set("iss", p.cfg.Issuer)
+	target.RawQuery = query.Encode()
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target.String())
+}
+
+func (p *Provider) serverError(c *gin.Context, err error) {
+	p.logger.Error("authorization request failed", logging.Fields{"error": err.Error()})
+	p.renderError(c, http.StatusInternalServerError, "Something went wrong. Try again later.")
+}
+
+// loginErrorMessage describes a failed sign-in without revealing whether
+// the account exists.
+func loginErrorMessage(err error) string {
+	switch err {
+	case auth.ErrAccountLocked, auth.ErrTooManyAttempts:
+		return "Too many failed attempts. Try again later."
+	case auth.ErrEmailNotVerified:
+		return "Verify your email address before signing in."
+	case auth.ErrMFARequired:
+		return "Your account requires two-step verification. Set it up in your Acme Shop account, then try again."
+	case auth.ErrTooManySessions:
+		return "You are signed in on too many devices. Sign out of one and try again."
+	case auth.ErrInvalidToken, auth.ErrExpiredToken:
+		return "Your sign-in expired. Enter your password again."
+	case errors.ErrInvalidCredentials, errors.ErrUserInactive:
+		return "The email address or password is incorrect."
+	default:
+		return "Sign-in failed. Try again later."
+	}
+}
@@ -0,0 +1,367 @@
+package oidc
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// Client is an application registered to sign users in through the provider.
+type Client struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// SecretHash is empty for public clients such as single-page and mobile
+	// apps, which cannot keep a secret and rely on PKCE alone.
+	SecretHash string `json:"-"`
+
+	// RedirectURIs lists the exact URIs authorization responses may be sent
+	// to. No prefix or wildcard matching is done.
+	RedirectURIs []string `json:"redirect_uris"`
+
+	// Trusted clients are first-party apps whose users are not asked for
+	// consent.
+	Trusted bool `json:"trusted"`
+
+	// Scopes lists the service scopes a machine client may be granted with
+	// the client_credentials grant. Only confidential clients have them.
+	Scopes []string `json:"scopes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Confidential reports whether the client must authenticate with a secret.
+func (c *Client) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// AllowsRedirectURI reports whether uri is one of the client's registered
+// redirect URIs.
+func (c *Client) AllowsRedirectURI(uri string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScopes reports whether every scope in scopes was registered for the
+// client's own use.
+func (c *Client) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		if !containsScope(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidRedirectURI reports whether uri may be registered as a redirect URI.
+// Codes are bearer credentials, so they are only sent over https, except to
+// local development servers, and never to URIs with a fragment.
+func ValidRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return false
+	}
+}
+
+// Consent records the scopes a user has allowed a client.
+type Consent struct {
+	UserID    string
+	ClientID  string
+	Scopes    []string
+	GrantedAt time.Time
+}
+
+// Covers reports whether the consent includes every scope in scopes.
+func (c *Consent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !containsScope(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClientStore persists registered clients and user consents.
+type ClientStore interface {
+	// GetClient returns errors.ErrNotFound if the client does not exist.
+	GetClient(ctx context.Context, id string) (*Client, error)
+	CreateClient(ctx context.Context, client *Client) error
+	ListClients(ctx context.Context) ([]*Client, error)
+
+	// DeleteClient removes a client and its consents. It returns
+	// errors.ErrNotFound if the client does not exist.
+	DeleteClient(ctx context.Context, id string) error
+
+	// GetConsent returns errors.ErrNotFound if the user has not consented
+	// to the client.
+	GetConsent(ctx context.Context, userID, clientID string) (*Consent, error)
+
+	// SaveConsent replaces any earlier consent the user gave the client.
+	SaveConsent(ctx context.Context, consent *Consent) error
+}
+
+// PostgresClientStore stores clients and consents in Postgres.
+type PostgresClientStore struct {
+	db     *sql.DB
+	logger *logging.LoggerV2
+}
+
+// NewPostgresClientStore creates a new Postgres-backed client store.
+func NewPostgresClientStore(db *sql.DB) *PostgresClientStore {
+	return &PostgresClientStore{
+		db:     db,
+		logger: logging.NewLoggerV2("oauth-client-store"),
+	}
+}
+
+const clientColumns = `id, name, secret_hash, redirect_uris, trusted, scopes, created_at`
+
+func (s *PostgresClientStore) GetClient(ctx context.Context, id string) (*Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM oauth_clients WHERE id = $1`
+
+	client, err := scanClient(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (s *PostgresClientStore) CreateClient(ctx context.Context, client *Client) error {
+	query := `INSERT INTO oauth_clients (` + clientColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := s.db.ExecContext(ctx, query,
+		client.ID,
+		client.Name,
+		sql.NullString{String: client.SecretHash, Valid: client.SecretHash != ""},
+		pq.Array(client.RedirectURIs),
+		client.Trusted,
+		pq.Array(client.Scopes),
+		client.CreatedAt,
+	)
+	if err != nil {
+		s.logger.Error("failed to store OAuth client", logging.Fields{
+			"client_id": client.ID,
+			"error":     err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+func (s *PostgresClientStore) ListClients(ctx context.Context) ([]*Client, error) {
+	query := `SELECT ` + clientColumns + ` FROM oauth_clients ORDER BY created_at`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*Client{}
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+func (s *PostgresClientStore) DeleteClient(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresClientStore) GetConsent(ctx context.Context, userID, clientID string) (*Consent, error) {
+	query := `
+		SELECT user_id, client_id, scopes, granted_at
+		FROM oauth_consents
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	var consent Consent
+	err := s.db.QueryRowContext(ctx, query, userID, clientID).Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		pq.Array(&consent.Scopes),
+		&consent.GrantedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (s *PostgresClientStore) SaveConsent(ctx context.Context, consent *Consent) error {
+	query := `
+		INSERT INTO oauth_consents (user_id, client_id, scopes, granted_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = EXCLUDED.scopes, granted_at = EXCLUDED.granted_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		consent.UserID,
+		consent.ClientID,
+		pq.Array(consent.Scopes),
+		consent.GrantedAt,
+	)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanClient(row rowScanner) (*Client, error) {
+	var client Client
+	var secretHash sql.NullString
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&secretHash,
+		pq.Array(&client.RedirectURIs),
+		&client.Trusted,
+		pq.Array(&client.Scopes),
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	client.SecretHash = secretHash.String
+	return &client, nil
+}
+
+// InMemoryClientStore is a client store for tests and single-node development.
+type InMemoryClientStore struct {
+	mu       sync.Mutex
+	clients  map[string]Client
+	consents map[string]Consent
+}
+
+func NewInMemoryClientStore() *InMemoryClientStore {
+	return &InMemoryClientStore{
+		clients:  make(map[string]Client),
+		consents: make(map[string]Consent),
+	}
+}
+
+func (s *InMemoryClientStore) GetClient(ctx context.Context, id string) (*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, ok := s.clients[id]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	return &client, nil
+}
+
+func (s *InMemoryClientStore) CreateClient(ctx context.Context, client *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[client.ID]; ok {
+		return errors.ErrAlreadyExists
+	}
+	stored := *client
+	stored.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	stored.Scopes = append([]string(nil), client.Scopes...)
+	s.clients[client.ID] = stored
+	return nil
+}
+
+func (s *InMemoryClientStore) ListClients(ctx context.Context) ([]*Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]*Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		client := client
+		clients = append(clients, &client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
+
+func (s *InMemoryClientStore) DeleteClient(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[id]; !ok {
+		return errors.ErrNotFound
+	}
+	delete(s.clients, id)
+	for key, consent := range s.consents {
+		if consent.ClientID == id {
+			delete(s.consents, key)
+		}
+	}
+	return nil
+}
+
+func (s *InMemoryClientStore) GetConsent(ctx context.Context, userID, clientID string) (*Consent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	consent, ok := s.consents[userID+"/"+clientID]
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	return &consent, nil
+}
+
+func (s *InMemoryClientStore) SaveConsent(ctx context.Context, consent *Consent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *consent
+	stored.Scopes = append([]string(nil), consent.Scopes...)
+	s.consents[consent.UserID+"/"+consent.ClientID] = stored
+	return nil
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ ClientStore = (*PostgresClientStore)(nil)
+	_ ClientStore = (*InMemoryClientStore)(nil)
+)
@@ -0,0 +1,151 @@
+package oidc
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+)
+
+// Introspection is a token introspection response (RFC 7662). Inactive
+// tokens only report Active.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+
+	// Role and AuthMethods describe the user of a user token.
+	Role        string   `json:"role,omitempty"`
+	AuthMethods []string `json:"amr,omitempty"`
+
+	// SessionID and SessionStatus are set for tokens bound to a session.
+	// A token is only active while its session is.
+	SessionID     string `json:"sid,omitempty"`
+	SessionStatus string `json:"session_status,omitempty"`
+}
+
+// Introspect handles POST /oauth/introspect
+//
+// Only confidential clients may introspect. Clients granted the
+// tokens:introspect scope, such as the API gateway, may introspect any
+// access token; other clients only tokens issued to them. Refresh tokens
+// are always reported inactive.
+func (e *TokenEndpoint) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := e.authenticateClient(c)
+	if !ok {
+		return
+	}
+	if !client.Confidential() {
+		e.invalidClient(c, false)
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		e.tokenError(c, http.StatusBadRequest, "invalid_request", "The token parameter is required")
+		return
+	}
+
+	claims, err := e.tokens.ValidateAccessToken(c.Request.Context(), token)
+	if err != nil && !inactiveTokenError(err) {
+		e.tokenServerError(c, err)
+		return
+	}
+	if err != nil || !mayIntrospect(client, claims) {
+		c.JSON(http.StatusOK, Introspection{Active: false})
+		return
+	}
+
+	e.logger.Debug("token introspected", logging.Fields{
+		"client_id": client.ID,
+		"token_id":  claims.ID,
+	})
+	c.JSON(http.StatusOK, introspection(claims))
+}
+
+// Revoke handles POST /oauth/revoke
+//
+// Clients revoke their own access and refresh tokens (RFC 7009). The
+// response is the same whether or not the token was valid or issued to the
+// client, so the endpoint reveals nothing about other tokens.
+func (e *TokenEndpoint) Revoke(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := e.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		e.tokenError(c, http.StatusBadRequest, "invalid_request", "The token parameter is required")
+		return
+	}
+
+	// token_type_hint is only an optimisation, so both kinds are tried
+	if err := e.tokens.RevokeToken(c.Request.Context(), client.ID, token); err != nil {
+		e.tokenServerError(c, err)
+		return
+	}
+
+	e.logger.Info("token revocation requested", logging.Fields{"client_id": client.ID})
+	c.Status(http.StatusOK)
+}
+
+// mayIntrospect reports whether client may see the claims of a token.
+func mayIntrospect(client *Client, claims *auth.JWTClaims) bool {
+	return containsScope(client.Scopes, string(auth.PermTokensIntrospect)) || claims.ClientID == client.ID
+}
+
+func introspection(claims *auth.JWTClaims) *Introspection {
+	resp := &Introspection{
+		Active:      true,
+		Scope:       claims.Scope,
+		ClientID:    claims.ClientID,
+		TokenType:   "Bearer",
+		Sub:         claims.Subject,
+		Iss:         claims.Issuer,
+		Jti:         claims.ID,
+		Role:        string(claims.Role),
+		AuthMethods: claims.AuthMethods,
+		SessionID:   claims.SessionID,
+	}
+	if claims.ExpiresAt != nil {
+		resp.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		resp.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		resp.Nbf = claims.NotBefore.Unix()
+	}
+	if claims.SessionID != "" {
+		resp.SessionStatus = "active"
+	}
+	return resp
+}
+
+// inactiveTokenError reports whether err means the token is not active, as
+// opposed to a failure to check it.
+func inactiveTokenError(err error) bool {
+	switch err {
+	case auth.ErrInvalidToken, auth.ErrExpiredToken, auth.ErrInvalidClaims, auth.ErrTokenNotYetValid,
+		auth.ErrTokenRevoked, auth.ErrSessionNotFound, auth.ErrSessionExpired, auth.ErrSessionInvalid,
+		auth.ErrSessionRevoked:
+		return true
+	default:
+		return false
+	}
+}
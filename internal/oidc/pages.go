@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// scopeDescriptions are shown on the consent page.
+var scopeDescriptions = map[string]string{
+	ScopeOpenID:  "Know who you are on Acme Shop",
+	ScopeProfile: "See your name",
+	ScopeEmail:   "See your email address",
+}
+
+// pages holds the sign-in, second factor, consent and error pages. Every
+// form posts back to the authorization URL it was rendered for.
+var pages = template.Must(template.New("").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in with Acme Shop</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 24rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+label, input, button { display: block; width: 100%; box-sizing: border-box; margin-top: .5rem; }
+input, button { padding: .5rem; font-size: 1rem; }
+button { margin-top: 1rem; cursor: pointer; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "login"}}{{template "header"}}
+<h1>Sign in to continue to {{.Client}}</h1>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post" action="/oauth/authorize?{{.Query}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="hidden" name="action" value="login">
+<label for="email">Email</label>
+<input id="email" name="email" type="email" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required>
+<button type="submit">Sign in</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "mfa"}}{{template "header"}}
+<h1>Two-step verification</h1>
+<p>Enter the code from your authenticator app, or one of your recovery codes.</p>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post" action="/oauth/authorize?{{.Query}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="hidden" name="action" value="mfa">
+<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label for="code">Code</label>
+<input id="code" name="code" autocomplete="one-time-code" required autofocus>
+<button type="submit">Verify</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "consent"}}{{template "header"}}
+<h1>{{.Client}} wants to</h1>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+<form method="post" action="/oauth/authorize?{{.Query}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<button type="submit" name="action" value="consent">Allow</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "error"}}{{template "header"}}
+<h1>Sign-in failed</h1>
+<p class="error">{{.Error}}</p>
+{{template "footer"}}{{end}}
+`))
+
+// pageData is the data every page template is rendered with.
+type pageData struct {
+	Client    string
+	Query     template.URL
+	CSRFToken string
+	Error     string
+	MFAToken  string
+	Scopes    []string
+}
+
+func (p *Provider) renderLogin(c *gin.Context, req *authorizeRequest, message string) {
+	p.render(c, http.StatusOK, "login", p.pageData(c, req, message))
+}
+
+func (p *Provider) renderMFA(c *gin.Context, req *authorizeRequest, mfaToken, message string) {
+	data := p.pageData(c, req, message)
+	data.MFAToken = mfaToken
+	p.render(c, http.StatusOK, "mfa", data)
+}
+
+func (p *Provider) renderConsent(c *gin.Context, req *authorizeRequest) {
+	data := p.pageData(c, req, "")
+	for _, scope := range req.scopes {
+		data.Scopes = append(data.Scopes, scopeDescriptions[scope])
+	}
+	p.render(c, http.StatusOK, "consent", data)
+}
+
+func (p *Provider) renderError(c *gin.Context, status int, message string) {
+	p.render(c, status, "error", &pageData{Error: message})
+}
+
+func (p *Provider) pageData(c *gin.Context, req *authorizeRequest, message string) *pageData {
+	return &pageData{
+		Client: req.client.Name,
+		// The query was parsed and validated, so it is safe to echo as a URL
+		Query:     template.URL(req.query),
+		CSRFToken: p.csrfToken(c),
+		Error:     message,
+	}
+}
+
+// render writes a page that may not be framed or cached, since it handles
+// credentials.
+func (p *Provider) render(c *gin.Context, status int, name string, data *pageData) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	c.Status(status)
+
+	if err := pages.ExecuteTemplate(c.Writer, name, data); err != nil {
+		p.logger.Error("failed to render page", logging.Fields{
+			"page":  name,
+			"error": err.Error(),
+		})
+	}
+}
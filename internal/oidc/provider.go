@@ -0,0 +1,342 @@
+// Package oidc implements an OpenID Connect provider so other applications
+// can sign users in with their Acme Shop account using the authorization
+// code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+// Scopes the provider understands. Every request must include openid.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+const (
+	// sessionCookie holds the ID of the browser's Acme Shop session, so a
+	// user signed in once is not asked again by the next client.
+	sessionCookie = "acme_sso"
+
+	// csrfCookie holds the double-submit token the provider's forms echo.
+	csrfCookie = "acme_oauth_csrf"
+
+	cookiePath = "/oauth"
+)
+
+var (
+	// ErrInvalidGrant is returned when an authorization code or refresh
+	// token is unknown, expired, already used or issued to another client.
+	ErrInvalidGrant = errors.New("invalid authorization grant")
+
+	// ErrSymmetricSigningKey is returned when the active JWT key is an HMAC
+	// secret, which clients cannot verify ID tokens with.
+	ErrSymmetricSigningKey = errors.New("OIDC requires an asymmetric JWT signing key")
+
+	// ErrInvalidIssuer is returned when the configured issuer is not an
+	// absolute URL without query or fragment.
+	ErrInvalidIssuer = errors.New("OIDC issuer must be an absolute URL")
+)
+
+// AccessTokens validates and revokes the tokens the users service issued.
+type AccessTokens interface {
+	// ValidateAccessToken validates an access token and returns its claims.
+	ValidateAccessToken(ctx context.Context, token string) (*auth.JWTClaims, error)
+
+	// RevokeToken revokes an access or refresh token issued to clientID.
+	// Tokens that are invalid, expired or were issued to anyone else are
+	// ignored.
+	RevokeToken(ctx context.Context, clientID, token string) error
+}
+
+// Accounts is the provider's view of the users service: it authenticates
+// users and issues their tokens.
+type Accounts interface {
+	AccessTokens
+
+	// Login checks a user's password and either starts a browser session or,
+	// when a second factor is required, returns an MFA token.
+	Login(ctx context.Context, req *LoginRequest) (*LoginResult, error)
+
+	// VerifyMFA completes a login that is waiting for its second factor.
+	VerifyMFA(ctx context.Context, req *MFARequest) (*LoginResult, error)
+
+	// Session returns an active browser session that may be used to sign in
+	// to clients.
+	Session(ctx context.Context, sessionID string) (*auth.Session, error)
+
+	// User returns a user's profile and whether their email is verified.
+	User(ctx context.Context, userID string) (*models.User, bool, error)
+
+	// IssueTokens creates a session for a client acting for the user of the
+	// browser session, limited to scopes.
+	IssueTokens(ctx context.Context, browser *auth.Session, clientID string, scopes []string) (*Tokens, error)
+
+	// Refresh redeems a refresh token issued to clientID.
+	Refresh(ctx context.Context, clientID, refreshToken string) (*Tokens, error)
+}
+
+// LoginRequest is a password login from the provider's sign-in page.
+type LoginRequest struct {
+	Email     string
+	Password  string
+	IPAddress string
+	UserAgent string
+}
+
+// MFARequest is the second step of a login from the provider's sign-in page.
+type MFARequest struct {
+	MFAToken  string
+	Code      string
+	IPAddress string
+	UserAgent string
+}
+
+// LoginResult is either a new browser session or a pending MFA challenge.
+type LoginResult struct {
+	Session  *auth.Session
+	MFAToken string
+}
+
+// Tokens are the access and refresh tokens of a client session.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	Session      *auth.Session
+}
+
+// Provider serves the OpenID Connect discovery, authorization and userinfo
+// endpoints, and redeems authorization codes and refresh tokens at the token
+// endpoint it extends.
+type Provider struct {
+	*TokenEndpoint
+
+	accounts Accounts
+	codes    CodeStore
+	cfg      config.OIDCConfig
+	logger   *logging.LoggerV2
+}
+
+// NewProvider creates a new OpenID Connect provider and adds the
+// authorization_code and refresh_token grants to tokens. ID tokens are
+// signed with the token endpoint's active key, which must be asymmetric.
+func NewProvider(accounts Accounts, tokens *TokenEndpoint, codes CodeStore, cfg config.OIDCConfig) (*Provider, error) {
+	issuer, err := url.Parse(cfg.Issuer)
+	if err != nil || !issuer.IsAbs() || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return nil, ErrInvalidIssuer
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	if tokens.jwt.SigningAlgorithm() == jwt.SigningMethodHS256.Alg() {
+		return nil, ErrSymmetricSigningKey
+	}
+
+	p := &Provider{
+		TokenEndpoint: tokens,
+		accounts:      accounts,
+		codes:         codes,
+		cfg:           cfg,
+		logger:        logging.NewLoggerV2("oidc-provider"),
+	}
+	tokens.grants["authorization_code"] = p.exchangeCode
+	tokens.grants["refresh_token"] = p.refresh
+	return p, nil
+}
+
+// RegisterRoutes adds the provider's endpoints to r. The token,
+// introspection and revocation endpoints are registered separately by the
+// TokenEndpoint. loginLimits are applied to the sign-in form.
+func (p *Provider) RegisterRoutes(r gin.IRoutes, loginLimits ...gin.HandlerFunc) {
+	r.GET("/.well-known/openid-configuration", p.Discovery)
+	r.GET("/oauth/authorize", p.Authorize)
+	r.POST("/oauth/authorize", append(loginLimits, p.SubmitAuthorize)...)
+	r.GET("/oauth/userinfo", p.UserInfo)
+	r.POST("/oauth/userinfo", p.UserInfo)
+}
+
+// DiscoveryDocument is the OpenID Provider Metadata document.
+type DiscoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	ResponseModesSupported            []string `json:"response_modes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	PromptValuesSupported             []string `json:"prompt_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+
+	AuthorizationResponseIssParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+}
+
+// Discovery handles GET /.well-known/openid-configuration
+func (p *Provider) Discovery(c *gin.Context) {
+	issuer := p.cfg.Issuer
+	c.JSON(http.StatusOK, DiscoveryDocument{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		RevocationEndpoint:                issuer + "/oauth/revoke",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		ResponseModesSupported:            []string{"query"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{p.jwt.SigningAlgorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		PromptValuesSupported:             []string{"none", "login", "consent"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "sid",
+			"email", "email_verified", "name", "given_name", "family_name",
+		},
+		AuthorizationResponseIssParameterSupported: true,
+	})
+}
+
+// idToken signs an ID token for the user of a client session. It expires
+// with the access token issued alongside it. authTime is when the user
+// signed in, if known.
+func (p *Provider) idToken(user *models.User, emailVerified bool, tokens *Tokens, nonce string, authTime time.Time) (string, error) {
+	now := time.Now()
+	session := tokens.Session
+	claims := &auth.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.cfg.Issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{session.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokens.ExpiresIn)),
+		},
+		Nonce:       nonce,
+		AuthMethods: session.AuthMethods,
+		SessionID:   session.ID,
+	}
+	if !authTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(authTime)
+	}
+	addUserClaims(claims, user, emailVerified, session.Scopes)
+	return p.jwt.GenerateIDToken(claims)
+}
+
+// addUserClaims copies the profile and email claims the scopes allow.
+func addUserClaims(claims *auth.IDTokenClaims, user *models.User, emailVerified bool, scopes []string) {
+	if containsScope(scopes, ScopeProfile) {
+		claims.Name = strings.TrimSpace(user.FullName())
+		claims.GivenName = user.FirstName
+		claims.FamilyName = user.LastName
+	}
+	if containsScope(scopes, ScopeEmail) {
+		claims.Email = user.Email
+		claims.EmailVerified = &emailVerified
+	}
+}
+
+// browserSession returns the session named by the SSO cookie, or nil if
+// there is none or it can no longer be used.
+func (p *Provider) browserSession(c *gin.Context) *auth.Session {
+	sessionID, err := c.Cookie(sessionCookie)
+	if err != nil || sessionID == "" {
+		return nil
+	}
+
+	session, err := p.accounts.Session(c.Request.Context(), sessionID)
+	if err != nil || session.ClientID != "" {
+		return nil
+	}
+	return session
+}
+
+// setBrowserSession remembers session in the SSO cookie until it expires.
+func (p *Provider) setBrowserSession(c *gin.Context, session *auth.Session) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.ID,
+		Path:     cookiePath,
+		Expires:  session.MaxExpiresAt,
+		HttpOnly: true,
+		Secure:   p.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// csrfToken returns the browser's CSRF token, issuing one if needed.
+func (p *Provider) csrfToken(c *gin.Context) string {
+	if token, err := c.Cookie(csrfCookie); err == nil && token != "" {
+		return token
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		// Forms without a token are rejected, which is safe if unhelpful
+		p.logger.Error("failed to generate CSRF token", logging.Fields{"error": err.Error()})
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     cookiePath,
+		HttpOnly: true,
+		Secure:   p.secureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+func (p *Provider) secureCookies() bool {
+	return strings.HasPrefix(p.cfg.Issuer, "https://")
+}
+
+// parseScopes splits a space-separated scope parameter, dropping duplicates.
+// It reports false if any scope is unsupported.
+func parseScopes(scope string) ([]string, bool) {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !containsScope(supportedScopes, s) {
+			return nil, false
+		}
+		if !containsScope(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, true
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
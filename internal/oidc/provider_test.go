@@ -0,0 +1,881 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const (
+	testIssuer      = "http://acme.test"
+	testRedirectURI = "https://app.example.com/callback"
+	testPassword    = "correct horse battery staple"
+)
+
+// testAccounts implements Accounts with the real session, refresh token and
+// JWT services over in-memory stores, and a fixed set of users.
+type testAccounts struct {
+	users    map[string]*models.User
+	sessions *auth.SessionService
+	refresh  *auth.RefreshTokenService
+	denylist *auth.InMemoryTokenDenylist
+	jwt      *auth.JWTService
+}
+
+func (a *testAccounts) Login(ctx context.Context, req *LoginRequest) (*LoginResult, error) {
+	for _, user := range a.users {
+		if user.Email == req.Email && req.Password == testPassword {
+			session, err := a.sessions.Create(ctx, user.ID, user.Email, string(user.Role),
+				req.IPAddress, req.UserAgent, "", []string{auth.AuthMethodPassword})
+			if err != nil {
+				return nil, err
+			}
+			return &LoginResult{Session: session}, nil
+		}
+	}
+	return nil, errors.ErrInvalidCredentials
+}
+
+func (a *testAccounts) VerifyMFA(ctx context.Context, req *MFARequest) (*LoginResult, error) {
+	return nil, auth.ErrInvalidToken
+}
+
+func (a *testAccounts) Session(ctx context.Context, sessionID string) (*auth.Session, error) {
+	return a.sessions.Get(ctx, sessionID)
+}
+
+func (a *testAccounts) User(ctx context.Context, userID string) (*models.User, bool, error) {
+	user, ok := a.users[userID]
+	if !ok {
+		return nil, false, errors.ErrNotFound
+	}
+	return user, true, nil
+}
+
+func (a *testAccounts) IssueTokens(ctx context.Context, browser *auth.Session, clientID string, scopes []string) (*Tokens, error) {
+	session, err := a.sessions.CreateForClient(ctx, browser, clientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+	return a.tokens(ctx, session)
+}
+
+func (a *testAccounts) Refresh(ctx context.Context, clientID, refreshToken string) (*Tokens, error) {
+	record, err := a.refresh.Redeem(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	session, err := a.sessions.Get(ctx, record.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.ClientID != clientID {
+		return nil, auth.ErrInvalidToken
+	}
+	return a.tokens(ctx, session)
+}
+
+func (a *testAccounts) tokens(ctx context.Context, session *auth.Session) (*Tokens, error) {
+	token, err := a.jwt.GenerateSessionToken(a.users[session.UserID], session)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, _, err := a.refresh.Issue(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: token, RefreshToken: refreshToken, ExpiresIn: time.Hour, Session: session}, nil
+}
+
+func (a *testAccounts) ValidateAccessToken(ctx context.Context, token string) (*auth.JWTClaims, error) {
+	claims, err := a.jwt.ValidateToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if revoked, _ := a.denylist.Contains(ctx, claims.ID); revoked {
+		return nil, auth.ErrTokenRevoked
+	}
+	if claims.SessionID == "" {
+		return claims, nil
+	}
+	if _, err := a.sessions.Get(ctx, claims.SessionID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (a *testAccounts) RevokeToken(ctx context.Context, clientID, token string) error {
+	if claims, err := a.jwt.ValidateToken(token); err == nil {
+		if claims.ClientID != clientID {
+			return nil
+		}
+		return a.denylist.Add(ctx, claims.ID, claims.ExpiresAt.Time)
+	}
+
+	record, err := a.refresh.Lookup(ctx, token)
+	if err != nil {
+		return nil
+	}
+	session, err := a.sessions.Get(ctx, record.SessionID)
+	if err != nil || session.ClientID != clientID {
+		return nil
+	}
+	return a.sessions.Revoke(ctx, session.ID)
+}
+
+type testProvider struct {
+	*Provider
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	// client is a confidential client and publicClient a public one. Both
+	// redirect to testRedirectURI.
+	client       *Client
+	secret       string
+	publicClient *Client
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	signingKey, err := auth.NewSigningKey("test", key)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	ring, err := auth.NewKeyRing(signingKey)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	jwtService := auth.NewJWTServiceWithKeys(ring, time.Hour, "acme-shop-users-service")
+
+	accounts := &testAccounts{
+		users: map[string]*models.User{
+			"user-1": {ID: "user-1", Email: "jane@example.com", FirstName: "Jane", LastName: "Doe", Role: models.RoleCustomer, Active: true},
+		},
+		sessions: auth.NewSessionService(auth.NewInMemorySessionStore(), config.SessionConfig{
+			Limits: config.SessionLimits{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour},
+		}),
+		refresh:  auth.NewRefreshTokenService(auth.NewInMemoryRefreshTokenStore(), 24*time.Hour),
+		denylist: auth.NewInMemoryTokenDenylist(),
+		jwt:      jwtService,
+	}
+
+	clients := NewInMemoryClientStore()
+	secret, hash, err := auth.GenerateClientSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	client := &Client{ID: "client-app", Name: "Example App", SecretHash: hash, RedirectURIs: []string{testRedirectURI}}
+	publicClient := &Client{ID: "client-spa", Name: "Example SPA", RedirectURIs: []string{testRedirectURI}}
+	clients.CreateClient(context.Background(), client)
+	clients.CreateClient(context.Background(), publicClient)
+
+	provider, err := NewProvider(accounts, NewTokenEndpoint(accounts, clients, jwtService), NewInMemoryCodeStore(), config.OIDCConfig{
+		Issuer:  testIssuer,
+		CodeTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	router := gin.New()
+	provider.RegisterRoutes(router)
+	provider.TokenEndpoint.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testProvider{
+		Provider:     provider,
+		server:       server,
+		key:          key,
+		client:       client,
+		secret:       secret,
+		publicClient: publicClient,
+	}
+}
+
+// browser returns an HTTP client that keeps cookies and stops at redirects,
+// like a browser that has not yet followed one.
+func (p *testProvider) browser(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// authorizeQuery builds an authorization request for clientID with a fresh
+// PKCE verifier, returning the query and the verifier.
+func authorizeQuery(clientID string, extra url.Values) (string, string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	verifier := base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"openid profile email"},
+		"state":                 {"state-123"},
+		"nonce":                 {"nonce-456"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+	for key, values := range extra {
+		query[key] = values
+	}
+	return query.Encode(), verifier
+}
+
+var csrfPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// page reads an HTML page from the provider and returns its body and CSRF
+// token.
+func page(t *testing.T, resp *http.Response) (string, string) {
+	t.Helper()
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+
+	match := csrfPattern.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected page to contain a CSRF token, got %s", body)
+	}
+	return string(body), string(match[1])
+}
+
+// callback returns the query of a redirect back to the client.
+func callback(t *testing.T, resp *http.Response) url.Values {
+	t.Helper()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect to the client, got %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testRedirectURI {
+		t.Fatalf("expected redirect to %s, got %s", testRedirectURI, got)
+	}
+	query := location.Query()
+	if query.Get("iss") != testIssuer {
+		t.Fatalf("expected iss %s, got %q", testIssuer, query.Get("iss"))
+	}
+	return query
+}
+
+func get(t *testing.T, browser *http.Client, target string) *http.Response {
+	t.Helper()
+	resp, err := browser.Get(target)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return resp
+}
+
+func post(t *testing.T, browser *http.Client, target string, form url.Values) *http.Response {
+	t.Helper()
+	resp, err := browser.PostForm(target, form)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return resp
+}
+
+// signIn opens an authorization request in browser and submits the sign-in
+// form. It returns the page or redirect that follows.
+func (p *testProvider) signIn(t *testing.T, browser *http.Client, query string) *http.Response {
+	t.Helper()
+	authorizeURL := p.server.URL + "/oauth/authorize?" + query
+
+	body, csrf := page(t, get(t, browser, authorizeURL))
+	if !strings.Contains(body, `value="login"`) {
+		t.Fatalf("expected sign-in page, got %s", body)
+	}
+
+	return post(t, browser, authorizeURL, url.Values{
+		"csrf_token": {csrf},
+		"action":     {"login"},
+		"email":      {"jane@example.com"},
+		"password":   {testPassword},
+	})
+}
+
+// token posts a token request, authenticating as the confidential client
+// with HTTP Basic if basic is set.
+func (p *testProvider) token(t *testing.T, form url.Values, basic bool) (int, map[string]interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, p.server.URL+"/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basic {
+		req.SetBasicAuth(p.client.ID, p.secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected token response not to be cached, got %q", resp.Header.Get("Cache-Control"))
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("expected JSON response, got %v", err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestDiscovery(t *testing.T) {
+	p := newTestProvider(t)
+
+	resp, err := http.Get(p.server.URL + "/.well-known/openid-configuration")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	var doc DiscoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if doc.Issuer != testIssuer || doc.TokenEndpoint != testIssuer+"/oauth/token" {
+		t.Fatalf("expected endpoints under %s, got %+v", testIssuer, doc)
+	}
+	if len(doc.IDTokenSigningAlgValuesSupported) != 1 || doc.IDTokenSigningAlgValuesSupported[0] != "ES256" {
+		t.Fatalf("expected ES256 ID tokens, got %v", doc.IDTokenSigningAlgValuesSupported)
+	}
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	p := newTestProvider(t)
+	browser := p.browser(t)
+	query, verifier := authorizeQuery(p.client.ID, nil)
+
+	// Sign in, then allow the client on the consent page
+	body, csrf := page(t, p.signIn(t, browser, query))
+	if !strings.Contains(body, "Example App wants to") {
+		t.Fatalf("expected consent page, got %s", body)
+	}
+	params := callback(t, post(t, browser, p.server.URL+"/oauth/authorize?"+query, url.Values{
+		"csrf_token": {csrf},
+		"action":     {"consent"},
+	}))
+	if params.Get("state") != "state-123" || params.Get("code") == "" {
+		t.Fatalf("expected code and state, got %v", params)
+	}
+	code := params.Get("code")
+
+	status, tokens := p.token(t, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}, true)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, tokens)
+	}
+	if tokens["scope"] != "openid profile email" || tokens["token_type"] != "Bearer" {
+		t.Fatalf("expected bearer token for the requested scopes, got %v", tokens)
+	}
+
+	var idClaims auth.IDTokenClaims
+	_, err := jwt.ParseWithClaims(tokens["id_token"].(string), &idClaims,
+		func(*jwt.Token) (interface{}, error) { return &p.key.PublicKey, nil },
+		jwt.WithIssuer(testIssuer), jwt.WithAudience(p.client.ID), jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		t.Fatalf("expected valid ID token, got %v", err)
+	}
+	if idClaims.Subject != "user-1" || idClaims.Nonce != "nonce-456" || idClaims.Email != "jane@example.com" || idClaims.Name != "Jane Doe" {
+		t.Fatalf("expected ID token for user-1 with nonce and profile, got %+v", idClaims)
+	}
+	if idClaims.AuthTime == nil || idClaims.EmailVerified == nil || !*idClaims.EmailVerified {
+		t.Fatalf("expected auth_time and email_verified, got %+v", idClaims)
+	}
+
+	// Codes are single use
+	status, body2 := p.token(t, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}, true)
+	if status != http.StatusBadRequest || body2["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a reused code, got %d %v", status, body2)
+	}
+
+	// The access token reads the user's claims
+	req, _ := http.NewRequest(http.MethodGet, p.server.URL+"/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens["access_token"].(string))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var info map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || info["sub"] != "user-1" || info["email"] != "jane@example.com" || info["given_name"] != "Jane" {
+		t.Fatalf("expected userinfo for user-1, got %d %v", resp.StatusCode, info)
+	}
+
+	// The refresh token rotates, and only for the client it was issued to
+	refreshToken := tokens["refresh_token"].(string)
+	status, body2 = p.token(t, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.publicClient.ID},
+	}, false)
+	if status != http.StatusBadRequest || body2["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for another client's refresh token, got %d %v", status, body2)
+	}
+
+	_, tokens = p.token(t, url.Values{"grant_type": {"authorization_code"}}, false)
+	if tokens["error"] != "invalid_client" {
+		t.Fatalf("expected invalid_client without client authentication, got %v", tokens)
+	}
+
+	// Signed in and consented: the next request needs no interaction
+	query, verifier = authorizeQuery(p.client.ID, url.Values{"prompt": {"none"}})
+	params = callback(t, get(t, browser, p.server.URL+"/oauth/authorize?"+query))
+	status, tokens = p.token(t, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {params.Get("code")},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier},
+	}, true)
+	if status != http.StatusOK {
+		t.Fatalf("expected silent sign-in to succeed, got %d %v", status, tokens)
+	}
+	status, tokens = p.token(t, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tokens["refresh_token"].(string)},
+	}, true)
+	if status != http.StatusOK || tokens["id_token"] == nil {
+		t.Fatalf("expected refreshed tokens, got %d %v", status, tokens)
+	}
+}
+
+func TestPublicClientFlow(t *testing.T) {
+	p := newTestProvider(t)
+	browser := p.browser(t)
+	query, verifier := authorizeQuery(p.publicClient.ID, nil)
+
+	_, csrf := page(t, p.signIn(t, browser, query))
+	params := callback(t, post(t, browser, p.server.URL+"/oauth/authorize?"+query, url.Values{
+		"csrf_token": {csrf},
+		"action":     {"consent"},
+	}))
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {p.publicClient.ID},
+		"code":          {params.Get("code")},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {verifier + "x"},
+	}
+	status, body := p.token(t, form, false)
+	if status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Fatalf("expected invalid_grant for a wrong code verifier, got %d %v", status, body)
+	}
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	p := newTestProvider(t)
+
+	t.Run("unknown client", func(t *testing.T) {
+		query, _ := authorizeQuery("client-unknown", nil)
+		resp, err := p.browser(t).Get(p.server.URL + "/oauth/authorize?" + query)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Location") != "" {
+			t.Fatalf("expected an error page, got %d redirect to %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+	})
+
+	t.Run("unregistered redirect URI", func(t *testing.T) {
+		query, _ := authorizeQuery(p.client.ID, url.Values{"redirect_uri": {"https://evil.example.com/callback"}})
+		resp, err := p.browser(t).Get(p.server.URL + "/oauth/authorize?" + query)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest || resp.Header.Get("Location") != "" {
+			t.Fatalf("expected an error page, got %d redirect to %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+	})
+
+	tests := []struct {
+		name  string
+		extra url.Values
+		want  string
+	}{
+		{"no PKCE", url.Values{"code_challenge_method": {"plain"}}, "invalid_request"},
+		{"no openid scope", url.Values{"scope": {"profile"}}, "invalid_scope"},
+		{"unsupported scope", url.Values{"scope": {"openid admin"}}, "invalid_scope"},
+		{"implicit flow", url.Values{"response_type": {"token"}}, "unsupported_response_type"},
+		{"not signed in", url.Values{"prompt": {"none"}}, "login_required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := authorizeQuery(p.client.ID, tt.extra)
+			params := callback(t, get(t, p.browser(t), p.server.URL+"/oauth/authorize?"+query))
+			if params.Get("error") != tt.want || params.Get("state") != "state-123" {
+				t.Fatalf("expected %s with state, got %v", tt.want, params)
+			}
+		})
+	}
+
+	t.Run("missing CSRF token", func(t *testing.T) {
+		query, _ := authorizeQuery(p.client.ID, nil)
+		resp, err := p.browser(t).PostForm(p.server.URL+"/oauth/authorize?"+query, url.Values{
+			"action":   {"login"},
+			"email":    {"jane@example.com"},
+			"password": {testPassword},
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("consent denied", func(t *testing.T) {
+		browser := p.browser(t)
+		query, _ := authorizeQuery(p.client.ID, nil)
+		_, csrf := page(t, p.signIn(t, browser, query))
+		params := callback(t, post(t, browser, p.server.URL+"/oauth/authorize?"+query, url.Values{
+			"csrf_token": {csrf},
+			"action":     {"deny"},
+		}))
+		if params.Get("error") != "access_denied" {
+			t.Fatalf("expected access_denied, got %v", params)
+		}
+	})
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	p := newTestProvider(t)
+
+	secret, hash, err := auth.GenerateClientSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	machine := &Client{
+		ID:         "client-orders",
+		Name:       "Orders Service",
+		SecretHash: hash,
+		Scopes:     []string{string(auth.PermUsersReadAny), string(auth.PermUsersList)},
+	}
+	p.clients.CreateClient(context.Background(), machine)
+
+	grant := func(extra url.Values) url.Values {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {machine.ID},
+			"client_secret": {secret},
+		}
+		for key, values := range extra {
+			form[key] = values
+		}
+		return form
+	}
+
+	t.Run("registered scopes by default", func(t *testing.T) {
+		status, body := p.token(t, grant(nil), false)
+		if status != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %v", status, body)
+		}
+		if body["scope"] != "users:read:any users:list" {
+			t.Fatalf("expected registered scopes, got %v", body["scope"])
+		}
+		if _, ok := body["refresh_token"]; ok {
+			t.Fatal("expected no refresh token")
+		}
+		if _, ok := body["id_token"]; ok {
+			t.Fatal("expected no ID token")
+		}
+
+		claims, err := p.jwt.ValidateToken(body["access_token"].(string))
+		if err != nil {
+			t.Fatalf("expected valid access token, got %v", err)
+		}
+		if !claims.IsClientToken() || claims.ClientID != machine.ID || claims.Subject != machine.ID {
+			t.Fatalf("expected client token for %s, got %+v", machine.ID, claims)
+		}
+		if claims.UserID != "" || claims.SessionID != "" {
+			t.Fatalf("expected no user or session, got %+v", claims)
+		}
+	})
+
+	t.Run("narrowed scope", func(t *testing.T) {
+		status, body := p.token(t, grant(url.Values{"scope": {"users:read:any users:read:any"}}), false)
+		if status != http.StatusOK || body["scope"] != "users:read:any" {
+			t.Fatalf("expected users:read:any, got %d: %v", status, body)
+		}
+	})
+
+	t.Run("unregistered scope", func(t *testing.T) {
+		status, body := p.token(t, grant(url.Values{"scope": {"users:read:any users:delete:any"}}), false)
+		if status != http.StatusBadRequest || body["error"] != "invalid_scope" {
+			t.Fatalf("expected invalid_scope, got %d: %v", status, body)
+		}
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		status, body := p.token(t, grant(url.Values{"client_secret": {"wrong"}}), false)
+		if status != http.StatusUnauthorized || body["error"] != "invalid_client" {
+			t.Fatalf("expected invalid_client, got %d: %v", status, body)
+		}
+	})
+
+	t.Run("client without scopes", func(t *testing.T) {
+		status, body := p.token(t, url.Values{"grant_type": {"client_credentials"}}, true)
+		if status != http.StatusBadRequest || body["error"] != "unauthorized_client" {
+			t.Fatalf("expected unauthorized_client, got %d: %v", status, body)
+		}
+	})
+}
+
+// issueTokens signs the test user in to clientID directly, skipping the
+// authorization flow.
+func (p *testProvider) issueTokens(t *testing.T, clientID string) *Tokens {
+	t.Helper()
+	ctx := context.Background()
+	accounts := p.accounts.(*testAccounts)
+
+	browser, err := accounts.sessions.Create(ctx, "user-1", "jane@example.com", string(models.RoleCustomer),
+		"127.0.0.1", "test", "", []string{auth.AuthMethodPassword})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tokens, err := accounts.IssueTokens(ctx, browser, clientID, []string{ScopeOpenID})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	return tokens
+}
+
+// oauthPost posts a form to a provider endpoint, authenticating as clientID
+// with HTTP Basic if secret is set and with client_id in the form otherwise.
+func (p *testProvider) oauthPost(t *testing.T, path string, form url.Values, clientID, secret string) (int, []byte) {
+	t.Helper()
+	if secret == "" {
+		form.Set("client_id", clientID)
+	}
+	req, _ := http.NewRequest(http.MethodPost, p.server.URL+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if secret != "" {
+		req.SetBasicAuth(clientID, secret)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body
+}
+
+func (p *testProvider) introspect(t *testing.T, token, clientID, secret string) Introspection {
+	t.Helper()
+	status, body := p.oauthPost(t, "/oauth/introspect", url.Values{"token": {token}}, clientID, secret)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", status, body)
+	}
+
+	var result Introspection
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("expected JSON response, got %v", err)
+	}
+	return result
+}
+
+func TestIntrospection(t *testing.T) {
+	p := newTestProvider(t)
+	tokens := p.issueTokens(t, p.client.ID)
+
+	gatewaySecret, hash, _ := auth.GenerateClientSecret()
+	gateway := &Client{ID: "client-gateway", Name: "Gateway", SecretHash: hash, Scopes: []string{string(auth.PermTokensIntrospect)}}
+	p.clients.CreateClient(context.Background(), gateway)
+
+	otherSecret, hash, _ := auth.GenerateClientSecret()
+	other := &Client{ID: "client-other", Name: "Other", SecretHash: hash, RedirectURIs: []string{testRedirectURI}}
+	p.clients.CreateClient(context.Background(), other)
+
+	t.Run("own token", func(t *testing.T) {
+		result := p.introspect(t, tokens.AccessToken, p.client.ID, p.secret)
+		if !result.Active || result.Sub != "user-1" || result.ClientID != p.client.ID || result.Scope != ScopeOpenID {
+			t.Fatalf("expected active token for user-1, got %+v", result)
+		}
+		if result.Jti == "" || result.Exp == 0 {
+			t.Fatalf("expected jti and exp, got %+v", result)
+		}
+		if result.SessionID != tokens.Session.ID || result.SessionStatus != "active" {
+			t.Fatalf("expected active session %s, got %+v", tokens.Session.ID, result)
+		}
+	})
+
+	t.Run("gateway may introspect any token", func(t *testing.T) {
+		if result := p.introspect(t, tokens.AccessToken, gateway.ID, gatewaySecret); !result.Active {
+			t.Fatalf("expected active token, got %+v", result)
+		}
+	})
+
+	t.Run("other client's token", func(t *testing.T) {
+		result := p.introspect(t, tokens.AccessToken, other.ID, otherSecret)
+		if result.Active || result.Sub != "" {
+			t.Fatalf("expected inactive token without claims, got %+v", result)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		if result := p.introspect(t, "not-a-token", p.client.ID, p.secret); result.Active {
+			t.Fatalf("expected inactive token, got %+v", result)
+		}
+	})
+
+	t.Run("public client", func(t *testing.T) {
+		status, body := p.oauthPost(t, "/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, p.publicClient.ID, "")
+		if status != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d: %s", status, body)
+		}
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		status, body := p.oauthPost(t, "/oauth/introspect", url.Values{"token": {tokens.AccessToken}}, p.client.ID, "wrong")
+		if status != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d: %s", status, body)
+		}
+	})
+}
+
+func TestRevocation(t *testing.T) {
+	p := newTestProvider(t)
+
+	revoke := func(t *testing.T, token, clientID, secret string) {
+		t.Helper()
+		status, body := p.oauthPost(t, "/oauth/revoke", url.Values{"token": {token}}, clientID, secret)
+		if status != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", status, body)
+		}
+	}
+
+	t.Run("access token", func(t *testing.T) {
+		tokens := p.issueTokens(t, p.client.ID)
+		revoke(t, tokens.AccessToken, p.client.ID, p.secret)
+
+		if result := p.introspect(t, tokens.AccessToken, p.client.ID, p.secret); result.Active {
+			t.Fatalf("expected revoked token to be inactive, got %+v", result)
+		}
+		// The session and its refresh token are unaffected
+		status, body := p.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}}, true)
+		if status != http.StatusOK {
+			t.Fatalf("expected refresh to succeed, got %d: %v", status, body)
+		}
+	})
+
+	t.Run("refresh token ends the session", func(t *testing.T) {
+		tokens := p.issueTokens(t, p.client.ID)
+		revoke(t, tokens.RefreshToken, p.client.ID, p.secret)
+
+		if result := p.introspect(t, tokens.AccessToken, p.client.ID, p.secret); result.Active {
+			t.Fatalf("expected access token of revoked session to be inactive, got %+v", result)
+		}
+		status, body := p.token(t, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}}, true)
+		if status != http.StatusBadRequest || body["error"] != "invalid_grant" {
+			t.Fatalf("expected invalid_grant, got %d: %v", status, body)
+		}
+	})
+
+	t.Run("another client's token is ignored", func(t *testing.T) {
+		tokens := p.issueTokens(t, p.client.ID)
+		revoke(t, tokens.AccessToken, p.publicClient.ID, "")
+		revoke(t, tokens.RefreshToken, p.publicClient.ID, "")
+
+		if result := p.introspect(t, tokens.AccessToken, p.client.ID, p.secret); !result.Active {
+			t.Fatalf("expected token to stay active, got %+v", result)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		revoke(t, "not-a-token", p.client.ID, p.secret)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		status, body := p.oauthPost(t, "/oauth/revoke", url.Values{}, p.client.ID, p.secret)
+		if status != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", status, body)
+		}
+	})
+}
+
+func TestNewProviderRejectsHMACKeys(t *testing.T) {
+	ring, _ := auth.NewKeyRing(auth.NewHMACSigningKey("", "secret"))
+	jwtService := auth.NewJWTServiceWithKeys(ring, time.Hour, "")
+
+	_, err := NewProvider(&testAccounts{}, NewTokenEndpoint(&testAccounts{}, NewInMemoryClientStore(), jwtService), NewInMemoryCodeStore(), config.OIDCConfig{Issuer: testIssuer})
+	if err != ErrSymmetricSigningKey {
+		t.Fatalf("expected ErrSymmetricSigningKey, got %v", err)
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	tests := map[string]bool{
+		"https://app.example.com/callback": true,
+		"http://localhost:3000/callback":   true,
+		"http://127.0.0.1/callback":        true,
+		"http://app.example.com/callback":  false,
+		"https://app.example.com/cb#frag":  false,
+		"/callback":                        false,
+		"javascript:alert(1)":              false,
+	}
+	for uri, want := range tests {
+		if got := ValidRedirectURI(uri); got != want {
+			t.Fatalf("expected ValidRedirectURI(%q) = %v, got %v", uri, want, got)
+		}
+	}
+}
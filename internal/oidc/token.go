@@ -0,0 +1,366 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+)
+
+// TokenResponse is a successful token endpoint response.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// TokenError is an OAuth 2.0 error response.
+type TokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// grantHandler issues tokens for one grant type to an authenticated client.
+type grantHandler func(c *gin.Context, client *Client)
+
+// TokenEndpoint serves the OAuth 2.0 token, introspection and revocation
+// endpoints. It always issues machine clients tokens of their own with the
+// client_credentials grant, so internal callers and gateways do not depend
+// on the OpenID Connect provider being enabled; the provider adds the grants
+// that act for users.
+type TokenEndpoint struct {
+	tokens  AccessTokens
+	clients ClientStore
+	jwt     *auth.JWTService
+	grants  map[string]grantHandler
+	logger  *logging.LoggerV2
+}
+
+// NewTokenEndpoint creates a token endpoint for the registered clients.
+// Tokens are signed with the JWT service's active key, and introspected and
+// revoked through tokens.
+func NewTokenEndpoint(tokens AccessTokens, clients ClientStore, jwtService *auth.JWTService) *TokenEndpoint {
+	e := &TokenEndpoint{
+		tokens:  tokens,
+		clients: clients,
+		jwt:     jwtService,
+		grants:  make(map[string]grantHandler),
+		logger:  logging.NewLoggerV2("oauth"),
+	}
+	e.grants["client_credentials"] = e.clientCredentials
+	return e
+}
+
+// Clients returns the store of registered clients.
+func (e *TokenEndpoint) Clients() ClientStore {
+	return e.clients
+}
+
+// RegisterRoutes adds the token, introspection and revocation endpoints to
+// r. loginLimits are applied to the requests that check client secrets,
+// except introspection, which gateways call for every request they
+// authorize.
+func (e *TokenEndpoint) RegisterRoutes(r gin.IRoutes, loginLimits ...gin.HandlerFunc) {
+	r.POST("/oauth/token", append(loginLimits, e.Token)...)
+	r.POST("/oauth/introspect", e.Introspect)
+	r.POST("/oauth/revoke", append(loginLimits, e.Revoke)...)
+}
+
+// Token handles POST /oauth/token
+func (e *TokenEndpoint) Token(c *gin.Context) {
+	// Tokens must never be cached by the client or intermediaries
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	client, ok := e.authenticateClient(c)
+	if !ok {
+		return
+	}
+
+	grant, ok := e.grants[c.PostForm("grant_type")]
+	if !ok {
+		e.tokenError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	grant(c, client)
+}
+
+// exchangeCode redeems an authorization code for the tokens of a new client
+// session.
+func (p *Provider) exchangeCode(c *gin.Context, client *Client) {
+	ctx := c.Request.Context()
+
+	code, err := p.codes.Consume(ctx, hashCode(c.PostForm("code")))
+	if err == ErrInvalidGrant {
+		p.tokenError(c, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid or has already been used")
+		return
+	}
+	if err != nil {
+		p.tokenServerError(c, err)
+		return
+	}
+
+	switch {
+	case code.ClientID != client.ID, code.RedirectURI != c.PostForm("redirect_uri"):
+		p.tokenError(c, http.StatusBadRequest, "invalid_grant", "The authorization code was issued to another client or redirect URI")
+		return
+	case time.Now().After(code.ExpiresAt):
+		p.tokenError(c, http.StatusBadRequest, "invalid_grant", "The authorization code has expired")
+		return
+	case !verifyPKCE(c.PostForm("code_verifier"), code.CodeChallenge):
+		p.tokenError(c, http.StatusBadRequest, "invalid_grant", "The code verifier does not match the code challenge")
+		return
+	}
+
+	// Signing out of the browser session before the code is redeemed
+	// cancels the sign-in
+	browser, err := p.accounts.Session(ctx, code.SessionID)
+	if err != nil {
+		p.tokenError(c, http.StatusBadRequest, "invalid_grant", "The user is no longer signed in")
+		return
+	}
+
+	tokens, err := p.accounts.IssueTokens(ctx, browser, client.ID, code.Scopes)
+	if err != nil {
+		p.tokenGrantError(c, err)
+		return
+	}
+
+	p.logger.Info("authorization code redeemed", logging.Fields{
+		"client_id":  client.ID,
+		"user_id":    code.UserID,
+		"session_id": tokens.Session.ID,
+	})
+
+	p.writeTokens(c, tokens, code.Nonce, code.AuthTime)
+}
+
+// refresh redeems a refresh token issued to the client.
+func (p *Provider) refresh(c *gin.Context, client *Client) {
+	tokens, err := p.accounts.Refresh(c.Request.Context(), client.ID, c.PostForm("refresh_token"))
+	if err != nil {
+		p.tokenGrantError(c, err)
+		return
+	}
+
+	p.writeTokens(c, tokens, "", time.Time{})
+}
+
+// clientCredentials issues a machine client a token for itself (RFC 6749
+// section 4.4), limited to the scopes registered for it. No refresh token
+// is issued; the client authenticates again when the token expires.
+func (e *TokenEndpoint) clientCredentials(c *gin.Context, client *Client) {
+	if !client.Confidential() || len(client.Scopes) == 0 {
+		e.tokenError(c, http.StatusBadRequest, "unauthorized_client", "The client may not use the client_credentials grant")
+		return
+	}
+
+	// Omitting scope requests every scope the client is registered for
+	scopes := client.Scopes
+	if requested := strings.Fields(c.PostForm("scope")); len(requested) > 0 {
+		scopes = nil
+		for _, scope := range requested {
+			if !containsScope(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		if !client.AllowsScopes(scopes) {
+			e.tokenError(c, http.StatusBadRequest, "invalid_scope", "The client may not request these scopes")
+			return
+		}
+	}
+
+	token, err := e.jwt.GenerateClientToken(client.ID, scopes)
+	if err != nil {
+		e.tokenServerError(c, err)
+		return
+	}
+
+	e.logger.Info("client credentials token issued", logging.Fields{
+		"client_id": client.ID,
+		"scope":     strings.Join(scopes, " "),
+	})
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(e.jwt.Expiration() / time.Second),
+		Scope:       strings.Join(scopes, " "),
+	})
+}
+
+// writeTokens responds with a client session's tokens and a fresh ID token.
+func (p *Provider) writeTokens(c *gin.Context, tokens *Tokens, nonce string, authTime time.Time) {
+	user, emailVerified, err := p.accounts.User(c.Request.Context(), tokens.Session.UserID)
+	if err != nil {
+		p.tokenServerError(c, err)
+		return
+	}
+
+	idToken, err := p.idToken(user, emailVerified, tokens, nonce, authTime)
+	if err != nil {
+		p.tokenServerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn / time.Second),
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      idToken,
+		Scope:        strings.Join(tokens.Session.Scopes, " "),
+	})
+}
+
+// authenticateClient identifies the client making a token request.
+// Confidential clients authenticate with HTTP Basic or client_secret in the
+// form; public clients only send client_id.
+func (e *TokenEndpoint) authenticateClient(c *gin.Context) (*Client, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before
+		// they are base64-encoded
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err == nil {
+			secret, err = url.QueryUnescape(secret)
+		}
+		if err != nil {
+			e.invalidClient(c, basic)
+			return nil, false
+		}
+	} else {
+		clientID = c.PostForm("client_id")
+		secret = c.PostForm("client_secret")
+	}
+
+	client, err := e.clients.GetClient(c.Request.Context(), clientID)
+	if err == errors.ErrNotFound {
+		e.invalidClient(c, basic)
+		return nil, false
+	}
+	if err != nil {
+		e.tokenServerError(c, err)
+		return nil, false
+	}
+
+	if client.Confidential() {
+		if secret == "" || !auth.VerifyClientSecret(secret, client.SecretHash) {
+			e.logger.Warn("client authentication failed", logging.Fields{"client_id": client.ID})
+			e.invalidClient(c, basic)
+			return nil, false
+		}
+	} else if secret != "" {
+		e.invalidClient(c, basic)
+		return nil, false
+	}
+
+	return client, true
+}
+
+func (e *TokenEndpoint) invalidClient(c *gin.Context, basic bool) {
+	if basic {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	e.tokenError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+}
+
+// tokenGrantError maps an error from issuing or refreshing tokens.
+func (e *TokenEndpoint) tokenGrantError(c *gin.Context, err error) {
+	switch err {
+	case ErrInvalidGrant, auth.ErrInvalidToken, auth.ErrExpiredToken, auth.ErrRefreshTokenReused,
+		auth.ErrSessionNotFound, auth.ErrSessionExpired, auth.ErrSessionRevoked, auth.ErrSessionInvalid,
+		errors.ErrUserInactive, errors.ErrNotFound:
+		e.tokenError(c, http.StatusBadRequest, "invalid_grant", "The grant is invalid, expired or revoked")
+	case auth.ErrTooManySessions:
+		e.tokenError(c, http.StatusBadRequest, "invalid_grant", "The user has too many active sessions")
+	default:
+		e.tokenServerError(c, err)
+	}
+}
+
+func (e *TokenEndpoint) tokenServerError(c *gin.Context, err error) {
+	e.logger.Error("token request failed", logging.Fields{"error": err.Error()})
+	e.tokenError(c, http.StatusInternalServerError, "server_error", "")
+}
+
+func (e *TokenEndpoint) tokenError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, TokenError{Error: code, ErrorDescription: description})
+}
+
+// UserInfo handles GET and POST /oauth/userinfo
+func (p *Provider) UserInfo(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" || token == c.GetHeader("Authorization") {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := p.accounts.ValidateAccessToken(ctx, token)
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth", error="invalid_token"`)
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+	if !claims.HasScope(ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer realm="oauth", error="insufficient_scope", scope="openid"`)
+		c.Status(http.StatusForbidden)
+		return
+	}
+
+	user, emailVerified, err := p.accounts.User(ctx, claims.UserID)
+	if err != nil {
+		p.logger.Error("userinfo lookup failed", logging.Fields{
+			"user_id": claims.UserID,
+			"error":   err.Error(),
+		})
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	info := &auth.IDTokenClaims{}
+	info.Subject = user.ID
+	addUserClaims(info, user, emailVerified, strings.Fields(claims.Scope))
+	c.JSON(http.StatusOK, info)
+}
+
+// validPKCEValue reports whether v is a well-formed code verifier or S256
+// code challenge: 43 to 128 unreserved characters (RFC 7636 section 4.1).
+func validPKCEValue(v string) bool {
+	if len(v) < 43 || len(v) > 128 {
+		return false
+	}
+	for _, r := range v {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9',
+			r == '-', r == '.', r == '_', r == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// verifyPKCE reports whether verifier hashes to the S256 challenge.
+func verifyPKCE(verifier, challenge string) bool {
+	if !validPKCEValue(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,252 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Policy limits how many requests a single key may make within a sliding
+// window.
+type Policy struct {
+	// Name identifies the policy and namespaces its counters.
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Result describes the state of a key after a call to Allow.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is the time until the current fixed window ends.
+	Reset time.Duration
+
+	// RetryAfter is the earliest time a denied request could succeed.
+	// It is zero for allowed requests.
+	RetryAfter time.Duration
+
+	Policy Policy
+}
+
+// Limiter decides whether a request identified by key is within policy.
+type Limiter interface {
+	// Allow records a request and reports whether it is allowed. Denied
+	// requests are not counted.
+	Allow(ctx context.Context, key string, policy Policy) (*Result, error)
+}
+
+// The limiters implement a sliding window counter: requests are counted in
+// fixed windows, and the previous window's count is weighted by how much of
+// it still overlaps the sliding window ending now. This keeps two counters
+// per key while avoiding the burst of up to twice the limit that a plain
+// fixed window allows at window boundaries.
+
+// windowPosition returns the index of the fixed window containing now and how
+// far into that window now is.
+func windowPosition(now time.Time, window time.Duration) (int64, time.Duration) {
+	n := now.UnixNano()
+	return n / int64(window), time.Duration(n % int64(window))
+}
+
+// previousWeight is the fraction of the previous fixed window that still
+// overlaps the sliding window.
+func previousWeight(elapsed, window time.Duration) float64 {
+	return 1 - float64(elapsed)/float64(window)
+}
+
+func estimate(prev, curr int64, weight float64) int64 {
+	return int64(float64(prev)*weight) + curr
+}
+
+// newResult builds a result from the counters of the previous and current
+// fixed windows. For allowed requests curr includes the request itself.
+func newResult(allowed bool, prev, curr int64, policy Policy, elapsed time.Duration) *Result {
+	limit := int64(policy.Limit)
+	used := estimate(prev, curr, previousWeight(elapsed, policy.Window))
+
+	res := &Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(max(limit-used, 0)),
+		Reset:     policy.Window - elapsed,
+		Policy:    policy,
+	}
+	if !allowed {
+		res.RetryAfter = retryAfter(prev, curr, limit, policy.Window, elapsed)
+	}
+	return res
+}
+
+// retryAfter returns how long until the estimated count drops below limit.
+func retryAfter(prev, curr, limit int64, window, elapsed time.Duration) time.Duration {
+	if curr < limit && prev > 0 {
+		// Wait for the previous window to slide far enough out
+		at := time.Duration((1 - float64(limit-curr)/float64(prev)) * float64(window))
+		if at > elapsed {
+			return at - elapsed
+		}
+		return 0
+	}
+	if curr == 0 {
+		// Only reachable with a non-positive limit
+		return window - elapsed
+	}
+
+	// The current window alone is over the limit, so wait for it to become
+	// the previous window and slide out
+	at := time.Duration((1 - float64(limit)/float64(curr)) * float64(window))
+	return window - elapsed + at
+}
+
+// slidingWindowScript checks and increments the current window's counter
+// atomically. It returns {allowed, previous count, current count}.
+var slidingWindowScript = redis.NewScript(`
+local prev = tonumber(redis.call('GET', KEYS[1]) or '0')
+local curr = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+
+if math.floor(prev * weight) + curr >= limit then
+	return {0, prev, curr}
+end
+
+curr = redis.call('INCR', KEYS[2])
+if curr == 1 then
+	redis.call('PEXPIRE', KEYS[2], ARGV[3])
+end
+return {1, prev, curr}
+`)
+
+// RedisLimiter shares counters across replicas through Redis.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter creates a new Redis-backed limiter.
+func NewRedisLimiter(cfg config.RedisConfig) *RedisLimiter {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, policy Policy) (*Result, error) {
+	idx, elapsed := windowPosition(time.Now(), policy.Window)
+
+	// The hash tag keeps both windows of a key in the same cluster slot
+	base := fmt.Sprintf("%s{%s:%s}:", keyPrefix, policy.Name, key)
+	keys := []string{
+		fmt.Sprintf("%s%d", base, idx-1),
+		fmt.Sprintf("%s%d", base, idx),
+	}
+
+	vals, err := slidingWindowScript.Run(ctx, l.client, keys,
+		policy.Limit,
+		previousWeight(elapsed, policy.Window),
+		// Keep each window around long enough to serve as the previous one
+		(2 * policy.Window).Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return newResult(vals[0] == 1, vals[1], vals[2], policy, elapsed), nil
+}
+
+// InMemoryLimiter keeps counters in process. It is suitable for single-node
+// deployments and tests.
+type InMemoryLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*windowCounter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type windowCounter struct {
+	index  int64
+	prev   int64
+	curr   int64
+	window time.Duration
+}
+
+// sweepInterval is how often expired counters are evicted.
+const sweepInterval = time.Minute
+
+func NewInMemoryLimiter() *InMemoryLimiter {
+	return &InMemoryLimiter{
+		windows: make(map[string]*windowCounter),
+		now:     time.Now,
+	}
+}
+
+func (l *InMemoryLimiter) Allow(ctx context.Context, key string, policy Policy) (*Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	idx, elapsed := windowPosition(now, policy.Window)
+	mapKey := policy.Name + ":" + key
+
+	w, ok := l.windows[mapKey]
+	if !ok {
+		w = &windowCounter{index: idx, window: policy.Window}
+		l.windows[mapKey] = w
+	}
+	w.advance(idx)
+
+	if estimate(w.prev, w.curr, previousWeight(elapsed, policy.Window)) >= int64(policy.Limit) {
+		return newResult(false, w.prev, w.curr, policy, elapsed), nil
+	}
+
+	w.curr++
+	return newResult(true, w.prev, w.curr, policy, elapsed), nil
+}
+
+// advance rolls the counters forward to the window with the given index.
+func (w *windowCounter) advance(idx int64) {
+	switch {
+	case idx == w.index:
+		return
+	case idx == w.index+1:
+		w.prev, w.curr = w.curr, 0
+	default:
+		w.prev, w.curr = 0, 0
+	}
+	w.index = idx
+}
+
+// sweep evicts counters that no longer affect any decision.
+// Callers must hold l.mu.
+func (l *InMemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, w := range l.windows {
+		idx, _ := windowPosition(now, w.window)
+		if idx > w.index+1 {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// Ensure implementations satisfy the interface
+var (
+	_ Limiter = (*RedisLimiter)(nil)
+	_ Limiter = (*InMemoryLimiter)(nil)
+)
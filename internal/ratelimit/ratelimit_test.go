@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(start time.Time) (*InMemoryLimiter, *time.Time) {
+	now := start
+	l := NewInMemoryLimiter()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestInMemoryLimiter(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "test", Limit: 3, Window: time.Minute}
+
+	// Start exactly on a window boundary so the previous window is empty
+	limiter, _ := newTestLimiter(time.Unix(0, 0).Add(1000 * time.Minute))
+
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(ctx, "user-1", policy)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !res.Allowed {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Fatalf("expected %d remaining, got %d", 2-i, res.Remaining)
+		}
+	}
+
+	t.Run("denied over limit", func(t *testing.T) {
+		res, _ := limiter.Allow(ctx, "user-1", policy)
+		if res.Allowed {
+			t.Fatal("expected request to be denied")
+		}
+		if res.Remaining != 0 {
+			t.Fatalf("expected 0 remaining, got %d", res.Remaining)
+		}
+		if res.RetryAfter <= 0 {
+			t.Fatalf("expected positive retry-after, got %v", res.RetryAfter)
+		}
+	})
+
+	t.Run("keys are independent", func(t *testing.T) {
+		res, _ := limiter.Allow(ctx, "user-2", policy)
+		if !res.Allowed {
+			t.Fatal("expected other key to be allowed")
+		}
+	})
+
+	t.Run("policies are independent", func(t *testing.T) {
+		other := Policy{Name: "other", Limit: 1, Window: time.Minute}
+		res, _ := limiter.Allow(ctx, "user-1", other)
+		if !res.Allowed {
+			t.Fatal("expected other policy to be allowed")
+		}
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "test", Limit: 4, Window: time.Minute}
+	start := time.Unix(0, 0).Add(1000 * time.Minute)
+	limiter, now := newTestLimiter(start)
+
+	for i := 0; i < 4; i++ {
+		limiter.Allow(ctx, "ip", policy)
+	}
+
+	tests := []struct {
+		name    string
+		offset  time.Duration
+		allowed bool
+	}{
+		// At the start of the next window the previous one still fully counts
+		{"start of next window", time.Minute, false},
+		// Halfway through, half of the previous window's 4 requests count
+		{"halfway through next window", 90 * time.Second, true},
+		{"halfway, second request", 90 * time.Second, true},
+		{"halfway, over limit", 90 * time.Second, false},
+		// Two windows later nothing carries over
+		{"two windows later", 3 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*now = start.Add(tt.offset)
+			res, _ := limiter.Allow(ctx, "ip", policy)
+			if res.Allowed != tt.allowed {
+				t.Fatalf("expected allowed=%v, got %v", tt.allowed, res.Allowed)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "test", Limit: 2, Window: time.Minute}
+	start := time.Unix(0, 0).Add(1000 * time.Minute)
+	limiter, now := newTestLimiter(start)
+
+	limiter.Allow(ctx, "ip", policy)
+	limiter.Allow(ctx, "ip", policy)
+
+	*now = start.Add(10 * time.Second)
+	denied, _ := limiter.Allow(ctx, "ip", policy)
+	if denied.Allowed {
+		t.Fatal("expected request to be denied")
+	}
+
+	*now = now.Add(denied.RetryAfter + time.Millisecond)
+	res, _ := limiter.Allow(ctx, "ip", policy)
+	if !res.Allowed {
+		t.Fatalf("expected request to be allowed after %v", denied.RetryAfter)
+	}
+}
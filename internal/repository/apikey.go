@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// APIKey is a long-lived credential that acts as its owning user, limited to
+// its scopes. Only a salted hash of the key is stored.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+
+	// Prefix is the lookup ID embedded in the key. It is safe to show and
+	// lets users tell their keys apart.
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Active     bool       `json:"-"`
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired reports whether the key has passed its expiry time.
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// CreateAPIKey stores a new API key.
+func (s *PostgresUserStore) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	key.CreatedAt = time.Now().UTC()
+	key.Active = true
+	query := `
+		INSERT INTO api_keys (id, user_id, key_hash, key_prefix, name, scopes, created_at, expires_at, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, true)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		key.ID,
+		key.UserID,
+		key.KeyHash,
+		key.Prefix,
+		key.Name,
+		pq.Array(key.Scopes),
+		key.CreatedAt,
+		nullTime(key.ExpiresAt),
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return errors.ErrAlreadyExists
+		}
+		s.logger.Error("failed to store API key", logging.Fields{
+			"user_id": key.UserID,
+			"error":   err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// GetAPIKeyByPrefix retrieves an active API key by its lookup prefix.
+func (s *PostgresUserStore) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_prefix, key_hash, scopes, created_at, expires_at, last_used_at, active
+		FROM api_keys
+		WHERE key_prefix = $1 AND active
+	`
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, prefix))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	return key, err
+}
+
+// GetAPIKey retrieves one of a user's active API keys.
+func (s *PostgresUserStore) GetAPIKey(ctx context.Context, userID, id string) (*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_prefix, key_hash, scopes, created_at, expires_at, last_used_at, active
+		FROM api_keys
+		WHERE id = $1 AND user_id = $2 AND active
+	`
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	return key, err
+}
+
+// ListAPIKeys lists a user's active API keys, newest first. Expired keys are
+// included until they are revoked.
+func (s *PostgresUserStore) ListAPIKeys(ctx context.Context, userID string) ([]*APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_prefix, key_hash, scopes, created_at, expires_at, last_used_at, active
+		FROM api_keys
+		WHERE user_id = $1 AND active
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RotateAPIKey replaces an active key's secret, keeping its name, scopes and
+// expiry. The old secret stops working immediately.
+func (s *PostgresUserStore) RotateAPIKey(ctx context.Context, userID, id, prefix, hash string) error {
+	query := `
+		UPDATE api_keys
+		SET key_prefix = $1, key_hash = $2, last_used_at = NULL
+		WHERE id = $3 AND user_id = $4 AND active
+	`
+
+	result, err := s.db.ExecContext(ctx, query, prefix, hash, id, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// RevokeAPIKey deactivates one of a user's API keys. It returns
+// errors.ErrNotFound if the user has no such active key.
+func (s *PostgresUserStore) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	query := `UPDATE api_keys SET active = false, revoked_at = $1 WHERE id = $2 AND user_id = $3 AND active`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// TouchAPIKey records that a key was just used.
+func (s *PostgresUserStore) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, usedAt, id)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var name sql.NullString
+	var expiresAt, lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&name,
+		&key.Prefix,
+		&key.KeyHash,
+		pq.Array(&key.Scopes),
+		&key.CreatedAt,
+		&expiresAt,
+		&lastUsedAt,
+		&key.Active,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.Name = name.String
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return &key, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
 )
 
 const (
@@ -48,10 +49,11 @@ func (c *RedisUserCache) Get(ctx context.Context, id string) (*models.User, erro
 	data, err := c.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
 		c.logger.Debug("cache miss", logging.Fields{"key": key})
+		metrics.UserCacheRequests.WithLabelValues("miss").Inc()
 		return nil, nil // Cache miss, not an error
 	}
 	if err != nil {
-		// TODO(TEAM-PLATFORM): Add metrics for cache errors
+		metrics.UserCacheRequests.WithLabelValues("error").Inc()
 		logging.Errorf("cache get error for key %s: %v", key, err)
 		return nil, err
 	}
@@ -62,9 +64,11 @@ func (c *RedisUserCache) Get(ctx context.Context, id string) (*models.User, erro
 			"key":   key,
 			"error": err.Error(),
 		})
+		metrics.UserCacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
+	metrics.UserCacheRequests.WithLabelValues("hit").Inc()
 	c.logger.Debug("cache hit", logging.Fields{"key": key, "user_id": user.ID})
 	return &user, nil
 }
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Device is a browser or app a user has logged in from, identified by the
+// device ID cookie it presents.
+type Device struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"-"`
+	Browser       string    `json:"browser"`
+	OS            string    `json:"os"`
+	Type          string    `json:"type"`
+	LastIPAddress string    `json:"last_ip_address"`
+	FirstSeenAt   time.Time `json:"first_seen_at"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+}
+
+// RecordDevice records a login from a device, setting its last-seen time and
+// details. It reports whether this is the first login from the device.
+func (s *PostgresUserStore) RecordDevice(ctx context.Context, device *Device) (bool, error) {
+	// Postgres stores microseconds, so compare at that precision below
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	query := `
+		INSERT INTO user_devices (
+			user_id, device_id, browser, os, device_type, last_ip_address, first_seen_at, last_seen_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (user_id, device_id) DO UPDATE
+		SET browser = EXCLUDED.browser, os = EXCLUDED.os, device_type = EXCLUDED.device_type,
+			last_ip_address = EXCLUDED.last_ip_address, last_seen_at = EXCLUDED.last_seen_at
+		RETURNING first_seen_at
+	`
+
+	err := s.db.QueryRowContext(ctx, query,
+		device.UserID,
+		device.ID,
+		device.Browser,
+		device.OS,
+		device.Type,
+		sql.NullString{String: device.LastIPAddress, Valid: device.LastIPAddress != ""},
+		now,
+	).Scan(&device.FirstSeenAt)
+	if err != nil {
+		return false, err
+	}
+
+	device.LastSeenAt = now
+	return device.FirstSeenAt.Equal(now), nil
+}
+
+// ListDevices lists the devices a user has logged in from, most recently
+// seen first.
+func (s *PostgresUserStore) ListDevices(ctx context.Context, userID string) ([]*Device, error) {
+	query := `
+		SELECT device_id, user_id, browser, os, device_type, last_ip_address, first_seen_at, last_seen_at
+		FROM user_devices
+		WHERE user_id = $1
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []*Device{}
+	for rows.Next() {
+		var device Device
+		var lastIPAddress sql.NullString
+		err := rows.Scan(
+			&device.ID,
+			&device.UserID,
+			&device.Browser,
+			&device.OS,
+			&device.Type,
+			&lastIPAddress,
+			&device.FirstSeenAt,
+			&device.LastSeenAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		device.LastIPAddress = lastIPAddress.String
+		devices = append(devices, &device)
+	}
+	return devices, rows.Err()
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// uniqueViolation is the PostgreSQL error code for a unique constraint failure.
+const uniqueViolation = "23505"
+
+// EmailVerification is the verification state of a user's email address.
+type EmailVerification struct {
+	Email        string     `json:"email"`
+	Verified     bool       `json:"verified"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	PendingEmail string     `json:"pending_email,omitempty"`
+}
+
+// GetEmailVerification retrieves the verification state of a user's email.
+func (s *PostgresUserStore) GetEmailVerification(ctx context.Context, id string) (*EmailVerification, error) {
+	query := `
+		SELECT email, email_verified, email_verified_at, pending_email
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var v EmailVerification
+	var verifiedAt sql.NullTime
+	var pendingEmail sql.NullString
+
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&v.Email, &v.Verified, &verifiedAt, &pendingEmail)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if verifiedAt.Valid {
+		v.VerifiedAt = &verifiedAt.Time
+	}
+	v.PendingEmail = pendingEmail.String
+
+	return &v, nil
+}
+
+// MarkEmailVerified marks email as verified, provided it is still the user's
+// current address. It returns errors.ErrNotFound if the address has changed.
+func (s *PostgresUserStore) MarkEmailVerified(ctx context.Context, id, email string) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE users
+		SET email_verified = true, email_verified_at = COALESCE(email_verified_at, $1), updated_at = $1
+		WHERE id = $2 AND email = $3 AND deleted_at IS NULL
+	`
+
+	result, err := s.db.ExecContext(ctx, query, now, id, email)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// SetPendingEmail records an address the user has asked to change to. The
+// current address is unchanged until ConfirmEmailChange.
+func (s *PostgresUserStore) SetPendingEmail(ctx context.Context, id, email string) error {
+	query := `UPDATE users SET pending_email = $1, updated_at = $2 WHERE id = $3 AND deleted_at IS NULL`
+
+	result, err := s.db.ExecContext(ctx, query, email, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// ConfirmEmailChange replaces the user's address with the pending one, which
+// must equal email. It returns errors.ErrNotFound if no such change is
+// pending and errors.ErrAlreadyExists if another account took the address in
+// the meantime.
+func (s *PostgresUserStore) ConfirmEmailChange(ctx context.Context, id, email string) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL,
+		    email_verified = true, email_verified_at = $1, updated_at = $1
+		WHERE id = $2 AND pending_email = $3 AND deleted_at IS NULL
+	`
+
+	result, err := s.db.ExecContext(ctx, query, now, id, email)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return errors.ErrAlreadyExists
+		}
+		s.logger.Error("failed to confirm email change", logging.Fields{
+			"user_id": id,
+			"error":   err.Error(),
+		})
+		return err
+	}
+	return requireRow(result)
+}
+
+func requireRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
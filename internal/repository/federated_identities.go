@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// FederatedIdentity links a user to their account at an external identity
+// provider.
+type FederatedIdentity struct {
+	Provider string `json:"provider"`
+
+	// Subject is the provider's stable identifier for the user.
+	Subject     string     `json:"subject"`
+	UserID      string     `json:"-"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// GetFederatedIdentity retrieves the link for a provider's subject.
+func (s *PostgresUserStore) GetFederatedIdentity(ctx context.Context, provider, subject string) (*FederatedIdentity, error) {
+	query := `
+		SELECT provider, subject, user_id, email, created_at, last_login_at
+		FROM federated_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var identity FederatedIdentity
+	var lastLoginAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&identity.Provider,
+		&identity.Subject,
+		&identity.UserID,
+		&identity.Email,
+		&identity.CreatedAt,
+		&lastLoginAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return &identity, nil
+}
+
+// CreateFederatedIdentity links a provider's subject to a user. It returns
+// errors.ErrAlreadyExists if the subject is already linked.
+func (s *PostgresUserStore) CreateFederatedIdentity(ctx context.Context, identity *FederatedIdentity) error {
+	identity.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO federated_identities (provider, subject, user_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		identity.Provider,
+		identity.Subject,
+		identity.UserID,
+		identity.Email,
+		identity.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return errors.ErrAlreadyExists
+		}
+		s.logger.Error("failed to link federated identity", logging.Fields{
+			"user_id":  identity.UserID,
+			"provider": identity.Provider,
+			"error":    err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// RecordFederatedLogin sets the last login time of a linked identity.
+func (s *PostgresUserStore) RecordFederatedLogin(ctx context.Context, provider, subject string) error {
+	query := `
+		UPDATE federated_identities
+		SET last_login_at = $1
+		WHERE provider = $2 AND subject = $3
+	`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), provider, subject)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// MFA is a user's second-factor enrollment. Secret is the encrypted TOTP
+// secret; it is never returned to API callers.
+type MFA struct {
+	UserID       string
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+	ConfirmedAt  *time.Time
+}
+
+// GetMFA retrieves a user's MFA enrollment, pending or confirmed.
+// It returns errors.ErrNotFound if the user never started enrollment.
+func (s *PostgresUserStore) GetMFA(ctx context.Context, userID string) (*MFA, error) {
+	query := `
+		SELECT user_id, totp_secret, enabled, last_used_step, confirmed_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+
+	var m MFA
+	var lastUsedStep sql.NullInt64
+	var confirmedAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&m.UserID, &m.Secret, &m.Enabled, &lastUsedStep, &confirmedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.LastUsedStep = lastUsedStep.Int64
+	if confirmedAt.Valid {
+		m.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &m, nil
+}
+
+// SavePendingMFA stores a new, not yet confirmed TOTP secret, replacing any
+// earlier pending one. It returns errors.ErrAlreadyExists if MFA is already
+// enabled.
+func (s *PostgresUserStore) SavePendingMFA(ctx context.Context, userID, secret string) error {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO user_mfa (user_id, totp_secret, enabled, created_at, updated_at)
+		VALUES ($1, $2, false, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, last_used_step = NULL, updated_at = EXCLUDED.updated_at
+		WHERE user_mfa.enabled = false
+	`
+
+	result, err := s.db.ExecContext(ctx, query, userID, secret, now)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err == errors.ErrNotFound {
+		return errors.ErrAlreadyExists
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnableMFA confirms a pending enrollment and stores its recovery code
+// hashes, replacing any earlier ones. step is the time step of the code
+// used to confirm, which cannot be used again.
+func (s *PostgresUserStore) EnableMFA(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_mfa
+		SET enabled = true, last_used_step = $1, confirmed_at = $2, updated_at = $2
+		WHERE user_id = $3 AND enabled = false
+	`, step, now, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes, now); err != nil {
+		s.logger.Error("failed to store recovery codes", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableMFA removes a user's enrollment and recovery codes.
+func (s *PostgresUserStore) DisableMFA(ctx context.Context, userID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRow(result); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as used. It returns errors.ErrNotFound if step is
+// not newer than the last accepted one, so a code cannot be replayed.
+func (s *PostgresUserStore) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET last_used_step = $1, updated_at = $2
+		WHERE user_id = $3 AND enabled = true
+		  AND (last_used_step IS NULL OR last_used_step < $1)
+	`
+
+	result, err := s.db.ExecContext(ctx, query, step, time.Now().UTC(), userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It returns
+// errors.ErrNotFound if the code does not exist or was already used.
+func (s *PostgresUserStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = $1
+		WHERE id = (
+			SELECT id FROM mfa_recovery_codes
+			WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL
+	`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones.
+func (s *PostgresUserStore) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes.
+func (s *PostgresUserStore) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	query := `SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL`
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`,
+			userID, hash, now,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// AddPasswordHistory records a password hash the user is moving away from
+// and prunes their history to the newest keep entries.
+func (s *PostgresUserStore) AddPasswordHistory(ctx context.Context, userID, hash string, keep int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO password_history (user_id, password_hash, created_at) VALUES ($1, $2, $3)`,
+		userID, hash, time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		)
+	`
+	if _, err := tx.ExecContext(ctx, query, userID, keep); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPasswordHistory returns up to limit of a user's previous password
+// hashes, newest first.
+func (s *PostgresUserStore) GetPasswordHistory(ctx context.Context, userID string, limit int) ([]string, error) {
+	query := `
+		SELECT password_hash
+		FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
 )
 
 // PostgresUserStore implements the interfaces.UserStore interface
@@ -366,25 +367,33 @@ func (s *PostgresUserStore) GetPasswordHash(ctx context.Context, id string) (str
 	return hash, err
 }
 
-// UpdatePasswordHash updates the user's password hash.
-func (s *PostgresUserStore) UpdatePasswordHash(ctx context.Context, id, hash string) error {
-	query := `UPDATE users SET password_hash = $1, updated_at = $2 WHERE id = $3`
-	_, err := s.db.ExecContext(ctx, query, hash, time.Now().UTC(), id)
+// UpdatePasswordHash updates the user's password hash and records its type,
+// which also clears a forced 'reset_required' state and the breached flag.
+func (s *PostgresUserStore) UpdatePasswordHash(ctx context.Context, id, hash, hashType string) error {
+	query := `
+		UPDATE users
+		SET password_hash = $1, password_hash_type = $2, password_breached = false,
+			password_breached_at = NULL, updated_at = $3
+		WHERE id = $4
+	`
+	_, err := s.db.ExecContext(ctx, query, hash, hashType, time.Now().UTC(), id)
 	return err
 }
 
-func generateUserID() string {
-	// Simple ID generation for demo
-	return "user-" + randomString(12)
+// MarkPasswordBreached flags a user whose current password was found in the
+// breached password corpus. The first detection time is kept.
+func (s *PostgresUserStore) MarkPasswordBreached(ctx context.Context, id string) error {
+	query := `
+		UPDATE users
+		SET password_breached = true, password_breached_at = $1
+		WHERE id = $2 AND NOT password_breached
+	`
+	_, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id)
+	return err
 }
 
-func randomString(n int) string {
-	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = letters[i%len(letters)]
-	}
-	return string(b)
+func generateUserID() string {
+	return ids.NewUserID()
 }
 
 func joinStrings(s []string, sep string) string {
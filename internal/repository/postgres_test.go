@@ -21,24 +21,6 @@ func TestGenerateUserID(t *testing.T) {
 	}
 }
 
-func TestRandomString(t *testing.T) {
-	tests := []struct {
-		length int
-	}{
-		{0},
-		{5},
-		{10},
-		{20},
-	}
-
-	for _, tt := range tests {
-		result := randomString(tt.length)
-		if len(result) != tt.length {
-			t.Fatalf("expected length %d, got %d", tt.length, len(result))
-		}
-	}
-}
-
 func TestJoinStrings(t *testing.T) {
 	tests := []struct {
 		input    []string
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+)
+
+// WebAuthnCredential is a registered passkey or security key.
+type WebAuthnCredential struct {
+	// ID is the base64url-encoded credential ID.
+	ID     string `json:"id"`
+	UserID string `json:"-"`
+	Name   string `json:"name"`
+
+	// PublicKey is the COSE-encoded public key. It is never returned to clients.
+	PublicKey  []byte     `json:"-"`
+	SignCount  uint32     `json:"-"`
+	Transports []string   `json:"transports"`
+	AAGUID     string     `json:"aaguid,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// CreateWebAuthnCredential stores a newly registered credential. It returns
+// errors.ErrAlreadyExists if the credential ID is already registered.
+func (s *PostgresUserStore) CreateWebAuthnCredential(ctx context.Context, cred *WebAuthnCredential) error {
+	cred.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO webauthn_credentials (
+			id, user_id, name, public_key, sign_count, transports, aaguid, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		cred.ID,
+		cred.UserID,
+		cred.Name,
+		cred.PublicKey,
+		int64(cred.SignCount),
+		pq.Array(cred.Transports),
+		sql.NullString{String: cred.AAGUID, Valid: cred.AAGUID != ""},
+		cred.CreatedAt,
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+			return errors.ErrAlreadyExists
+		}
+		s.logger.Error("failed to store WebAuthn credential", logging.Fields{
+			"user_id": cred.UserID,
+			"error":   err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// GetWebAuthnCredential retrieves a credential by ID.
+func (s *PostgresUserStore) GetWebAuthnCredential(ctx context.Context, id string) (*WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, transports, aaguid, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE id = $1
+	`
+
+	cred, err := scanWebAuthnCredential(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, errors.ErrNotFound
+	}
+	return cred, err
+}
+
+// ListWebAuthnCredentials lists a user's credentials, oldest first.
+func (s *PostgresUserStore) ListWebAuthnCredentials(ctx context.Context, userID string) ([]*WebAuthnCredential, error) {
+	query := `
+		SELECT id, user_id, name, public_key, sign_count, transports, aaguid, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creds := []*WebAuthnCredential{}
+	for rows.Next() {
+		cred, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	return creds, rows.Err()
+}
+
+// UpdateWebAuthnSignCount records a successful login with a credential. The
+// update only applies if the stored counter has not moved on since it was
+// read, so concurrent logins with a cloned authenticator cannot both succeed.
+func (s *PostgresUserStore) UpdateWebAuthnSignCount(ctx context.Context, id string, previous, signCount uint32) error {
+	query := `
+		UPDATE webauthn_credentials
+		SET sign_count = $1, last_used_at = $2
+		WHERE id = $3 AND sign_count = $4
+	`
+
+	result, err := s.db.ExecContext(ctx, query, int64(signCount), time.Now().UTC(), id, int64(previous))
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+// DeleteWebAuthnCredential removes one of a user's credentials. It returns
+// errors.ErrNotFound if the user has no such credential.
+func (s *PostgresUserStore) DeleteWebAuthnCredential(ctx context.Context, userID, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebAuthnCredential(row rowScanner) (*WebAuthnCredential, error) {
+	var cred WebAuthnCredential
+	var signCount int64
+	var aaguid sql.NullString
+	var lastUsedAt sql.NullTime
+
+	err := row.Scan(
+		&cred.ID,
+		&cred.UserID,
+		&cred.Name,
+		&cred.PublicKey,
+		&signCount,
+		pq.Array(&cred.Transports),
+		&aaguid,
+		&cred.CreatedAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cred.SignCount = uint32(signCount)
+	cred.AAGUID = aaguid.String
+	if lastUsedAt.Valid {
+		cred.LastUsedAt = &lastUsedAt.Time
+	}
+	return &cred, nil
+}
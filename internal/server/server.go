@@ -4,13 +4,19 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/middleware"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ratelimit"
 )
 
 // Server represents the HTTP server.
@@ -18,12 +24,16 @@ type Server struct {
 	srv     *http.Server
 	router  *gin.Engine
 	handler *handlers.Handlers
+	tokens  *oidc.TokenEndpoint
+	oidc    *oidc.Provider
+	limiter ratelimit.Limiter
 	config  *config.Config
 	logger  *logging.LoggerV2
 }
 
-// New creates a new server instance.
-func New(h *handlers.Handlers, cfg *config.Config) *Server {
+// New creates a new server instance. provider may be nil if the OpenID
+// Connect provider is disabled; the token endpoint is always served.
+func New(h *handlers.Handlers, tokens *oidc.TokenEndpoint, provider *oidc.Provider, limiter ratelimit.Limiter, cfg *config.Config) *Server {
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -33,6 +43,9 @@ func New(h *handlers.Handlers, cfg *config.Config) *Server {
 	s := &Server{
 		router:  router,
 		handler: h,
+		tokens:  tokens,
+		oidc:    provider,
+		limiter: limiter,
 		config:  cfg,
 		logger:  logging.NewLoggerV2("server"),
 	}
@@ -67,6 +80,14 @@ func (s *Server) setupMiddleware() {
 	// Logging middleware
 	s.router.Use(s.loggingMiddleware())
 
+	// Client IP for audit events
+	s.router.Use(s.auditMiddleware())
+
+	// Metrics middleware
+	if s.config.Features.EnableMetrics {
+		s.router.Use(s.metricsMiddleware())
+	}
+
 	// CORS middleware (if needed)
 	s.router.Use(s.corsMiddleware())
 }
@@ -96,6 +117,35 @@ func (s *Server) loggingMiddleware() gin.HandlerFunc {
 	}
 }
 
+// auditMiddleware makes the resolved client IP available to audit events
+// recorded further down the stack.
+func (s *Server) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithClientIP(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	}
+}
+
+func (s *Server) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		// Label by route template rather than raw path to bound cardinality
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		metrics.HTTPRequestDuration.WithLabelValues(
+			c.Request.Method,
+			route,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
+
 func (s *Server) corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -112,13 +162,37 @@ func (s *Server) corsMiddleware() gin.HandlerFunc {
 }
 
 func (s *Server) setupRoutes() {
-	// Health check endpoints (no auth required)
+	// Health check endpoints. Dependency errors are only shown to admins
 	s.router.GET("/health", s.handler.Health)
-	s.router.GET("/health/detailed", s.handler.HealthDetailed)
+	s.router.GET("/health/detailed", s.handler.AuthMiddleware(),
+		s.handler.Authorize(handlers.AccessRule{Any: auth.PermHealthRead}), s.handler.HealthDetailed)
 	s.router.GET("/ready", s.handler.Ready)
 	s.router.GET("/live", s.handler.Live)
 	s.router.GET("/metrics", s.handler.Metrics)
 
+	// Public signing keys for downstream token verification
+	s.router.GET("/.well-known/jwks.json", s.handler.JWKS)
+
+	// OAuth 2.0 token, introspection and revocation endpoints, through which
+	// other Acme Shop services get client_credentials tokens for the internal
+	// routes and gateways check tokens
+	s.tokens.RegisterRoutes(s.router, s.rateLimitMiddleware(s.oauthRateLimits()...))
+
+	// OpenID Connect provider for other applications
+	if s.oidc != nil {
+		s.oidc.RegisterRoutes(s.router, s.rateLimitMiddleware(s.oauthRateLimits()...))
+	}
+
+	// Internal routes for other Acme Shop services, authorized by the scopes
+	// of their client_credentials tokens rather than by role
+	internal := s.router.Group("/internal")
+	internal.Use(s.handler.ServiceAuthMiddleware())
+	{
+		internal.GET("/users", s.handler.RequireScope(auth.PermUsersList), s.handler.ListUsers)
+		internal.GET("/users/:id", s.handler.RequireScope(auth.PermUsersReadAny), s.handler.GetUser)
+		internal.POST("/sso/verify", s.handler.RequireScope(auth.PermSSOVerify), s.handler.VerifySSOAssertion)
+	}
+
 	// Debug endpoint (should be disabled in production)
 	if s.config.Features.EnableDebugMode {
 		s.router.GET("/debug/info", s.handler.DebugInfo)
@@ -130,11 +204,11 @@ func (s *Server) setupRoutes() {
 		v1 := s.router.Group("/api/v1")
 		{
 			// Auth routes
-			v1.POST("/auth/login", s.handler.LoginV1)
+			v1.POST("/auth/login", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.LoginV1)
 
 			// User routes (protected)
 			v1Protected := v1.Group("")
-			v1Protected.Use(s.handler.AuthMiddlewareV1())
+			v1Protected.Use(s.handler.AuthMiddlewareV1(), s.rateLimitMiddleware(s.userRateLimits()...))
 			{
 				v1Protected.GET("/users", s.handler.ListUsersV1)
 				v1Protected.GET("/users/:id", s.handler.GetUserV1)
@@ -148,34 +222,104 @@ func (s *Server) setupRoutes() {
 		v2 := s.router.Group("/api/v2")
 		{
 			// Public auth routes
-			v2.POST("/auth/login", s.handler.Login)
+			v2.POST("/auth/login", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.Login)
 			v2.POST("/auth/refresh", s.handler.RefreshToken)
 			v2.POST("/auth/validate", s.handler.ValidateToken)
+			v2.POST("/auth/mfa/verify", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.VerifyMFA)
+			v2.POST("/auth/webauthn/login/begin", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.BeginPasskeyLogin)
+			v2.POST("/auth/webauthn/login/finish", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.FinishPasskeyLogin)
+			v2.GET("/auth/federated", s.handler.ListFederatedProviders)
+			v2.POST("/auth/federated/:provider/begin", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.BeginFederatedLogin)
+			v2.POST("/auth/federated/:provider/finish", s.rateLimitMiddleware(s.loginRateLimits()...), s.handler.FinishFederatedLogin)
+			v2.POST("/auth/password/forgot", s.rateLimitMiddleware(s.passwordResetRateLimits()...), s.handler.ForgotPassword)
+			v2.POST("/auth/password/reset", s.rateLimitMiddleware(s.passwordResetRateLimits()...), s.handler.ResetPassword)
+			v2.POST("/auth/email/resend", s.rateLimitMiddleware(s.passwordResetRateLimits()...), s.handler.ResendVerification)
+
+			// The signed token identifies the user, so verification works
+			// before the account can sign in
+			v2.POST("/users/me/email/verify", s.handler.VerifyEmail)
 
 			// Protected routes
 			v2Protected := v2.Group("")
-			v2Protected.Use(s.handler.AuthMiddleware())
-			{
-				// Auth management
-				v2Protected.POST("/auth/logout", s.handler.Logout)
-				v2Protected.POST("/auth/logout/all", s.handler.LogoutAll)
-				v2Protected.GET("/auth/sessions", s.handler.GetSessions)
-				v2Protected.DELETE("/auth/sessions/:id", s.handler.RevokeSession)
-
-				// User management
-				v2Protected.GET("/users", s.handler.ListUsers)
-				v2Protected.POST("/users", s.handler.CreateUser)
-				v2Protected.GET("/users/me", s.handler.GetUserProfile)
-				v2Protected.PUT("/users/me", s.handler.UpdateUserProfile)
-				v2Protected.POST("/users/me/password", s.handler.ChangePassword)
-				v2Protected.GET("/users/:id", s.handler.GetUser)
-				v2Protected.PUT("/users/:id", s.handler.UpdateUser)
-				v2Protected.DELETE("/users/:id", s.handler.DeleteUser)
+			v2Protected.Use(s.handler.AuthMiddleware(), s.rateLimitMiddleware(s.userRateLimits()...))
+			for _, r := range s.v2ProtectedRoutes() {
+				v2Protected.Handle(r.method, r.path, s.handler.Authorize(r.access), r.handler)
 			}
 		}
 	}
 }
 
+// route binds a handler to a method and path along with the access rule
+// callers must satisfy.
+type route struct {
+	method  string
+	path    string
+	access  handlers.AccessRule
+	handler gin.HandlerFunc
+}
+
+// v2ProtectedRoutes is the route→permission table for authenticated v2 routes.
+// Routes with a zero AccessRule only require a valid token.
+func (s *Server) v2ProtectedRoutes() []route {
+	h := s.handler
+	ownUser := handlers.OwnerFromParam("id")
+
+	return []route{
+		// Auth management
+		{http.MethodPost, "/auth/logout", handlers.AccessRule{MFAExempt: true}, h.Logout},
+		{http.MethodPost, "/auth/logout/all", handlers.AccessRule{MFAExempt: true}, h.LogoutAll},
+		{http.MethodGet, "/auth/sessions", handlers.AccessRule{}, h.GetSessions},
+		{http.MethodDelete, "/auth/sessions/:id", handlers.AccessRule{
+			Any: auth.PermSessionsRevokeAny, Self: auth.PermSessionsRevokeSelf, Owner: h.SessionOwner,
+		}, h.RevokeSession},
+		{http.MethodPost, "/auth/webauthn/register/begin", handlers.AccessRule{}, h.BeginPasskeyRegistration},
+		{http.MethodPost, "/auth/webauthn/register/finish", handlers.AccessRule{}, h.FinishPasskeyRegistration},
+		{http.MethodGet, "/auth/webauthn/credentials", handlers.AccessRule{}, h.ListPasskeys},
+		{http.MethodDelete, "/auth/webauthn/credentials/:id", handlers.AccessRule{}, h.DeletePasskey},
+
+		// User management
+		{http.MethodGet, "/users", handlers.AccessRule{Any: auth.PermUsersList}, h.ListUsers},
+		{http.MethodPost, "/users", handlers.AccessRule{Any: auth.PermUsersCreate}, h.CreateUser},
+		{http.MethodGet, "/users/me", handlers.AccessRule{}, h.GetUserProfile},
+		{http.MethodPut, "/users/me", handlers.AccessRule{}, h.UpdateUserProfile},
+		{http.MethodPost, "/users/me/password", handlers.AccessRule{}, h.ChangePassword},
+		{http.MethodGet, "/users/me/email", handlers.AccessRule{}, h.GetEmailStatus},
+		{http.MethodPut, "/users/me/email", handlers.AccessRule{}, h.ChangeEmail},
+		{http.MethodGet, "/users/me/api-keys", handlers.AccessRule{}, h.ListAPIKeys},
+		{http.MethodPost, "/users/me/api-keys", handlers.AccessRule{}, h.CreateAPIKey},
+		{http.MethodPost, "/users/me/api-keys/:id/rotate", handlers.AccessRule{}, h.RotateAPIKey},
+		{http.MethodDelete, "/users/me/api-keys/:id", handlers.AccessRule{}, h.RevokeAPIKey},
+		{http.MethodPost, "/users/me/federated/:provider/begin", handlers.AccessRule{}, h.BeginFederatedLink},
+		{http.MethodPost, "/users/me/federated/:provider/finish", handlers.AccessRule{}, h.FinishFederatedLink},
+
+		// MFA enrollment stays reachable for roles that must enroll
+		{http.MethodGet, "/users/me/mfa", handlers.AccessRule{MFAExempt: true}, h.GetMFAStatus},
+		{http.MethodPost, "/users/me/mfa/totp", handlers.AccessRule{MFAExempt: true}, h.BeginTOTPEnrollment},
+		{http.MethodPost, "/users/me/mfa/totp/confirm", handlers.AccessRule{MFAExempt: true}, h.ConfirmTOTPEnrollment},
+		{http.MethodDelete, "/users/me/mfa/totp", handlers.AccessRule{}, h.DisableTOTP},
+		{http.MethodPost, "/users/me/mfa/recovery-codes", handlers.AccessRule{}, h.RegenerateRecoveryCodes},
+
+		{http.MethodGet, "/users/:id", handlers.AccessRule{
+			Any: auth.PermUsersReadAny, Self: auth.PermUsersReadSelf, Owner: ownUser,
+		}, h.GetUser},
+		{http.MethodPut, "/users/:id", handlers.AccessRule{
+			Any: auth.PermUsersUpdateAny, Self: auth.PermUsersUpdateSelf, Owner: ownUser,
+		}, h.UpdateUser},
+		{http.MethodDelete, "/users/:id", handlers.AccessRule{
+			Any: auth.PermUsersDeleteAny, Self: auth.PermUsersDeleteSelf, Owner: ownUser,
+		}, h.DeleteUser},
+		{http.MethodPost, "/users/:id/unlock", handlers.AccessRule{Any: auth.PermUsersUnlock}, h.UnlockUser},
+
+		// Audit log
+		{http.MethodGet, "/audit", handlers.AccessRule{Any: auth.PermAuditRead}, h.ListAuditEvents},
+
+		// OpenID Connect clients
+		{http.MethodGet, "/oauth/clients", handlers.AccessRule{Any: auth.PermOAuthClientsManage}, h.ListOAuthClients},
+		{http.MethodPost, "/oauth/clients", handlers.AccessRule{Any: auth.PermOAuthClientsManage}, h.CreateOAuthClient},
+		{http.MethodDelete, "/oauth/clients/:id", handlers.AccessRule{Any: auth.PermOAuthClientsManage}, h.DeleteOAuthClient},
+	}
+}
+
 // Start starts the HTTP server.
 func (s *Server) Start() error {
 	s.logger.Info("starting server", logging.Fields{
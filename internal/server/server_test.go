@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/handlers"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ratelimit"
+)
+
+// testTokens validates tokens with the JWT service and records revocations.
+type testTokens struct {
+	jwt     *auth.JWTService
+	revoked []string
+}
+
+func (t *testTokens) ValidateAccessToken(ctx context.Context, token string) (*auth.JWTClaims, error) {
+	return t.jwt.ValidateToken(token)
+}
+
+func (t *testTokens) RevokeToken(ctx context.Context, clientID, token string) error {
+	t.revoked = append(t.revoked, token)
+	return nil
+}
+
+func TestOAuthEndpointsWithoutOIDC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	jwtService := auth.NewJWTService("test-secret", time.Hour)
+	tokens := &testTokens{jwt: jwtService}
+
+	clients := oidc.NewInMemoryClientStore()
+	secret, hash, err := auth.GenerateClientSecret()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	clients.CreateClient(context.Background(), &oidc.Client{
+		ID:         "orders-service",
+		Name:       "Orders Service",
+		SecretHash: hash,
+		Scopes:     []string{string(auth.PermUsersReadAny), string(auth.PermTokensIntrospect)},
+	})
+
+	cfg := &config.Config{}
+	h := handlers.NewHandlers(nil, nil, nil, nil, nil, nil, nil, nil, nil, cfg)
+	srv := New(h, oidc.NewTokenEndpoint(tokens, clients, jwtService), nil, ratelimit.NewInMemoryLimiter(), cfg)
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("orders-service", secret)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("/oauth/token", url.Values{"grant_type": {"client_credentials"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var token oidc.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	w = post("/oauth/token", url.Values{"grant_type": {"authorization_code"}, "code": {"abc"}})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unsupported_grant_type") {
+		t.Fatalf("expected unsupported_grant_type, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = post("/oauth/introspect", url.Values{"token": {token.AccessToken}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var introspection oidc.Introspection
+	if err := json.Unmarshal(w.Body.Bytes(), &introspection); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !introspection.Active || introspection.ClientID != "orders-service" {
+		t.Fatalf("expected active token for orders-service, got %+v", introspection)
+	}
+
+	w = post("/oauth/revoke", url.Values{"token": {token.AccessToken}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(tokens.revoked) != 1 {
+		t.Fatalf("expected 1 revocation, got %d", len(tokens.revoked))
+	}
+
+	// The OpenID Connect endpoints stay off
+	req, _ := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+const (
+	maxAPIKeyNameLen = 100
+
+	// apiKeyTouchInterval limits last_used_at writes for busy keys.
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService manages API keys and resolves them to their owners.
+type APIKeyService struct {
+	repo   *repository.PostgresUserStore
+	audit  *audit.Recorder
+	logger *logging.LoggerV2
+}
+
+// NewAPIKeyService creates a new API key service.
+func NewAPIKeyService(repo *repository.PostgresUserStore, auditLog *audit.Recorder) *APIKeyService {
+	return &APIKeyService{
+		repo:   repo,
+		audit:  auditLog,
+		logger: logging.NewLoggerV2("api-key-service"),
+	}
+}
+
+// Create issues a new API key for a user. Every scope must be a permission
+// the user's role already has.
+func (s *APIKeyService) Create(ctx context.Context, userID string, req *CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCreateAPIKeyRequest(req, user); err != nil {
+		return nil, err
+	}
+
+	generated, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &repository.APIKey{
+		ID:        ids.NewAPIKeyID(),
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    generated.Prefix,
+		KeyHash:   generated.Hash,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("API key created", logging.Fields{"user_id": userID, "key_id": key.ID})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionAPIKeyCreate,
+		ResourceType: audit.ResourceAPIKey,
+		ResourceID:   key.ID,
+		NewValue: map[string]interface{}{
+			"name":       key.Name,
+			"scopes":     key.Scopes,
+			"expires_at": key.ExpiresAt,
+		},
+	})
+
+	return &CreatedAPIKey{APIKey: key, Key: generated.Key}, nil
+}
+
+// List returns a user's active API keys.
+func (s *APIKeyService) List(ctx context.Context, userID string) ([]*repository.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+// Rotate replaces a key's secret and returns the new key. The old secret
+// stops working immediately.
+func (s *APIKeyService) Rotate(ctx context.Context, userID, id string) (*CreatedAPIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RotateAPIKey(ctx, userID, id, generated.Prefix, generated.Hash); err != nil {
+		return nil, err
+	}
+
+	oldPrefix := key.Prefix
+	key.Prefix = generated.Prefix
+	key.KeyHash = generated.Hash
+	key.LastUsedAt = nil
+
+	s.logger.Info("API key rotated", logging.Fields{"user_id": userID, "key_id": id})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionAPIKeyRotate,
+		ResourceType: audit.ResourceAPIKey,
+		ResourceID:   id,
+		OldValue:     map[string]interface{}{"prefix": oldPrefix},
+		NewValue:     map[string]interface{}{"prefix": key.Prefix},
+	})
+
+	return &CreatedAPIKey{APIKey: key, Key: generated.Key}, nil
+}
+
+// Revoke deactivates one of a user's API keys.
+func (s *APIKeyService) Revoke(ctx context.Context, userID, id string) error {
+	if err := s.repo.RevokeAPIKey(ctx, userID, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("API key revoked", logging.Fields{"user_id": userID, "key_id": id})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionAPIKeyRevoke,
+		ResourceType: audit.ResourceAPIKey,
+		ResourceID:   id,
+	})
+
+	return nil
+}
+
+// Authenticate resolves a presented API key to the key and its owning user.
+// It returns auth.ErrAPIKeyInvalid for unknown, revoked or malformed keys and
+// auth.ErrAPIKeyExpired for expired ones.
+func (s *APIKeyService) Authenticate(ctx context.Context, presented string) (*repository.APIKey, *models.User, error) {
+	prefix, err := auth.ParseAPIKey(presented)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if err == errors.ErrNotFound {
+		return nil, nil, auth.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !auth.VerifyAPIKey(presented, key.KeyHash) {
+		s.logger.Warn("API key secret mismatch", logging.Fields{"key_id": key.ID})
+		return nil, nil, auth.ErrAPIKeyInvalid
+	}
+
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, nil, auth.ErrAPIKeyExpired
+	}
+
+	user, err := s.repo.GetByID(ctx, key.UserID)
+	if err == errors.ErrNotFound {
+		return nil, nil, auth.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, errors.ErrUserInactive
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now.UTC()); err != nil {
+			s.logger.Warn("failed to record API key use", logging.Fields{
+				"key_id": key.ID,
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	return key, user, nil
+}
+
+func validateCreateAPIKeyRequest(req *CreateAPIKeyRequest, user *models.User) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxAPIKeyNameLen {
+		return errors.ErrValidation
+	}
+
+	if len(req.Scopes) == 0 {
+		return errors.ErrValidation
+	}
+	for _, scope := range req.Scopes {
+		if !auth.HasPermission(user.Role, auth.Permission(scope)) {
+			return errors.ErrValidation
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return errors.ErrValidation
+	}
+
+	return nil
+}
+
+// CreateAPIKeyRequest represents a request to create an API key.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey is a new or rotated API key. Key is only returned here.
+type CreatedAPIKey struct {
+	*repository.APIKey
+	Key string `json:"key"`
+}
@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
 )
 
@@ -15,8 +19,18 @@ import (
 type AuthService struct {
 	repo            *repository.PostgresUserStore
 	passwordService *auth.PasswordService
+	passwordRules   *passwordRules
 	jwtService      *auth.JWTService
 	sessionService  *auth.SessionService
+	refreshTokens   *auth.RefreshTokenService
+	tokenDenylist   auth.TokenDenylist
+	lockout         *auth.LockoutService
+	passwordResets  *auth.PasswordResetService
+	mfa             *MFAService
+	mfaChallenges   *auth.MFAChallengeService
+	webauthn        *auth.WebAuthn
+	notifier        notify.Sender
+	audit           *audit.Recorder
 	config          *config.Config
 	logger          *logging.LoggerV2
 }
@@ -25,15 +39,35 @@ type AuthService struct {
 func NewAuthService(
 	repo *repository.PostgresUserStore,
 	passwordService *auth.PasswordService,
+	passwordPolicy *auth.PasswordPolicy,
 	jwtService *auth.JWTService,
 	sessionService *auth.SessionService,
+	refreshTokens *auth.RefreshTokenService,
+	tokenDenylist auth.TokenDenylist,
+	lockout *auth.LockoutService,
+	passwordResets *auth.PasswordResetService,
+	mfa *MFAService,
+	mfaChallenges *auth.MFAChallengeService,
+	webauthn *auth.WebAuthn,
+	notifier notify.Sender,
+	auditLog *audit.Recorder,
 	cfg *config.Config,
 ) *AuthService {
 	return &AuthService{
 		repo:            repo,
 		passwordService: passwordService,
+		passwordRules:   newPasswordRules(passwordPolicy, passwordService, repo),
 		jwtService:      jwtService,
 		sessionService:  sessionService,
+		refreshTokens:   refreshTokens,
+		tokenDenylist:   tokenDenylist,
+		lockout:         lockout,
+		passwordResets:  passwordResets,
+		mfa:             mfa,
+		mfaChallenges:   mfaChallenges,
+		webauthn:        webauthn,
+		notifier:        notifier,
+		audit:           auditLog,
 		config:          cfg,
 		logger:          logging.NewLoggerV2("auth-service"),
 	}
@@ -45,12 +79,26 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 		"email": req.Email,
 	})
 
+	// Refuse locked accounts before touching the password so a locked
+	// account cannot be used as a guessing oracle
+	if err := s.lockout.Check(ctx, req.Email, req.IPAddress); err != nil {
+		s.logger.Warn("login refused", logging.Fields{
+			"email":      req.Email,
+			"ip_address": req.IPAddress,
+			"reason":     err.Error(),
+		})
+		metrics.LoginAttempts.WithLabelValues("v2", lockoutReason(err)).Inc()
+		return nil, err
+	}
+
 	user, err := s.repo.GetByEmail(ctx, req.Email)
 	if err != nil {
 		if err == errors.ErrNotFound {
 			s.logger.Warn("login failed - user not found", logging.Fields{
 				"email": req.Email,
 			})
+			s.recordLoginFailure(ctx, req.Email, req.IPAddress)
+			metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginUnknownUser).Inc()
 			return nil, errors.ErrInvalidCredentials
 		}
 		return nil, err
@@ -61,6 +109,8 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 		s.logger.Warn("login failed - user inactive", logging.Fields{
 			"user_id": user.ID,
 		})
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginInactive)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInactive).Inc()
 		return nil, errors.ErrUserInactive
 	}
 
@@ -76,35 +126,181 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 		s.logger.Warn("login failed - invalid password", logging.Fields{
 			"user_id": user.ID,
 		})
+		s.recordLoginFailure(ctx, req.Email, req.IPAddress)
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginInvalidPassword)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInvalidPassword).Inc()
 		return nil, errors.ErrInvalidCredentials
 	}
 
-	// Migrate password hash if needed
+	// Rehash legacy hashes and hashes weaker than the current policy
 	if needsMigration && s.config.Features.EnablePasswordMigration {
 		s.logger.Info("migrating password hash", logging.Fields{
 			"user_id": user.ID,
+			"from":    s.passwordService.DetectHashType(hash),
+			"to":      s.passwordService.Algorithm(),
 		})
 		newHash, err := s.passwordService.MigratePasswordHash(req.Password)
 		if err == nil {
-			s.repo.UpdatePasswordHash(ctx, user.ID, newHash)
+			s.repo.UpdatePasswordHash(ctx, user.ID, newHash, s.passwordService.Algorithm())
+		}
+	}
+
+	// Passwords that have appeared in a breach since they were set still
+	// work, but the user is flagged and told to change it
+	passwordBreached := s.passwordRules.policy.Breached(req.Password)
+	if passwordBreached {
+		s.logger.Warn("login with breached password", logging.Fields{"user_id": user.ID})
+		if err := s.repo.MarkPasswordBreached(ctx, user.ID); err != nil {
+			s.logger.Error("failed to flag breached password", logging.Fields{
+				"user_id": user.ID,
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	// Checked only after the password so the response does not reveal
+	// verification status to callers who do not know it
+	if err := s.requireVerifiedEmail(ctx, user.ID); err != nil {
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginUnverified)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginUnverified).Inc()
+		return nil, err
+	}
+
+	mfaEnabled, err := s.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		// Failures are only reset once the second factor succeeds, so
+		// repeated password logins cannot be used to reset the lockout
+		// counter between code guesses
+		resp, err := s.beginMFAChallenge(ctx, user, req.IPAddress, req.UserAgent, []string{auth.AuthMethodPassword})
+		if err != nil {
+			return nil, err
+		}
+		resp.PasswordBreached = passwordBreached
+		return resp, nil
+	}
+
+	resp, err := s.completeLogin(ctx, user, req.IPAddress, req.UserAgent, req.DeviceID, []string{auth.AuthMethodPassword})
+	if err != nil {
+		return nil, err
+	}
+	resp.PasswordBreached = passwordBreached
+	return resp, nil
+}
+
+// VerifyMFA completes a login that is waiting for its second factor. The
+// code may be a TOTP code or an unused recovery code.
+func (s *AuthService) VerifyMFA(ctx context.Context, req *VerifyMFARequest) (*LoginResponse, error) {
+	challenge, err := s.mfaChallenges.Get(ctx, req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.lockout.Check(ctx, challenge.Email, req.IPAddress); err != nil {
+		metrics.LoginAttempts.WithLabelValues("v2", lockoutReason(err)).Inc()
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInactive).Inc()
+		return nil, errors.ErrUserInactive
+	}
+
+	methods, err := s.mfa.VerifyCode(ctx, user.ID, req.Code)
+	if err == auth.ErrInvalidMFACode {
+		s.logger.Warn("login failed - invalid MFA code", logging.Fields{
+			"user_id": user.ID,
+		})
+		if failErr := s.mfaChallenges.Fail(ctx, req.MFAToken); failErr != nil {
+			s.logger.Error("failed to record MFA attempt", logging.Fields{
+				"user_id": user.ID,
+				"error":   failErr.Error(),
+			})
 		}
+		s.recordLoginFailure(ctx, user.Email, req.IPAddress)
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginInvalidMFACode)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInvalidMFACode).Inc()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	if err := s.mfaChallenges.Complete(ctx, req.MFAToken); err != nil {
+		return nil, err
+	}
+
+	authMethods := challenge.AuthMethods
+	if len(authMethods) == 0 {
+		authMethods = []string{auth.AuthMethodPassword}
+	}
+	authMethods = append(append(authMethods, methods...), auth.AuthMethodMFA)
+
+	return s.completeLogin(ctx, user, req.IPAddress, req.UserAgent, req.DeviceID, authMethods)
+}
+
+// beginMFAChallenge issues the token a client exchanges, together with a
+// second factor, for a session. firstFactor records how the user has
+// authenticated so far.
+func (s *AuthService) beginMFAChallenge(ctx context.Context, user *models.User, ipAddress, userAgent string, firstFactor []string) (*LoginResponse, error) {
+	token, expiresAt, err := s.mfaChallenges.Issue(ctx, &auth.MFAChallenge{
+		UserID:      user.ID,
+		Email:       user.Email,
+		IPAddress:   ipAddress,
+		UserAgent:   userAgent,
+		AuthMethods: firstFactor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("login awaiting MFA", logging.Fields{"user_id": user.ID})
+	metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginMFAPending).Inc()
+
+	return &LoginResponse{
+		MFARequired:  true,
+		MFAToken:     token,
+		MFAExpiresAt: expiresAt,
+	}, nil
+}
+
+// completeLogin creates a session for an authenticated user and issues its
+// tokens. authMethods records how the user authenticated and deviceID the
+// client they logged in from.
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User, ipAddress, userAgent, deviceID string, authMethods []string) (*LoginResponse, error) {
+	s.recordLoginSuccess(ctx, user.Email)
+
 	// Create session
 	session, err := s.sessionService.Create(
 		ctx,
 		user.ID,
 		user.Email,
 		string(user.Role),
-		req.IPAddress,
-		req.UserAgent,
+		ipAddress,
+		userAgent,
+		deviceID,
+		authMethods,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	s.recordDevice(ctx, user, session)
+
 	// Generate JWT token
-	token, err := s.jwtService.GenerateToken(user, session.ID)
+	token, err := s.jwtService.GenerateSessionToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issue the first refresh token of the session's family
+	refreshToken, refreshExpiresAt, err := s.refreshTokens.Issue(ctx, session)
 	if err != nil {
 		return nil, err
 	}
@@ -116,27 +312,47 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 		"user_id":    user.ID,
 		"session_id": session.ID,
 	})
+	metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginSuccess).Inc()
+
+	s.audit.Record(ctx, &audit.Event{
+		ActorID:      user.ID,
+		Action:       audit.ActionLogin,
+		ResourceType: audit.ResourceSession,
+		ResourceID:   session.ID,
+		NewValue:     map[string]interface{}{"auth_methods": authMethods},
+		IPAddress:    ipAddress,
+	})
 
 	return &LoginResponse{
-		Token:     token,
-		User:      user,
-		SessionID: session.ID,
-		ExpiresAt: session.ExpiresAt,
+		Token:            token,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		User:             user,
+		SessionID:        session.ID,
+		ExpiresAt:        session.ExpiresAt,
 	}, nil
 }
 
 // LoginV1 authenticates a user using the legacy API.
 // Deprecated: Use Login instead.
 // TODO(TEAM-API): Remove after v1 API deprecation
-func (s *AuthService) LoginV1(ctx context.Context, email, password string) (*LoginResponseV1, error) {
+func (s *AuthService) LoginV1(ctx context.Context, email, password, ipAddress, userAgent string) (*LoginResponseV1, error) {
 	logging.Infof("LoginV1 called for email: %s", email)
 
 	if !s.config.Features.EnableV1API {
 		return nil, errors.ErrDeprecatedAPI
 	}
 
+	// v1 logins are only locked out per account
+	if err := s.lockout.Check(ctx, email, ""); err != nil {
+		metrics.LoginAttempts.WithLabelValues("v1", lockoutReason(err)).Inc()
+		return nil, err
+	}
+
 	user, err := s.repo.GetByEmail(ctx, email)
 	if err != nil {
+		s.recordLoginFailure(ctx, email, "")
+		metrics.LoginAttempts.WithLabelValues("v1", metrics.LoginUnknownUser).Inc()
 		return nil, errors.ErrInvalidCredentials
 	}
 
@@ -147,16 +363,58 @@ func (s *AuthService) LoginV1(ctx context.Context, email, password string) (*Log
 
 	valid, _ := s.passwordService.CheckPassword(password, hash)
 	if !valid {
+		s.recordLoginFailure(ctx, email, "")
+		s.auditLoginFailure(ctx, user.ID, "", metrics.LoginInvalidPassword)
+		metrics.LoginAttempts.WithLabelValues("v1", metrics.LoginInvalidPassword).Inc()
 		return nil, errors.ErrInvalidCredentials
 	}
 
+	if err := s.requireVerifiedEmail(ctx, user.ID); err != nil {
+		s.auditLoginFailure(ctx, user.ID, "", metrics.LoginUnverified)
+		metrics.LoginAttempts.WithLabelValues("v1", metrics.LoginUnverified).Inc()
+		return nil, err
+	}
+
+	// The v1 API has no second-factor step, so accounts that use or must use
+	// MFA have to sign in through v2
+	if err := s.requireNoMFA(ctx, user); err != nil {
+		s.auditLoginFailure(ctx, user.ID, "", metrics.LoginMFARequired)
+		metrics.LoginAttempts.WithLabelValues("v1", metrics.LoginMFARequired).Inc()
+		return nil, err
+	}
+
+	s.recordLoginSuccess(ctx, email)
+
+	// Bind the token to a session so that logging out revokes it
+	session, err := s.sessionService.Create(
+		ctx,
+		user.ID,
+		user.Email,
+		string(user.Role),
+		ipAddress,
+		userAgent,
+		"",
+		[]string{auth.AuthMethodPassword},
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate legacy token
-	token, err := s.jwtService.GenerateTokenV1(user.ID, user.Email)
+	token, err := s.jwtService.GenerateSessionTokenV1(user.ID, user.Email, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	s.repo.UpdateLastLogin(ctx, user.ID)
+	metrics.LoginAttempts.WithLabelValues("v1", metrics.LoginSuccess).Inc()
+
+	s.audit.Record(ctx, &audit.Event{
+		ActorID:      user.ID,
+		Action:       audit.ActionLogin,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   user.ID,
+	})
 
 	return &LoginResponseV1{
 		Token: token,
@@ -164,36 +422,187 @@ func (s *AuthService) LoginV1(ctx context.Context, email, password string) (*Log
 	}, nil
 }
 
+// UnlockAccount lifts a login lockout and clears the account's failure history.
+func (s *AuthService) UnlockAccount(ctx context.Context, userID string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("unlock account", logging.Fields{"user_id": userID})
+
+	if err := s.lockout.Unlock(ctx, user.Email); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionUserUnlock,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+	})
+
+	return nil
+}
+
+// requireVerifiedEmail returns auth.ErrEmailNotVerified if login requires a
+// verified email address and the user has not verified theirs.
+func (s *AuthService) requireVerifiedEmail(ctx context.Context, userID string) error {
+	if !s.config.Features.RequireEmailVerification {
+		return nil
+	}
+
+	verification, err := s.repo.GetEmailVerification(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !verification.Verified {
+		s.logger.Warn("login refused - email not verified", logging.Fields{"user_id": userID})
+		return auth.ErrEmailNotVerified
+	}
+	return nil
+}
+
+// requireNoMFA returns auth.ErrMFARequired if the user has MFA enabled or
+// their role requires it.
+func (s *AuthService) requireNoMFA(ctx context.Context, user *models.User) error {
+	if s.config.MFA.RoleRequiresMFA(string(user.Role)) {
+		return auth.ErrMFARequired
+	}
+
+	enabled, err := s.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return auth.ErrMFARequired
+	}
+	return nil
+}
+
+// auditLoginFailure records a failed login against a known account. Attempts
+// on unknown emails are only counted, so the log never stores guessed
+// addresses.
+func (s *AuthService) auditLoginFailure(ctx context.Context, userID, ipAddress, reason string) {
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionLoginFailed,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		NewValue:     map[string]interface{}{"reason": reason},
+		IPAddress:    ipAddress,
+	})
+}
+
+// recordLoginFailure counts a failed login towards lockout. Tracking errors
+// are logged rather than returned so they never change the login response.
+func (s *AuthService) recordLoginFailure(ctx context.Context, email, ipAddress string) {
+	if err := s.lockout.RecordFailure(ctx, email, ipAddress); err != nil {
+		s.logger.Error("failed to record login failure", logging.Fields{
+			"email": email,
+			"error": err.Error(),
+		})
+	}
+}
+
+// lockoutReason maps a lockout check error to a login metrics reason.
+func lockoutReason(err error) string {
+	switch err {
+	case auth.ErrAccountLocked:
+		return metrics.LoginLocked
+	case auth.ErrTooManyAttempts:
+		return metrics.LoginThrottled
+	default:
+		return metrics.LoginError
+	}
+}
+
+func (s *AuthService) recordLoginSuccess(ctx context.Context, email string) {
+	if err := s.lockout.RecordSuccess(ctx, email); err != nil {
+		s.logger.Error("failed to reset login failures", logging.Fields{
+			"email": email,
+			"error": err.Error(),
+		})
+	}
+}
+
 // Logout invalidates a user's session.
 func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
 	s.logger.Info("logout", logging.Fields{"session_id": sessionID})
 
-	return s.sessionService.Delete(ctx, sessionID)
+	if err := s.sessionService.Delete(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionLogout,
+		ResourceType: audit.ResourceSession,
+		ResourceID:   sessionID,
+	})
+
+	return nil
 }
 
 // LogoutAll invalidates all sessions for a user.
 func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
 	s.logger.Info("logout all", logging.Fields{"user_id": userID})
 
-	return s.sessionService.DeleteAllForUser(ctx, userID)
+	if err := s.sessionService.DeleteAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionLogoutAll,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+	})
+
+	return nil
 }
 
-// ValidateToken validates a JWT token and returns the claims.
+// ValidateToken validates a JWT token and returns the claims. Revoked
+// tokens return ErrTokenRevoked, and tokens whose session has ended return
+// the session's error.
 func (s *AuthService) ValidateToken(ctx context.Context, token string) (*auth.JWTClaims, error) {
 	claims, err := s.jwtService.ValidateToken(token)
 	if err != nil {
 		return nil, err
 	}
 
-	// Validate session is still active
-	if claims.SessionID != "" {
-		_, err := s.sessionService.Get(ctx, claims.SessionID)
+	if err := s.checkTokenActive(ctx, claims.ID, claims.SessionID); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// checkTokenActive rejects a validly signed token that has been revoked or
+// whose session has ended, and counts its use as activity on the session.
+func (s *AuthService) checkTokenActive(ctx context.Context, tokenID, sessionID string) error {
+	// Tokens issued before token IDs were added cannot be revoked singly
+	if tokenID != "" {
+		revoked, err := s.tokenDenylist.Contains(ctx, tokenID)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if revoked {
+			return auth.ErrTokenRevoked
 		}
 	}
 
-	return claims, nil
+	// Validate session is still active, and count this as activity on it
+	if sessionID != "" {
+		session, err := s.sessionService.Get(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if err := s.sessionService.Touch(ctx, session); err != nil {
+			s.logger.Warn("failed to record session activity", logging.Fields{
+				"session_id": session.ID,
+				"error":      err.Error(),
+			})
+		}
+	}
+
+	return nil
 }
 
 // ValidateTokenV1 validates a legacy JWT token.
@@ -206,53 +615,125 @@ func (s *AuthService) ValidateTokenV1(ctx context.Context, token string) (*auth.
 		return nil, errors.ErrDeprecatedAPI
 	}
 
-	return s.jwtService.ValidateTokenV1(token)
-}
+	claims, err := s.jwtService.ValidateTokenV1(token)
+	if err != nil {
+		return nil, err
+	}
 
-// RefreshToken refreshes a JWT token.
-func (s *AuthService) RefreshToken(ctx context.Context, token string) (*RefreshTokenResponse, error) {
-	claims, err := s.jwtService.ValidateToken(token)
-	if err != nil && err != auth.ErrExpiredToken {
+	if err := s.checkTokenActive(ctx, claims.ID, claims.SessionID); err != nil {
 		return nil, err
 	}
 
-	// Validate session
-	session, err := s.sessionService.Get(ctx, claims.SessionID)
+	return claims, nil
+}
+
+// RefreshToken redeems an opaque refresh token for a new access token and
+// rotates the refresh token. Presenting a token that was already rotated
+// revokes the whole session, since only a stolen copy could be replayed.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*RefreshTokenResponse, error) {
+	resp, _, err := s.refresh(ctx, refreshToken, "")
+	return resp, err
+}
+
+// refresh rotates a refresh token issued to clientID, or to a first-party
+// login if clientID is empty, and returns the session it belongs to.
+func (s *AuthService) refresh(ctx context.Context, refreshToken, clientID string) (*RefreshTokenResponse, *auth.Session, error) {
+	record, err := s.refreshTokens.Redeem(ctx, refreshToken)
+	if err == auth.ErrRefreshTokenReused {
+		s.logger.Warn("revoking session after refresh token reuse", logging.Fields{
+			"session_id": record.SessionID,
+			"user_id":    record.UserID,
+		})
+		if revokeErr := s.sessionService.Revoke(ctx, record.SessionID); revokeErr != nil {
+			s.logger.Warn("failed to revoke session", logging.Fields{
+				"session_id": record.SessionID,
+				"error":      revokeErr.Error(),
+			})
+		}
+		return nil, nil, err
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Refresh session
-	if err := s.sessionService.Refresh(ctx, session.ID); err != nil {
-		return nil, err
+	// Validate session. A client's refresh token only works for that
+	// client, and first-party tokens only for first-party logins.
+	session, err := s.sessionService.Get(ctx, record.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.ClientID != clientID {
+		s.logger.Warn("refresh token presented by another client", logging.Fields{
+			"session_id": session.ID,
+			"client_id":  clientID,
+		})
+		return nil, nil, auth.ErrInvalidToken
+	}
+
+	// Extend the session's idle timeout; its absolute lifetime still applies
+	if err := s.sessionService.Touch(ctx, session); err != nil {
+		return nil, nil, err
 	}
 
 	// Get user for new token
-	user, err := s.repo.GetByID(ctx, claims.UserID)
+	user, err := s.repo.GetByID(ctx, record.UserID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if !user.Active {
+		return nil, nil, errors.ErrUserInactive
 	}
 
-	// Generate new token
-	newToken, err := s.jwtService.GenerateToken(user, session.ID)
+	// Generate new token, keeping the session's authentication methods
+	newToken, err := s.jwtService.GenerateSessionToken(user, session)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Rotate the refresh token
+	newRefreshToken, refreshExpiresAt, err := s.refreshTokens.Issue(ctx, session)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	return &RefreshTokenResponse{
-		Token:     newToken,
-		ExpiresAt: session.ExpiresAt,
-	}, nil
+		Token:            newToken,
+		RefreshToken:     newRefreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		ExpiresAt:        session.ExpiresAt,
+	}, session, nil
+}
+
+// JWKS returns the public keys used to verify issued tokens.
+func (s *AuthService) JWKS() auth.JWKSet {
+	return s.jwtService.JWKS()
 }
 
-// GetSessions returns all active sessions for a user.
-func (s *AuthService) GetSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
-	return s.sessionService.ListForUser(ctx, userID)
+// GetSession returns a session by ID.
+func (s *AuthService) GetSession(ctx context.Context, sessionID string) (*auth.Session, error) {
+	return s.sessionService.Get(ctx, sessionID)
 }
 
 // RevokeSession revokes a specific session.
 func (s *AuthService) RevokeSession(ctx context.Context, sessionID string) error {
-	return s.sessionService.Revoke(ctx, sessionID)
+	session, err := s.sessionService.Get(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessionService.Revoke(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionSessionRevoke,
+		ResourceType: audit.ResourceSession,
+		ResourceID:   sessionID,
+		OldValue:     map[string]interface{}{"user_id": session.UserID, "active": true},
+		NewValue:     map[string]interface{}{"active": false},
+	})
+
+	return nil
 }
 
 // LoginRequest represents a login request.
@@ -261,14 +742,35 @@ type LoginRequest struct {
 	Password  string `json:"password"`
 	IPAddress string `json:"-"`
 	UserAgent string `json:"-"`
+	DeviceID  string `json:"-"`
 }
 
-// LoginResponse represents a login response (v2 API).
+// LoginResponse represents a login response (v2 API). When MFARequired is
+// set, only the MFA fields are populated and the client must call VerifyMFA.
 type LoginResponse struct {
-	Token     string       `json:"token"`
-	User      *models.User `json:"user"`
-	SessionID string       `json:"session_id"`
-	ExpiresAt interface{}  `json:"expires_at"`
+	Token            string       `json:"token"`
+	RefreshToken     string       `json:"refresh_token"`
+	RefreshExpiresAt time.Time    `json:"refresh_expires_at"`
+	User             *models.User `json:"user"`
+	SessionID        string       `json:"session_id"`
+	ExpiresAt        interface{}  `json:"expires_at"`
+
+	MFARequired  bool      `json:"mfa_required"`
+	MFAToken     string    `json:"mfa_token,omitempty"`
+	MFAExpiresAt time.Time `json:"mfa_expires_at"`
+
+	// PasswordBreached is set when the password used to log in appears in
+	// the breached password corpus and should be changed.
+	PasswordBreached bool `json:"password_breached,omitempty"`
+}
+
+// VerifyMFARequest represents the second step of an MFA login.
+type VerifyMFARequest struct {
+	MFAToken  string `json:"mfa_token"`
+	Code      string `json:"code"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+	DeviceID  string `json:"-"`
 }
 
 // LoginResponseV1 represents a login response (v1 API).
@@ -280,6 +782,8 @@ type LoginResponseV1 struct {
 
 // RefreshTokenResponse represents a token refresh response.
 type RefreshTokenResponse struct {
-	Token     string      `json:"token"`
-	ExpiresAt interface{} `json:"expires_at"`
+	Token            string      `json:"token"`
+	RefreshToken     string      `json:"refresh_token"`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
+	ExpiresAt        interface{} `json:"expires_at"`
 }
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+// SessionInfo is a session as shown to its owner, with the device it was
+// created from.
+type SessionInfo struct {
+	*auth.Session
+
+	Device      auth.DeviceInfo `json:"device"`
+	DeviceLabel string          `json:"device_label"`
+
+	// Current marks the session making the request.
+	Current bool `json:"current"`
+
+	// DeviceFirstSeenAt and DeviceLastSeenAt are the first and latest
+	// logins from the session's device.
+	DeviceFirstSeenAt *time.Time `json:"device_first_seen_at,omitempty"`
+	DeviceLastSeenAt  *time.Time `json:"device_last_seen_at,omitempty"`
+}
+
+// GetSessions returns a user's active sessions, newest first.
+// currentSessionID marks the caller's own session.
+func (s *AuthService) GetSessions(ctx context.Context, userID, currentSessionID string) ([]*SessionInfo, error) {
+	sessions, err := s.sessionService.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make(map[string]*repository.Device)
+	known, err := s.repo.ListDevices(ctx, userID)
+	if err != nil {
+		// Sessions are still useful without first/last seen times
+		s.logger.Warn("failed to list devices", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+	for _, device := range known {
+		devices[device.ID] = device
+	}
+
+	infos := make([]*SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		device := auth.ParseUserAgent(session.UserAgent)
+		info := &SessionInfo{
+			Session:     session,
+			Device:      device,
+			DeviceLabel: device.Label(),
+			Current:     session.ID == currentSessionID,
+		}
+		if known, ok := devices[session.DeviceID]; ok {
+			info.DeviceFirstSeenAt = &known.FirstSeenAt
+			info.DeviceLastSeenAt = &known.LastSeenAt
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// recordDevice updates the first and last seen times of the device a session
+// was created from, and raises a security event when a user with other known
+// devices logs in from a new one. Tracking failures do not fail the login.
+func (s *AuthService) recordDevice(ctx context.Context, user *models.User, session *auth.Session) {
+	if session.DeviceID == "" {
+		return
+	}
+
+	info := auth.ParseUserAgent(session.UserAgent)
+	device := &repository.Device{
+		ID:            session.DeviceID,
+		UserID:        user.ID,
+		Browser:       info.Browser,
+		OS:            info.OS,
+		Type:          info.Type,
+		LastIPAddress: session.IPAddress,
+	}
+
+	isNew, err := s.repo.RecordDevice(ctx, device)
+	if err != nil {
+		s.logger.Warn("failed to record device", logging.Fields{
+			"user_id":   user.ID,
+			"device_id": device.ID,
+			"error":     err.Error(),
+		})
+		return
+	}
+	if !isNew {
+		return
+	}
+
+	// The first device we see for a user, including every user's first
+	// login after device tracking was introduced, is not worth an alert
+	devices, err := s.repo.ListDevices(ctx, user.ID)
+	if err != nil || len(devices) <= 1 {
+		return
+	}
+
+	s.logger.Info("login from new device", logging.Fields{
+		"user_id":    user.ID,
+		"session_id": session.ID,
+		"device_id":  device.ID,
+	})
+
+	s.audit.Record(ctx, &audit.Event{
+		ActorID:      user.ID,
+		Action:       audit.ActionNewDevice,
+		ResourceType: audit.ResourceDevice,
+		ResourceID:   device.ID,
+		NewValue: map[string]interface{}{
+			"session_id": session.ID,
+			"browser":    device.Browser,
+			"os":         device.OS,
+			"type":       device.Type,
+		},
+		IPAddress: session.IPAddress,
+	})
+
+	go s.sendNewDeviceAlert(context.WithoutCancel(ctx), user, device, info.Label())
+}
+
+func (s *AuthService) sendNewDeviceAlert(ctx context.Context, user *models.User, device *repository.Device, label string) {
+	_, err := s.notifier.Send(ctx, &models.SendNotificationRequest{
+		Type:       models.NotificationTypeEmail,
+		Priority:   models.NotificationPriorityHigh,
+		Recipient:  user.Email,
+		TemplateID: notify.TemplateNewDeviceLogin,
+		TemplateData: map[string]interface{}{
+			"first_name":   user.FirstName,
+			"device_label": label,
+			"ip_address":   device.LastIPAddress,
+			"logged_in_at": device.FirstSeenAt,
+		},
+	})
+	if err != nil {
+		s.logger.Error("failed to send new device alert", logging.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})
+	}
+}
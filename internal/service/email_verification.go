@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+// GetEmailVerification returns the verification state of a user's email.
+func (s *UserService) GetEmailVerification(ctx context.Context, id string) (*repository.EmailVerification, error) {
+	return s.repo.GetEmailVerification(ctx, id)
+}
+
+// VerifyEmail confirms the address a verification token was issued for. If
+// the token is for a pending email change, the change is applied. The token
+// identifies the user, so this works before the user can sign in.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*repository.EmailVerification, error) {
+	claims, err := s.emailTokens.Verify(token)
+	if err != nil {
+		s.logger.Warn("email verification token rejected", logging.Fields{"error": err.Error()})
+		return nil, err
+	}
+
+	current, err := s.repo.GetEmailVerification(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch claims.Email {
+	case current.PendingEmail:
+		if err := s.repo.ConfirmEmailChange(ctx, claims.UserID, claims.Email); err != nil {
+			return nil, err
+		}
+
+		s.logger.Info("email change confirmed", logging.Fields{"user_id": claims.UserID})
+		s.audit.Record(ctx, &audit.Event{
+			ActorID:      claims.UserID,
+			Action:       audit.ActionEmailChange,
+			ResourceType: audit.ResourceUser,
+			ResourceID:   claims.UserID,
+			OldValue:     map[string]interface{}{"email": current.Email},
+			NewValue:     map[string]interface{}{"email": claims.Email},
+		})
+
+		// The cached user still carries the old address
+		if s.config.Features.EnableUserCache {
+			if err := s.cache.Invalidate(ctx, claims.UserID); err != nil {
+				s.logger.Warn("failed to invalidate cache", logging.Fields{
+					"user_id": claims.UserID,
+					"error":   err.Error(),
+				})
+			}
+		}
+
+	case current.Email:
+		if current.Verified {
+			return current, nil
+		}
+		if err := s.repo.MarkEmailVerified(ctx, claims.UserID, claims.Email); err != nil {
+			return nil, err
+		}
+
+		s.logger.Info("email verified", logging.Fields{"user_id": claims.UserID})
+		s.audit.Record(ctx, &audit.Event{
+			ActorID:      claims.UserID,
+			Action:       audit.ActionEmailVerify,
+			ResourceType: audit.ResourceUser,
+			ResourceID:   claims.UserID,
+			NewValue:     map[string]interface{}{"email": claims.Email},
+		})
+
+	default:
+		// The address changed or the change was superseded after the link
+		// was sent
+		return nil, auth.ErrInvalidToken
+	}
+
+	return s.repo.GetEmailVerification(ctx, claims.UserID)
+}
+
+// ResendVerification sends a new verification link to the account registered
+// under email. Like ForgotPassword, it returns nil whether or not the account
+// exists or is already verified, and sends in the background.
+func (s *UserService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	current, err := s.repo.GetEmailVerification(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if current.Verified || !user.Active {
+		return nil
+	}
+
+	go s.sendVerificationEmail(context.WithoutCancel(ctx), user, user.Email)
+
+	return nil
+}
+
+// RequestEmailChange starts changing a user's address to newEmail. The
+// current password is required so a hijacked session cannot move the
+// account to an attacker's mailbox. The old address stays in effect until
+// the link sent to newEmail is confirmed, and is told about the request.
+func (s *UserService) RequestEmailChange(ctx context.Context, id, newEmail, password string) error {
+	newEmail = strings.TrimSpace(newEmail)
+	if !ValidateEmail(newEmail) {
+		return errors.ErrValidation
+	}
+
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		return errors.ErrValidation
+	}
+
+	currentHash, err := s.repo.GetPasswordHash(ctx, id)
+	if err != nil {
+		return err
+	}
+	if valid, _ := s.passwordService.CheckPassword(password, currentHash); !valid {
+		return auth.ErrPasswordMismatch
+	}
+
+	if _, err := s.repo.GetByEmail(ctx, newEmail); err == nil {
+		return errors.ErrAlreadyExists
+	} else if err != errors.ErrNotFound {
+		return err
+	}
+
+	if err := s.repo.SetPendingEmail(ctx, id, newEmail); err != nil {
+		return err
+	}
+
+	s.logger.Info("email change requested", logging.Fields{"user_id": id})
+
+	go s.sendVerificationEmail(context.WithoutCancel(ctx), user, newEmail)
+	go s.notify(context.WithoutCancel(ctx), user.Email, notify.TemplateEmailChangeRequested, map[string]interface{}{
+		"first_name": user.FirstName,
+		"new_email":  newEmail,
+	})
+
+	return nil
+}
+
+// sendVerificationEmail sends a verification link for email, which is either
+// the user's current address or a pending new one.
+func (s *UserService) sendVerificationEmail(ctx context.Context, user *models.User, email string) {
+	token, expiresAt, err := s.emailTokens.Issue(user.ID, email)
+	if err != nil {
+		s.logger.Error("failed to issue email verification token", logging.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	link, err := tokenLink(s.config.EmailVerification.URL, token)
+	if err != nil {
+		s.logger.Error("invalid email verification URL", logging.Fields{"error": err.Error()})
+		return
+	}
+
+	s.notify(ctx, email, notify.TemplateEmailVerification, map[string]interface{}{
+		"first_name": user.FirstName,
+		"verify_url": link,
+		"expires_at": expiresAt,
+	})
+}
+
+func (s *UserService) notify(ctx context.Context, recipient, templateID string, data map[string]interface{}) {
+	_, err := s.notifier.Send(ctx, &models.SendNotificationRequest{
+		Type:         models.NotificationTypeEmail,
+		Priority:     models.NotificationPriorityHigh,
+		Recipient:    recipient,
+		TemplateID:   templateID,
+		TemplateData: data,
+	})
+	if err != nil {
+		s.logger.Error("failed to send email", logging.Fields{
+			"template": templateID,
+			"error":    err.Error(),
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/federation"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+// FederationService signs users in through external identity providers,
+// linking provider accounts to local users and provisioning new users.
+type FederationService struct {
+	federation  *federation.Federation
+	repo        *repository.PostgresUserStore
+	authService *AuthService
+	userService *UserService
+	audit       *audit.Recorder
+	logger      *logging.LoggerV2
+}
+
+// NewFederationService creates a new federated login service.
+func NewFederationService(
+	fed *federation.Federation,
+	repo *repository.PostgresUserStore,
+	authService *AuthService,
+	userService *UserService,
+	auditLog *audit.Recorder,
+) *FederationService {
+	return &FederationService{
+		federation:  fed,
+		repo:        repo,
+		authService: authService,
+		userService: userService,
+		audit:       auditLog,
+		logger:      logging.NewLoggerV2("federation-service"),
+	}
+}
+
+// Providers lists the identity providers users can sign in with.
+func (s *FederationService) Providers() []*federation.Provider {
+	return s.federation.Providers()
+}
+
+// BeginLogin starts a sign-in with a provider.
+func (s *FederationService) BeginLogin(ctx context.Context, providerID string) (*federation.Authorization, error) {
+	return s.federation.Begin(ctx, providerID)
+}
+
+// FinishLogin completes a sign-in with the code and state the provider
+// returned, producing the same session and tokens as Login. Users with MFA
+// enabled must still complete VerifyMFA.
+func (s *FederationService) FinishLogin(ctx context.Context, req *FederatedLoginRequest) (*LoginResponse, error) {
+	identity, err := s.federation.Finish(ctx, req.Provider, req.Code, req.State)
+	if err != nil {
+		if err != federation.ErrProviderUnavailable {
+			metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginFederatedFailed).Inc()
+		}
+		return nil, err
+	}
+
+	user, err := s.resolveUser(ctx, identity)
+	if err != nil {
+		s.logger.Warn("federated login failed", logging.Fields{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+			"reason":   err.Error(),
+		})
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginUnlinked).Inc()
+		return nil, err
+	}
+
+	if !user.Active {
+		s.authService.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginInactive)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInactive).Inc()
+		return nil, errors.ErrUserInactive
+	}
+
+	if err := s.repo.RecordFederatedLogin(ctx, identity.Provider, identity.Subject); err != nil {
+		s.logger.Warn("failed to record federated login", logging.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})
+	}
+
+	authMethods := []string{auth.AuthMethodFederated}
+
+	mfaEnabled, err := s.authService.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return s.authService.beginMFAChallenge(ctx, user, req.IPAddress, req.UserAgent, authMethods)
+	}
+
+	return s.authService.completeLogin(ctx, user, req.IPAddress, req.UserAgent, req.DeviceID, authMethods)
+}
+
+// BeginLink starts linking a provider to the signed-in user's account.
+func (s *FederationService) BeginLink(ctx context.Context, userID, providerID string) (*federation.Authorization, error) {
+	return s.federation.BeginLink(ctx, providerID, userID)
+}
+
+// FinishLink completes linking a provider to the signed-in user's account.
+// Because the user proved control of both accounts, the identity's email
+// need not match theirs, but it must be in the provider's allowed domains.
+func (s *FederationService) FinishLink(ctx context.Context, userID string, req *FederatedLoginRequest) error {
+	identity, err := s.federation.FinishLink(ctx, req.Provider, userID, req.Code, req.State)
+	if err != nil {
+		return err
+	}
+
+	link, err := s.repo.GetFederatedIdentity(ctx, identity.Provider, identity.Subject)
+	switch {
+	case err == nil && link.UserID == userID:
+		return nil
+	case err == nil:
+		return auth.ErrFederatedIdentityInUse
+	case err != errors.ErrNotFound:
+		return err
+	}
+
+	provider, err := s.federation.Provider(identity.Provider)
+	if err != nil {
+		return err
+	}
+	if identity.Email != "" && !provider.AllowsEmail(identity.Email) {
+		return auth.ErrFederatedDomainNotAllowed
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.link(ctx, user, identity)
+}
+
+// resolveUser finds the local user for an identity. Identities already
+// linked sign in to their user; otherwise the identity is linked to the
+// account with the same verified email address if that is safe to do
+// without the user's involvement, or a new account is provisioned if the
+// provider allows it.
+func (s *FederationService) resolveUser(ctx context.Context, identity *federation.Identity) (*models.User, error) {
+	link, err := s.repo.GetFederatedIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		return s.repo.GetByID(ctx, link.UserID)
+	}
+	if err != errors.ErrNotFound {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, auth.ErrFederatedEmailUnverified
+	}
+
+	provider, err := s.federation.Provider(identity.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.AllowsEmail(identity.Email) {
+		return nil, auth.ErrFederatedDomainNotAllowed
+	}
+
+	user, err := s.repo.GetByEmail(ctx, identity.Email)
+	switch err {
+	case nil:
+		if err := s.checkAutoLink(ctx, user); err != nil {
+			return nil, err
+		}
+	case errors.ErrNotFound:
+		user, err = s.provision(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.link(ctx, user, identity); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkAutoLink decides whether an identity with the same email as user may
+// be linked to it without the user signing in. Anything an attacker could
+// gain by asserting the address at a provider is refused: privileged
+// accounts, and accounts already protected by a password or MFA, must be
+// linked with BeginLink instead.
+func (s *FederationService) checkAutoLink(ctx context.Context, user *models.User) error {
+	// Linking to an unverified account would hand it to whoever
+	// registered the address first
+	verification, err := s.repo.GetEmailVerification(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if !verification.Verified {
+		return auth.ErrFederatedAccountUnverified
+	}
+
+	if user.Role != models.RoleCustomer && user.Role != models.RoleVendor {
+		return auth.ErrFederatedLinkRequired
+	}
+
+	mfaEnabled, err := s.authService.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	hash, err := s.repo.GetPasswordHash(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if mfaEnabled || hash != "" {
+		return auth.ErrFederatedLinkRequired
+	}
+
+	return nil
+}
+
+// provision creates an account for an identity through CreateUser, with the
+// provider's default role. The account gets a random password the user
+// never sees; they can set one with a password reset.
+func (s *FederationService) provision(ctx context.Context, identity *federation.Identity) (*models.User, error) {
+	provider, err := s.federation.Provider(identity.Provider)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.Provision {
+		return nil, auth.ErrFederatedAccountNotFound
+	}
+
+	password, err := s.unusablePassword(auth.PasswordContext{
+		Email:     identity.Email,
+		FirstName: identity.GivenName,
+		LastName:  identity.FamilyName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.CreateUser(ctx, &CreateUserRequest{
+		Email:         identity.Email,
+		FirstName:     identity.GivenName,
+		LastName:      identity.FamilyName,
+		Password:      password,
+		Role:          models.UserRole(provider.DefaultRole),
+		EmailVerified: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("provisioned federated user", logging.Fields{
+		"user_id":  user.ID,
+		"provider": identity.Provider,
+	})
+	return user, nil
+}
+
+func (s *FederationService) link(ctx context.Context, user *models.User, identity *federation.Identity) error {
+	err := s.repo.CreateFederatedIdentity(ctx, &repository.FederatedIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("linked federated identity", logging.Fields{
+		"user_id":  user.ID,
+		"provider": identity.Provider,
+	})
+	s.audit.Record(ctx, &audit.Event{
+		ActorID:      user.ID,
+		Action:       audit.ActionFederatedLink,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   user.ID,
+		NewValue: map[string]interface{}{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+		},
+	})
+	return nil
+}
+
+// unusablePassword returns a random password of the policy's maximum
+// length that passes the policy. The fixed suffix satisfies any required
+// character classes; a candidate that happens to contain the user's name
+// is replaced.
+func (s *FederationService) unusablePassword(pc auth.PasswordContext) (string, error) {
+	const suffix = "aA1!"
+	length := s.userService.config.PasswordPolicy.MaxLength - len(suffix)
+
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		b := make([]byte, 48)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+
+		password := base64.RawURLEncoding.EncodeToString(b)
+		if length < len(password) {
+			password = password[:length]
+		}
+		password += suffix
+
+		if err = s.userService.passwordRules.checkNew(password, pc); err == nil {
+			return password, nil
+		}
+	}
+	return "", err
+}
+
+// FederatedLoginRequest represents the second step of a federated login.
+type FederatedLoginRequest struct {
+	Code      string `json:"code"`
+	State     string `json:"state"`
+	Provider  string `json:"-"`
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+	DeviceID  string `json:"-"`
+}
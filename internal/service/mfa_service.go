@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+// MFAService manages TOTP enrollment and verifies second factors.
+type MFAService struct {
+	repo            *repository.PostgresUserStore
+	passwordService *auth.PasswordService
+	secrets         *auth.SecretBox
+	audit           *audit.Recorder
+	config          *config.Config
+	logger          *logging.LoggerV2
+}
+
+// NewMFAService creates a new MFA service.
+func NewMFAService(
+	repo *repository.PostgresUserStore,
+	passwordService *auth.PasswordService,
+	secrets *auth.SecretBox,
+	auditLog *audit.Recorder,
+	cfg *config.Config,
+) *MFAService {
+	return &MFAService{
+		repo:            repo,
+		passwordService: passwordService,
+		secrets:         secrets,
+		audit:           auditLog,
+		config:          cfg,
+		logger:          logging.NewLoggerV2("mfa-service"),
+	}
+}
+
+// Status returns a user's MFA state.
+func (s *MFAService) Status(ctx context.Context, userID string) (*MFAStatus, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MFAStatus{
+		Required: s.config.MFA.RoleRequiresMFA(string(user.Role)),
+	}
+
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err == errors.ErrNotFound {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !m.Enabled {
+		status.Pending = true
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.ConfirmedAt = m.ConfirmedAt
+	status.RecoveryCodesRemaining, err = s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// Enabled reports whether a user has confirmed MFA enrollment.
+func (s *MFAService) Enabled(ctx context.Context, userID string) (bool, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err == errors.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.Enabled, nil
+}
+
+// BeginEnrollment generates a new TOTP secret for the user. MFA is not
+// enabled until the user proves their authenticator works by calling
+// ConfirmEnrollment with a code from it.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID string) (*MFAEnrollment, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SavePendingMFA(ctx, userID, sealed); err != nil {
+		if err == errors.ErrAlreadyExists {
+			return nil, auth.ErrMFAAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	s.logger.Info("MFA enrollment started", logging.Fields{"user_id": userID})
+
+	return &MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(s.config.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA once the user submits a valid code for the
+// pending secret. It returns the recovery codes, which are only ever shown
+// this once.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err == errors.ErrNotFound {
+		return nil, auth.ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Enabled {
+		return nil, auth.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.secrets.Open(m.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := auth.ValidateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, auth.ErrInvalidMFACode
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.EnableMFA(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("MFA enabled", logging.Fields{"user_id": userID})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionMFAEnable,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		NewValue:     map[string]interface{}{"method": "totp"},
+	})
+
+	return codes, nil
+}
+
+// Disable turns MFA off after re-checking the password and a current code.
+// Users whose role requires MFA cannot disable it.
+func (s *MFAService) Disable(ctx context.Context, userID, password, code string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.config.MFA.RoleRequiresMFA(string(user.Role)) {
+		return auth.ErrMFARequired
+	}
+
+	hash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if valid, _ := s.passwordService.CheckPassword(password, hash); !valid {
+		return auth.ErrPasswordMismatch
+	}
+
+	if _, err := s.VerifyCode(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.DisableMFA(ctx, userID); err != nil {
+		return err
+	}
+
+	s.logger.Info("MFA disabled", logging.Fields{"user_id": userID})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionMFADisable,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		OldValue:     map[string]interface{}{"method": "totp"},
+	})
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking a
+// current code. Earlier codes stop working immediately.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if _, err := s.VerifyCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionRecoveryCodes,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+	})
+
+	return codes, nil
+}
+
+// VerifyCode checks a TOTP code or, failing that, a recovery code, and
+// consumes it so it cannot be used again. It returns the authentication
+// methods the code proves, not including "mfa" itself.
+func (s *MFAService) VerifyCode(ctx context.Context, userID, code string) ([]string, error) {
+	m, err := s.repo.GetMFA(ctx, userID)
+	if err == errors.ErrNotFound || (err == nil && !m.Enabled) {
+		return nil, auth.ErrMFANotEnabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, auth.ErrInvalidMFACode
+	}
+
+	secret, err := s.secrets.Open(m.Secret)
+	if err != nil {
+		s.logger.Error("failed to decrypt TOTP secret", logging.Fields{"user_id": userID})
+		return nil, err
+	}
+
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now()); ok {
+		// A code is valid for several periods; accept each step only once
+		if err := s.repo.UseTOTPStep(ctx, userID, step); err != nil {
+			if err == errors.ErrNotFound {
+				return nil, auth.ErrInvalidMFACode
+			}
+			return nil, err
+		}
+		return []string{auth.AuthMethodOTP}, nil
+	}
+
+	digest := s.secrets.Digest(auth.NormalizeRecoveryCode(code))
+	if err := s.repo.UseRecoveryCode(ctx, userID, digest); err != nil {
+		if err == errors.ErrNotFound {
+			return nil, auth.ErrInvalidMFACode
+		}
+		return nil, err
+	}
+
+	remaining, _ := s.repo.CountRecoveryCodes(ctx, userID)
+	s.logger.Warn("recovery code used", logging.Fields{
+		"user_id":   userID,
+		"remaining": remaining,
+	})
+
+	return []string{}, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the digests to store.
+func (s *MFAService) newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = s.secrets.Digest(auth.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// MFAStatus describes a user's MFA state.
+type MFAStatus struct {
+	Enabled bool `json:"enabled"`
+
+	// Pending is true between BeginEnrollment and ConfirmEnrollment.
+	Pending bool `json:"pending"`
+
+	// Required is true if the user's role must use MFA.
+	Required bool `json:"required"`
+
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// MFAEnrollment is a new TOTP secret awaiting confirmation.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/ids"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+)
+
+const (
+	maxOAuthClientNameLen  = 100
+	maxOAuthRedirectURIs   = 10
+	maxOAuthRedirectURILen = 2048
+)
+
+// OAuthClientService registers the applications that may sign users in
+// through the OpenID Connect provider, and the machine clients other
+// services use to call this one.
+type OAuthClientService struct {
+	store  oidc.ClientStore
+	audit  *audit.Recorder
+	logger *logging.LoggerV2
+}
+
+// NewOAuthClientService creates a new OAuth client service.
+func NewOAuthClientService(store oidc.ClientStore, auditLog *audit.Recorder) *OAuthClientService {
+	return &OAuthClientService{
+		store:  store,
+		audit:  auditLog,
+		logger: logging.NewLoggerV2("oauth-client-service"),
+	}
+}
+
+// Create registers a client. Confidential clients are issued a secret,
+// which is only returned here.
+func (s *OAuthClientService) Create(ctx context.Context, req *CreateOAuthClientRequest) (*CreatedOAuthClient, error) {
+	if err := validateCreateOAuthClientRequest(req); err != nil {
+		return nil, err
+	}
+
+	client := &oidc.Client{
+		ID:           ids.NewClientID(),
+		Name:         strings.TrimSpace(req.Name),
+		RedirectURIs: req.RedirectURIs,
+		Trusted:      req.Trusted,
+		Scopes:       req.Scopes,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	var secret string
+	if req.Confidential {
+		var err error
+		secret, client.SecretHash, err = auth.GenerateClientSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.store.CreateClient(ctx, client); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("OAuth client registered", logging.Fields{"client_id": client.ID})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionOAuthClientCreate,
+		ResourceType: audit.ResourceOAuthClient,
+		ResourceID:   client.ID,
+		NewValue: map[string]interface{}{
+			"name":          client.Name,
+			"redirect_uris": client.RedirectURIs,
+			"confidential":  client.Confidential(),
+			"trusted":       client.Trusted,
+			"scopes":        client.Scopes,
+		},
+	})
+
+	return &CreatedOAuthClient{Client: client, Secret: secret}, nil
+}
+
+// List returns every registered client.
+func (s *OAuthClientService) List(ctx context.Context) ([]*oidc.Client, error) {
+	return s.store.ListClients(ctx)
+}
+
+// Delete removes a client. Sessions already issued to it stay valid until
+// they expire or are revoked.
+func (s *OAuthClientService) Delete(ctx context.Context, id string) error {
+	if err := s.store.DeleteClient(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Info("OAuth client deleted", logging.Fields{"client_id": id})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionOAuthClientDelete,
+		ResourceType: audit.ResourceOAuthClient,
+		ResourceID:   id,
+	})
+	return nil
+}
+
+func validateCreateOAuthClientRequest(req *CreateOAuthClientRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > maxOAuthClientNameLen {
+		return errors.ErrValidation
+	}
+
+	// Machine clients only use the client_credentials grant and need no
+	// redirect URIs
+	if len(req.RedirectURIs) == 0 && len(req.Scopes) == 0 {
+		return errors.ErrValidation
+	}
+	if len(req.RedirectURIs) > maxOAuthRedirectURIs {
+		return errors.ErrValidation
+	}
+	for _, uri := range req.RedirectURIs {
+		if len(uri) > maxOAuthRedirectURILen || !oidc.ValidRedirectURI(uri) {
+			return errors.ErrValidation
+		}
+	}
+
+	// Only confidential clients can authenticate for themselves
+	if len(req.Scopes) > 0 && !req.Confidential {
+		return errors.ErrValidation
+	}
+	for _, scope := range req.Scopes {
+		if !auth.IsServiceScope(scope) {
+			return errors.ErrValidation
+		}
+	}
+
+	return nil
+}
+
+// CreateOAuthClientRequest represents a request to register an OAuth client.
+type CreateOAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+
+	// Confidential clients run on a server and can keep a secret.
+	Confidential bool `json:"confidential"`
+
+	// Trusted clients are first-party apps that skip the consent screen.
+	Trusted bool `json:"trusted"`
+
+	// Scopes are the service scopes a confidential client may request for
+	// itself with the client_credentials grant, such as "users:read:any".
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedOAuthClient is a newly registered client. Secret is only returned
+// here, and is empty for public clients.
+type CreatedOAuthClient struct {
+	*oidc.Client
+	Secret string `json:"client_secret,omitempty"`
+}
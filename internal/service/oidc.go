@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/oidc"
+)
+
+// OIDCAccounts returns the view of the auth service the OpenID Connect
+// provider signs users in and issues tokens through. The OAuth token
+// endpoint introspects and revokes tokens through it.
+func (s *AuthService) OIDCAccounts() oidc.Accounts {
+	return &oidcAccounts{s}
+}
+
+// oidcAccounts adapts AuthService to oidc.Accounts. Sign-ins go through the
+// same lockout, MFA and audit paths as first-party logins.
+type oidcAccounts struct {
+	s *AuthService
+}
+
+func (a *oidcAccounts) Login(ctx context.Context, req *oidc.LoginRequest) (*oidc.LoginResult, error) {
+	resp, err := a.s.Login(ctx, &LoginRequest{
+		Email:     req.Email,
+		Password:  req.Password,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.loginResult(ctx, resp)
+}
+
+func (a *oidcAccounts) VerifyMFA(ctx context.Context, req *oidc.MFARequest) (*oidc.LoginResult, error) {
+	resp, err := a.s.VerifyMFA(ctx, &VerifyMFARequest{
+		MFAToken:  req.MFAToken,
+		Code:      req.Code,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.loginResult(ctx, resp)
+}
+
+func (a *oidcAccounts) loginResult(ctx context.Context, resp *LoginResponse) (*oidc.LoginResult, error) {
+	if resp.MFARequired {
+		return &oidc.LoginResult{MFAToken: resp.MFAToken}, nil
+	}
+
+	session, err := a.Session(ctx, resp.SessionID)
+	if err == auth.ErrMFARequired {
+		// The session cannot be used, so do not leave it behind
+		if revokeErr := a.s.sessionService.Revoke(ctx, resp.SessionID); revokeErr != nil {
+			a.s.logger.Warn("failed to revoke session", logging.Fields{
+				"session_id": resp.SessionID,
+				"error":      revokeErr.Error(),
+			})
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &oidc.LoginResult{Session: session}, nil
+}
+
+// Session returns a browser session, refusing sessions whose role requires
+// MFA when the user has not completed it, as the v2 API does.
+func (a *oidcAccounts) Session(ctx context.Context, sessionID string) (*auth.Session, error) {
+	session, err := a.s.sessionService.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if a.s.config.MFA.RoleRequiresMFA(session.Role) && !auth.HasAuthMethod(session.AuthMethods, auth.AuthMethodMFA) {
+		return nil, auth.ErrMFARequired
+	}
+	return session, nil
+}
+
+func (a *oidcAccounts) User(ctx context.Context, userID string) (*models.User, bool, error) {
+	user, err := a.s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	verification, err := a.s.repo.GetEmailVerification(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, verification.Verified, nil
+}
+
+func (a *oidcAccounts) IssueTokens(ctx context.Context, browser *auth.Session, clientID string, scopes []string) (*oidc.Tokens, error) {
+	user, err := a.s.repo.GetByID(ctx, browser.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, errors.ErrUserInactive
+	}
+
+	session, err := a.s.sessionService.CreateForClient(ctx, browser, clientID, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := a.s.jwtService.GenerateSessionToken(user, session)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, _, err := a.s.refreshTokens.Issue(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	a.s.audit.Record(ctx, &audit.Event{
+		ActorID:      user.ID,
+		Action:       audit.ActionOAuthAuthorize,
+		ResourceType: audit.ResourceSession,
+		ResourceID:   session.ID,
+		NewValue: map[string]interface{}{
+			"client_id": clientID,
+			"scopes":    scopes,
+		},
+		IPAddress: browser.IPAddress,
+	})
+
+	return &oidc.Tokens{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    a.s.config.JWT.Expiration,
+		Session:      session,
+	}, nil
+}
+
+func (a *oidcAccounts) Refresh(ctx context.Context, clientID, refreshToken string) (*oidc.Tokens, error) {
+	resp, session, err := a.s.refresh(ctx, refreshToken, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return &oidc.Tokens{
+		AccessToken:  resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    a.s.config.JWT.Expiration,
+		Session:      session,
+	}, nil
+}
+
+func (a *oidcAccounts) ValidateAccessToken(ctx context.Context, token string) (*auth.JWTClaims, error) {
+	return a.s.ValidateToken(ctx, token)
+}
+
+// RevokeToken revokes a token issued to clientID (RFC 7009). An access token
+// is added to the denylist until it expires; a refresh token ends its whole
+// session, which also invalidates the session's access tokens.
+func (a *oidcAccounts) RevokeToken(ctx context.Context, clientID, token string) error {
+	if claims, err := a.s.jwtService.ValidateToken(token); err == nil {
+		return a.revokeAccessToken(ctx, clientID, claims)
+	}
+
+	record, err := a.s.refreshTokens.Lookup(ctx, token)
+	if err == auth.ErrInvalidToken || err == auth.ErrExpiredToken {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	session, err := a.s.sessionService.Get(ctx, record.SessionID)
+	switch err {
+	case nil:
+	case auth.ErrSessionNotFound, auth.ErrSessionExpired, auth.ErrSessionRevoked, auth.ErrSessionInvalid:
+		return nil
+	default:
+		return err
+	}
+	if session.ClientID != clientID {
+		a.s.logger.Warn("refresh token revocation by another client", logging.Fields{
+			"session_id": session.ID,
+			"client_id":  clientID,
+		})
+		return nil
+	}
+
+	if err := a.s.sessionService.Revoke(ctx, session.ID); err != nil {
+		return err
+	}
+
+	a.s.audit.Record(ctx, &audit.Event{
+		ActorID:      session.UserID,
+		Action:       audit.ActionOAuthRevoke,
+		ResourceType: audit.ResourceSession,
+		ResourceID:   session.ID,
+		NewValue: map[string]interface{}{
+			"client_id":  clientID,
+			"token_type": "refresh_token",
+		},
+	})
+	return nil
+}
+
+func (a *oidcAccounts) revokeAccessToken(ctx context.Context, clientID string, claims *auth.JWTClaims) error {
+	if claims.ClientID != clientID {
+		a.s.logger.Warn("access token revocation by another client", logging.Fields{
+			"token_id":  claims.ID,
+			"client_id": clientID,
+		})
+		return nil
+	}
+	// Tokens issued before token IDs were added expire on their own
+	if claims.ID == "" {
+		return nil
+	}
+
+	if err := a.s.tokenDenylist.Add(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	// Client credentials tokens belong to the client rather than a session
+	resourceType, resourceID := audit.ResourceSession, claims.SessionID
+	if claims.SessionID == "" {
+		resourceType, resourceID = audit.ResourceOAuthClient, clientID
+	}
+
+	a.s.audit.Record(ctx, &audit.Event{
+		ActorID:      claims.UserID,
+		Action:       audit.ActionOAuthRevoke,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		NewValue: map[string]interface{}{
+			"client_id":  clientID,
+			"token_type": "access_token",
+			"token_id":   claims.ID,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+// passwordRules enforces the password policy for both UserService and
+// AuthService, including the history check the policy cannot do alone.
+type passwordRules struct {
+	policy    *auth.PasswordPolicy
+	passwords *auth.PasswordService
+	repo      *repository.PostgresUserStore
+	logger    *logging.LoggerV2
+}
+
+func newPasswordRules(policy *auth.PasswordPolicy, passwords *auth.PasswordService, repo *repository.PostgresUserStore) *passwordRules {
+	return &passwordRules{
+		policy:    policy,
+		passwords: passwords,
+		repo:      repo,
+		logger:    logging.NewLoggerV2("password-policy"),
+	}
+}
+
+// checkNew validates a password for an account that does not exist yet.
+func (r *passwordRules) checkNew(password string, pc auth.PasswordContext) error {
+	return r.policy.Validate(password, pc)
+}
+
+// check validates a new password for an existing user, rejecting their
+// current password and the ones in their history alongside every other
+// violation. It returns a *auth.PasswordPolicyError listing them all.
+func (r *passwordRules) check(ctx context.Context, user *models.User, currentHash, password string) error {
+	violations := r.policy.Check(password, auth.PasswordContext{
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	})
+
+	reused, err := r.reused(ctx, user.ID, currentHash, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		violations = append(violations, auth.PasswordViolation{
+			Code:    auth.ViolationRecentlyUsed,
+			Message: fmt.Sprintf("must not match any of your last %d passwords", r.policy.HistorySize()+1),
+		})
+	}
+
+	if len(violations) > 0 {
+		return &auth.PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// record adds the hash being replaced to the user's history. Failures are
+// logged rather than returned because the password has already changed.
+func (r *passwordRules) record(ctx context.Context, userID, oldHash string) {
+	if r.policy.HistorySize() == 0 || oldHash == "" {
+		return
+	}
+	if err := r.repo.AddPasswordHistory(ctx, userID, oldHash, r.policy.HistorySize()); err != nil {
+		r.logger.Error("failed to record password history", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+}
+
+func (r *passwordRules) reused(ctx context.Context, userID, currentHash, password string) (bool, error) {
+	if valid, _ := r.passwords.CheckPassword(password, currentHash); valid {
+		return true, nil
+	}
+	if r.policy.HistorySize() == 0 {
+		return false, nil
+	}
+
+	history, err := r.repo.GetPasswordHistory(ctx, userID, r.policy.HistorySize())
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range history {
+		if valid, _ := r.passwords.CheckPassword(password, hash); valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
+)
+
+// ForgotPassword emails a password reset link to the account registered
+// under email. It returns nil whether or not the account exists so callers
+// cannot use it to discover registered addresses. Delivery happens in the
+// background for the same reason: response time must not depend on whether
+// a link was sent.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	s.logger.Info("password reset requested", logging.Fields{"email": email})
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			s.logger.Info("password reset requested for unknown email", logging.Fields{"email": email})
+			return nil
+		}
+		return err
+	}
+
+	if !user.Active {
+		s.logger.Info("password reset requested for inactive user", logging.Fields{"user_id": user.ID})
+		return nil
+	}
+
+	go s.sendPasswordReset(context.WithoutCancel(ctx), user)
+
+	return nil
+}
+
+func (s *AuthService) sendPasswordReset(ctx context.Context, user *models.User) {
+	token, expiresAt, err := s.passwordResets.Issue(ctx, user.ID)
+	if err != nil {
+		return
+	}
+
+	link, err := tokenLink(s.config.PasswordReset.URL, token)
+	if err != nil {
+		s.logger.Error("invalid password reset URL", logging.Fields{"error": err.Error()})
+		return
+	}
+
+	_, err = s.notifier.Send(ctx, &models.SendNotificationRequest{
+		Type:       models.NotificationTypeEmail,
+		Priority:   models.NotificationPriorityHigh,
+		Recipient:  user.Email,
+		TemplateID: notify.TemplatePasswordReset,
+		TemplateData: map[string]interface{}{
+			"first_name": user.FirstName,
+			"reset_url":  link,
+			"expires_at": expiresAt,
+		},
+	})
+	if err != nil {
+		s.logger.Error("failed to send password reset email", logging.Fields{
+			"user_id": user.ID,
+			"error":   err.Error(),
+		})
+	}
+}
+
+// tokenLink appends token to a frontend URL as the "token" query parameter.
+func tokenLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// ResetPassword sets a new password using a reset token and revokes every
+// session the user has, so a stolen session does not survive the reset. It
+// also completes resets forced by PasswordMigrator.ForcePasswordReset.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	// Check the password before redeeming so a rejected one does not burn
+	// the token
+	userID, err := s.passwordResets.Lookup(ctx, token)
+	if err != nil {
+		s.logger.Warn("password reset token rejected", logging.Fields{"error": err.Error()})
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	currentHash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwordRules.check(ctx, user, currentHash, newPassword); err != nil {
+		return err
+	}
+
+	// Redeeming can still fail if a concurrent request spent the token
+	if _, err := s.passwordResets.Redeem(ctx, token); err != nil {
+		s.logger.Warn("password reset token rejected", logging.Fields{"error": err.Error()})
+		return err
+	}
+
+	newHash, err := s.passwordService.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePasswordHash(ctx, userID, newHash, s.passwordService.Algorithm()); err != nil {
+		return err
+	}
+	s.passwordRules.record(ctx, userID, currentHash)
+
+	if err := s.sessionService.DeleteAllForUser(ctx, userID); err != nil {
+		s.logger.Error("failed to revoke sessions after password reset", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+		return err
+	}
+
+	// Proving control of the mailbox is enough to lift a lockout
+	if err := s.lockout.Unlock(ctx, user.Email); err != nil {
+		s.logger.Warn("failed to clear lockout after password reset", logging.Fields{
+			"user_id": userID,
+			"error":   err.Error(),
+		})
+	}
+
+	s.logger.Info("password reset completed", logging.Fields{"user_id": userID})
+
+	s.audit.Record(ctx, &audit.Event{
+		ActorID:      userID,
+		Action:       audit.ActionPasswordReset,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+	})
+
+	return nil
+}
@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"strings"
 
 	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
 	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
 	"github.com/tm-acme-shop/acme-shop-shared-go/models"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/config"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/notify"
 	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
 )
 
@@ -18,6 +21,10 @@ type UserService struct {
 	cache           *repository.RedisUserCache
 	legacyRepo      *repository.PostgresUserStoreV1
 	passwordService *auth.PasswordService
+	passwordRules   *passwordRules
+	emailTokens     *auth.EmailTokenService
+	notifier        notify.Sender
+	audit           *audit.Recorder
 	config          *config.Config
 	logger          *logging.LoggerV2
 }
@@ -28,6 +35,10 @@ func NewUserService(
 	cache *repository.RedisUserCache,
 	legacyRepo *repository.PostgresUserStoreV1,
 	passwordService *auth.PasswordService,
+	passwordPolicy *auth.PasswordPolicy,
+	emailTokens *auth.EmailTokenService,
+	notifier notify.Sender,
+	auditLog *audit.Recorder,
 	cfg *config.Config,
 ) *UserService {
 	return &UserService{
@@ -35,6 +46,10 @@ func NewUserService(
 		cache:           cache,
 		legacyRepo:      legacyRepo,
 		passwordService: passwordService,
+		passwordRules:   newPasswordRules(passwordPolicy, passwordService, repo),
+		emailTokens:     emailTokens,
+		notifier:        notifier,
+		audit:           auditLog,
 		config:          cfg,
 		logger:          logging.NewLoggerV2("user-service"),
 	}
@@ -93,7 +108,15 @@ func (s *UserService) CreateUser(ctx context.Context, req *CreateUserRequest) (*
 		"role":  req.Role,
 	})
 
-	// Hash password using bcrypt
+	err := s.passwordRules.checkNew(req.Password, auth.PasswordContext{
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	hashedPassword, err := s.passwordService.HashPassword(req.Password)
 	if err != nil {
 		return nil, err
@@ -122,6 +145,22 @@ func (s *UserService) CreateUser(ctx context.Context, req *CreateUserRequest) (*
 		"email":   user.Email,
 	})
 
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionUserCreate,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   user.ID,
+		NewValue:     audit.Snapshot(user),
+	})
+
+	if req.EmailVerified {
+		if err := s.repo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	go s.sendVerificationEmail(context.WithoutCancel(ctx), user, user.Email)
+
 	return user, nil
 }
 
@@ -135,20 +174,52 @@ func (s *UserService) CreateUserV1(ctx context.Context, email, name, password st
 		return nil, errors.ErrDeprecatedAPI
 	}
 
-	// Uses legacy MD5 hashing (deprecated)
-	// TODO(TEAM-SEC): This creates users with MD5 hashes, needs migration
-	return s.legacyRepo.CreateUser(ctx, email, name, password)
+	// v1 callers send a full name; split it the way the legacy store did and
+	// create the user through the v2 path so the password policy and hashing
+	// apply to them too.
+	var firstName, lastName string
+	if parts := strings.Fields(name); len(parts) > 0 {
+		firstName, lastName = parts[0], strings.Join(parts[1:], " ")
+	}
+
+	user, err := s.CreateUser(ctx, &CreateUserRequest{
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Password:  password,
+		Role:      models.RoleCustomer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user.ToV1(), nil
 }
 
 // UpdateUser updates an existing user.
 func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.UpdateUserRequest) (*models.User, error) {
 	s.logger.Info("updating user", logging.Fields{"user_id": id})
 
+	// Capture the current state for the audit diff
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := s.repo.Update(ctx, id, req)
 	if err != nil {
 		return nil, err
 	}
 
+	oldValue, newValue := audit.Diff(before, user)
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionUserUpdate,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   id,
+		OldValue:     oldValue,
+		NewValue:     newValue,
+	})
+
 	// Invalidate cache
 	if s.config.Features.EnableUserCache {
 		if err := s.cache.Invalidate(ctx, id); err != nil {
@@ -166,10 +237,22 @@ func (s *UserService) UpdateUser(ctx context.Context, id string, req *models.Upd
 func (s *UserService) DeleteUser(ctx context.Context, id string) error {
 	s.logger.Info("deleting user", logging.Fields{"user_id": id})
 
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
 	}
 
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionUserDelete,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   id,
+		OldValue:     audit.Snapshot(before),
+	})
+
 	// Invalidate cache
 	if s.config.Features.EnableUserCache {
 		s.cache.Invalidate(ctx, id)
@@ -248,30 +331,52 @@ func (s *UserService) ChangePassword(ctx context.Context, id, oldPassword, newPa
 		return auth.ErrPasswordMismatch
 	}
 
-	// Hash new password with bcrypt
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.passwordRules.check(ctx, user, currentHash, newPassword); err != nil {
+		return err
+	}
+
 	newHash, err := s.passwordService.HashPassword(newPassword)
 	if err != nil {
 		return err
 	}
 
 	// Update password
-	return s.repo.UpdatePasswordHash(ctx, id, newHash)
+	if err := s.repo.UpdatePasswordHash(ctx, id, newHash, s.passwordService.Algorithm()); err != nil {
+		return err
+	}
+	s.passwordRules.record(ctx, id, currentHash)
+
+	// Hashes are never written to the audit log
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionPasswordChange,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   id,
+	})
+
+	return nil
 }
 
-// MigratePassword upgrades a password hash from MD5/SHA1 to bcrypt.
+// MigratePassword rehashes a password with the configured algorithm.
 func (s *UserService) MigratePassword(ctx context.Context, id, password string) error {
 	if !s.config.Features.EnablePasswordMigration {
 		return nil
 	}
 
-	s.logger.Info("migrating password hash to bcrypt", logging.Fields{"user_id": id})
+	s.logger.Info("migrating password hash", logging.Fields{
+		"user_id": id,
+		"to":      s.passwordService.Algorithm(),
+	})
 
 	newHash, err := s.passwordService.MigratePasswordHash(password)
 	if err != nil {
 		return err
 	}
 
-	return s.repo.UpdatePasswordHash(ctx, id, newHash)
+	return s.repo.UpdatePasswordHash(ctx, id, newHash, s.passwordService.Algorithm())
 }
 
 // CreateUserRequest represents a request to create a user.
@@ -281,6 +386,10 @@ type CreateUserRequest struct {
 	LastName  string          `json:"last_name"`
 	Password  string          `json:"password"`
 	Role      models.UserRole `json:"role"`
+
+	// EmailVerified marks the address as already verified, e.g. by an
+	// identity provider, instead of sending a verification email.
+	EmailVerified bool `json:"-"`
 }
 
 // ListUsersResponse represents the response from listing users.
@@ -80,38 +80,6 @@ func ValidateEmail(email string) bool {
 	return emailRegex.MatchString(email)
 }
 
-// ValidatePasswordStrength checks if a password meets strength requirements.
-func ValidatePasswordStrength(password string) error {
-	if len(password) < 8 {
-		return errors.ErrPasswordTooWeak
-	}
-
-	if len(password) > 72 {
-		return errors.ErrValidation
-	}
-
-	hasLower := false
-	hasUpper := false
-	hasDigit := false
-
-	for _, c := range password {
-		switch {
-		case c >= 'a' && c <= 'z':
-			hasLower = true
-		case c >= 'A' && c <= 'Z':
-			hasUpper = true
-		case c >= '0' && c <= '9':
-			hasDigit = true
-		}
-	}
-
-	if !hasLower || !hasUpper || !hasDigit {
-		return errors.ErrPasswordTooWeak
-	}
-
-	return nil
-}
-
 func isValidRole(role models.UserRole) bool {
 	switch role {
 	case models.RoleAdmin, models.RoleCustomer, models.RoleVendor:
@@ -160,4 +128,5 @@ type LoginRequest struct {
 	Password  string `json:"password"`
 	IPAddress string `json:"-"`
 	UserAgent string `json:"-"`
+	DeviceID  string `json:"-"`
 }
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tm-acme-shop/acme-shop-shared-go/errors"
+	"github.com/tm-acme-shop/acme-shop-shared-go/logging"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/audit"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/auth"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/metrics"
+	"github.com/tm-acme-shop/acme-shop-users-service/internal/repository"
+)
+
+const (
+	defaultPasskeyName = "Passkey"
+	maxPasskeyNameLen  = 100
+)
+
+// BeginPasskeyRegistration returns the options for registering a new passkey
+// for the user.
+func (s *AuthService) BeginPasskeyRegistration(ctx context.Context, userID string) (*auth.CredentialCreationOptions, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]auth.CredentialDescriptor, len(existing))
+	for i, cred := range existing {
+		exclude[i] = auth.CredentialDescriptor{Type: "public-key", ID: cred.ID, Transports: cred.Transports}
+	}
+
+	return s.webauthn.BeginRegistration(ctx, auth.WebAuthnUser{
+		ID:          user.ID,
+		Name:        user.Email,
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+	}, exclude)
+}
+
+// FinishPasskeyRegistration verifies the authenticator's response and stores
+// the new passkey under name.
+func (s *AuthService) FinishPasskeyRegistration(ctx context.Context, userID, name string, resp *auth.RegistrationResponse) (*repository.WebAuthnCredential, error) {
+	verified, err := s.webauthn.FinishRegistration(ctx, userID, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	if len(name) > maxPasskeyNameLen {
+		name = name[:maxPasskeyNameLen]
+	}
+
+	cred := &repository.WebAuthnCredential{
+		ID:         verified.ID,
+		UserID:     userID,
+		Name:       name,
+		PublicKey:  verified.PublicKey,
+		SignCount:  verified.SignCount,
+		Transports: verified.Transports,
+		AAGUID:     verified.AAGUID,
+	}
+	if cred.Transports == nil {
+		cred.Transports = []string{}
+	}
+	if err := s.repo.CreateWebAuthnCredential(ctx, cred); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("passkey registered", logging.Fields{"user_id": userID})
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionPasskeyAdd,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		NewValue:     map[string]interface{}{"credential_id": cred.ID, "name": cred.Name},
+	})
+
+	return cred, nil
+}
+
+// ListPasskeys returns the user's registered passkeys.
+func (s *AuthService) ListPasskeys(ctx context.Context, userID string) ([]*repository.WebAuthnCredential, error) {
+	return s.repo.ListWebAuthnCredentials(ctx, userID)
+}
+
+// DeletePasskey removes one of the user's passkeys.
+func (s *AuthService) DeletePasskey(ctx context.Context, userID, credentialID string) error {
+	if err := s.repo.DeleteWebAuthnCredential(ctx, userID, credentialID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, &audit.Event{
+		Action:       audit.ActionPasskeyRemove,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userID,
+		OldValue:     map[string]interface{}{"credential_id": credentialID},
+	})
+
+	return nil
+}
+
+// BeginPasskeyLogin returns the options for signing in with a passkey.
+func (s *AuthService) BeginPasskeyLogin(ctx context.Context) (*auth.CredentialRequestOptions, error) {
+	return s.webauthn.BeginLogin(ctx)
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and signs the user in,
+// producing the same session and tokens as Login. A passkey with user
+// verification counts as multi-factor; without it, users who have TOTP
+// enabled must still complete VerifyMFA.
+func (s *AuthService) FinishPasskeyLogin(ctx context.Context, req *PasskeyLoginRequest) (*LoginResponse, error) {
+	assertion, err := s.webauthn.ParseAssertion(ctx, &req.Credential)
+	if err != nil {
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInvalidPasskey).Inc()
+		return nil, err
+	}
+
+	cred, err := s.repo.GetWebAuthnCredential(ctx, assertion.CredentialID)
+	if err == errors.ErrNotFound || (err == nil && cred.UserID != assertion.UserID) {
+		s.logger.Warn("login failed - unknown passkey", logging.Fields{
+			"credential_id": assertion.CredentialID,
+		})
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInvalidPasskey).Inc()
+		return nil, errors.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertion.Verify(cred.PublicKey, cred.SignCount); err != nil {
+		s.logger.Warn("login failed - passkey rejected", logging.Fields{
+			"user_id":       cred.UserID,
+			"credential_id": cred.ID,
+			"reason":        err.Error(),
+		})
+		s.auditLoginFailure(ctx, cred.UserID, req.IPAddress, metrics.LoginInvalidPasskey)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInvalidPasskey).Inc()
+		return nil, errors.ErrInvalidCredentials
+	}
+
+	// Fails if a concurrent login already advanced the counter
+	if err := s.repo.UpdateWebAuthnSignCount(ctx, cred.ID, cred.SignCount, assertion.SignCount); err != nil {
+		if err == errors.ErrNotFound {
+			return nil, errors.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, cred.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginInactive)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginInactive).Inc()
+		return nil, errors.ErrUserInactive
+	}
+
+	if err := s.requireVerifiedEmail(ctx, user.ID); err != nil {
+		s.auditLoginFailure(ctx, user.ID, req.IPAddress, metrics.LoginUnverified)
+		metrics.LoginAttempts.WithLabelValues("v2", metrics.LoginUnverified).Inc()
+		return nil, err
+	}
+
+	authMethods := []string{auth.AuthMethodHardwareKey}
+	if assertion.UserVerified {
+		return s.completeLogin(ctx, user, req.IPAddress, req.UserAgent, req.DeviceID, append(authMethods, auth.AuthMethodMFA))
+	}
+
+	mfaEnabled, err := s.mfa.Enabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled {
+		return s.beginMFAChallenge(ctx, user, req.IPAddress, req.UserAgent, authMethods)
+	}
+
+	return s.completeLogin(ctx, user, req.IPAddress, req.UserAgent, req.DeviceID, authMethods)
+}
+
+// PasskeyLoginRequest represents the second step of a passkey login.
+type PasskeyLoginRequest struct {
+	Credential auth.AssertionResponse `json:"credential"`
+	IPAddress  string                 `json:"-"`
+	UserAgent  string                 `json:"-"`
+	DeviceID   string                 `json:"-"`
+}